@@ -0,0 +1,160 @@
+package bootstrap
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/paoloanzn/go-bootstrap/config"
+	"github.com/paoloanzn/go-bootstrap/format"
+	"github.com/paoloanzn/go-bootstrap/parsing"
+)
+
+type fileState int
+
+const (
+	fileCreated fileState = iota
+	fileOverwritten
+	fileKept
+)
+
+// Result lists the paths of an applied plan, relative to the output
+// directory, in plan order. Skipped holds the nodes left out by the template
+// and the paths that existed and were kept.
+type Result struct {
+	Created     []string
+	Skipped     []string
+	Overwritten []string
+	// Injected lists the files injections and Go edits changed. Injections
+	// whose content a file already holds, and edits it already has, are
+	// skipped.
+	Injected []string
+}
+
+// Bootstrap creates the project described by pJsonTemplate, with the
+// variables and in the output directory of cfg.
+func Bootstrap(cfg *config.Config, pJsonTemplate *parsing.JSONTemplate) error {
+	plan, err := BuildPlan(cfg, pJsonTemplate)
+	if err != nil {
+		return err
+	}
+
+	_, err = Apply(cfg, plan)
+	return err
+}
+
+// Apply creates the directories and files of plan in the output directory of
+// cfg. Existing directories are reused and existing files are handled
+// according to the conflict policy of cfg.
+func Apply(cfg *config.Config, plan *Plan) (*Result, error) {
+	return ApplyContext(context.Background(), cfg, plan)
+}
+
+// ApplyContext is Apply, stopping before the next path once ctx is done. The
+// result lists what was done until then, also on errors.
+func ApplyContext(ctx context.Context, cfg *config.Config, plan *Plan) (*Result, error) {
+	return ApplyFS(ctx, NewOSFS(cfg.OutputDir), cfg, plan)
+}
+
+// ApplyFS is ApplyContext writing to fsys instead of the output directory of
+// cfg. The manifest of the generation is written last, see Manifest.
+func ApplyFS(ctx context.Context, fsys FS, cfg *config.Config, plan *Plan) (*Result, error) {
+	result := &Result{}
+
+	// fail before writing anything rather than half way through
+	if cfg.OnConflict == config.ConflictFail {
+		err := checkConflicts(fsys, plan)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	for _, action := range plan.Actions {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		switch action.Kind {
+		case ActionDir:
+			created, err := createDir(fsys, cfg, format.FormatPath(strings.TrimSuffix(action.Path, "/")))
+			if err != nil {
+				return result, err
+			}
+			if created {
+				result.Created = append(result.Created, action.Path)
+			} else {
+				result.Skipped = append(result.Skipped, action.Path)
+			}
+
+		case ActionFile:
+			state, err := writeFile(fsys, cfg, format.FormatPath(action.Path), action.Content)
+			if err != nil {
+				return result, err
+			}
+			switch state {
+			case fileCreated:
+				result.Created = append(result.Created, action.Path)
+			case fileOverwritten:
+				result.Overwritten = append(result.Overwritten, action.Path)
+			default:
+				result.Skipped = append(result.Skipped, action.Path)
+			}
+
+		case ActionInject:
+			injected, err := injectFile(fsys, cfg, action)
+			if err != nil {
+				return result, err
+			}
+			if injected {
+				result.Injected = append(result.Injected, action.Path)
+			} else {
+				result.Skipped = append(result.Skipped, action.Path)
+			}
+
+		case ActionEdit:
+			edited, err := editFile(fsys, cfg, action)
+			if err != nil {
+				return result, err
+			}
+			if edited {
+				result.Injected = append(result.Injected, action.Path)
+			} else {
+				result.Skipped = append(result.Skipped, action.Path)
+			}
+
+		case ActionSkip:
+			result.Skipped = append(result.Skipped, action.Path)
+		}
+	}
+
+	err := writeManifest(fsys, plan, result)
+	if err != nil {
+		return result, fmt.Errorf("Failed to write the manifest: %v", err)
+	}
+
+	return result, nil
+}
+
+// checkConflicts returns an error naming the files of plan that already
+// exist in fsys.
+func checkConflicts(fsys FS, plan *Plan) error {
+	var existing []string
+	for _, action := range plan.Actions {
+		if action.Kind != ActionFile {
+			continue
+		}
+		found, err := exists(fsys, action.Path)
+		if err != nil {
+			return err
+		}
+		if found {
+			existing = append(existing, action.Path)
+		}
+	}
+
+	if len(existing) > 0 {
+		return fmt.Errorf("Refusing to overwrite existing files: %s.", strings.Join(existing, ", "))
+	}
+
+	return nil
+}
@@ -0,0 +1,168 @@
+package bootstrap
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"path"
+	"sync"
+	"time"
+)
+
+// archiveModTime is the modification time of every archive entry, so that
+// generating the same project twice gives byte identical archives. It is the
+// earliest time zip files can represent.
+var archiveModTime = time.Date(1980, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// ArchiveFS is an FS streaming every entry to an archive as it is created,
+// so that a project can be generated straight into a tar.gz or zip file.
+// Entries cannot be overwritten once written. Close must be called to
+// complete the archive. It is safe for concurrent use.
+//
+// Archives are reproducible: entries are written in creation order with
+// fixed timestamps, owners and modes.
+type ArchiveFS struct {
+	mu    sync.Mutex
+	dirs  map[string]bool
+	files map[string]int64
+	add   func(name string, dir bool, perm fs.FileMode, data []byte) error
+	close func() error
+}
+
+// NewTarGzFS returns an ArchiveFS writing a gzip compressed tarball to w.
+func NewTarGzFS(w io.Writer) *ArchiveFS {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	a := newArchiveFS()
+	a.add = func(name string, dir bool, perm fs.FileMode, data []byte) error {
+		header := &tar.Header{
+			Name:     name,
+			Mode:     int64(perm),
+			Size:     int64(len(data)),
+			ModTime:  archiveModTime,
+			Typeflag: tar.TypeReg,
+		}
+		if dir {
+			header.Name += "/"
+			header.Typeflag = tar.TypeDir
+		}
+
+		err := tw.WriteHeader(header)
+		if err != nil {
+			return err
+		}
+
+		_, err = tw.Write(data)
+		return err
+	}
+	a.close = func() error {
+		err := tw.Close()
+		if err != nil {
+			return err
+		}
+		return gz.Close()
+	}
+
+	return a
+}
+
+// NewZipFS returns an ArchiveFS writing a zip archive to w.
+func NewZipFS(w io.Writer) *ArchiveFS {
+	zw := zip.NewWriter(w)
+
+	a := newArchiveFS()
+	a.add = func(name string, dir bool, perm fs.FileMode, data []byte) error {
+		header := &zip.FileHeader{Name: name, Method: zip.Deflate, Modified: archiveModTime}
+		header.SetMode(perm)
+		if dir {
+			header.Name += "/"
+			header.Method = zip.Store
+			header.SetMode(fs.ModeDir | perm)
+		}
+
+		f, err := zw.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+
+		_, err = f.Write(data)
+		return err
+	}
+	a.close = zw.Close
+
+	return a
+}
+
+func newArchiveFS() *ArchiveFS {
+	return &ArchiveFS{
+		dirs:  map[string]bool{".": true},
+		files: make(map[string]int64),
+	}
+}
+
+func (a *ArchiveFS) Stat(name string) (fs.FileInfo, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	name = path.Clean(name)
+	if a.dirs[name] {
+		return &memInfo{name: path.Base(name), dir: true}, nil
+	}
+	if size, exists := a.files[name]; exists {
+		return &memInfo{name: path.Base(name), size: size}, nil
+	}
+
+	return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+}
+
+func (a *ArchiveFS) Mkdir(name string, perm fs.FileMode) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	name = path.Clean(name)
+	if err := a.checkCreate("mkdir", name); err != nil {
+		return err
+	}
+
+	a.dirs[name] = true
+	return a.add(name, true, perm.Perm(), nil)
+}
+
+func (a *ArchiveFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	name = path.Clean(name)
+	if err := a.checkCreate("open", name); err != nil {
+		return err
+	}
+
+	a.files[name] = int64(len(data))
+	return a.add(name, false, perm.Perm(), data)
+}
+
+// checkCreate checks that name is new and that its parent directory exists.
+func (a *ArchiveFS) checkCreate(op string, name string) error {
+	if _, exists := a.files[name]; exists || a.dirs[name] {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrExist}
+	}
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	if !a.dirs[path.Dir(name)] {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+
+	return nil
+}
+
+// Close completes the archive. It does not close the underlying writer.
+func (a *ArchiveFS) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.close()
+}
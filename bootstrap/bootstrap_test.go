@@ -1,10 +1,31 @@
 package bootstrap
 
 import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
 	"io/ioutil"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/paoloanzn/go-bootstrap/config"
+	"github.com/paoloanzn/go-bootstrap/format"
+	"github.com/paoloanzn/go-bootstrap/goedit"
+	"github.com/paoloanzn/go-bootstrap/parsing"
 )
 
 // TestCreateDir covers the CreateDir function by testing:
@@ -24,7 +45,7 @@ func TestCreateDir(t *testing.T) {
 		// Create a new directory path that does not exist
 		targetDir := filepath.Join(baseDir, "newDir")
 		// Call CreateDir with abortIfFailed false
-		err = CreateDir(targetDir, false)
+		err = CreateDir(&config.Config{}, targetDir, false)
 		if err != nil {
 			t.Errorf("Expected no error, got: %v", err)
 		}
@@ -54,7 +75,7 @@ func TestCreateDir(t *testing.T) {
 		}
 
 		// Call CreateDir on an existing directory; should return nil error
-		err = CreateDir(targetDir, false)
+		err = CreateDir(&config.Config{}, targetDir, false)
 		if err != nil {
 			t.Errorf("Expected nil error when directory exists, but got: %v", err)
 		}
@@ -62,7 +83,7 @@ func TestCreateDir(t *testing.T) {
 
 	t.Run("ErrorInvalidPath", func(t *testing.T) {
 		// Call CreateDir with an invalid path (empty string)
-		err := CreateDir("", false)
+		err := CreateDir(&config.Config{}, "", false)
 		if err == nil {
 			t.Errorf("Expected an error for invalid path, got nil")
 		}
@@ -85,7 +106,7 @@ func TestCreateFile(t *testing.T) {
 		// Define a new file path in the temp directory
 		targetFile := filepath.Join(baseDir, "newFile.txt")
 		// Call CreateFile with abortIfFailed false
-		err = CreateFile(targetFile, false)
+		err = CreateFile(&config.Config{}, targetFile, false)
 		if err != nil {
 			t.Errorf("Expected no error, got: %v", err)
 		}
@@ -117,7 +138,7 @@ func TestCreateFile(t *testing.T) {
 		file.Close()
 
 		// Calling CreateFile on an existing file should return nil error
-		err = CreateFile(targetFile, false)
+		err = CreateFile(&config.Config{}, targetFile, false)
 		if err != nil {
 			t.Errorf("Expected nil error when file exists, but got: %v", err)
 		}
@@ -125,13 +146,1404 @@ func TestCreateFile(t *testing.T) {
 
 	t.Run("ErrorInvalidPath", func(t *testing.T) {
 		// Call CreateFile with an invalid path (empty string)
-		err := CreateFile("", false)
+		err := CreateFile(&config.Config{}, "", false)
 		if err == nil {
 			t.Errorf("Expected an error for invalid file path, got nil")
 		}
 	})
 }
 
+// TestWriteFile covers the WriteFile function by testing:
+// - Happy path: the file is created with wildcards replaced in its content
+// - Already exists: existing content is left untouched
+func TestWriteFile(t *testing.T) {
+	cfg := &config.Config{ProjectName: "acme"}
+
+	t.Run("HappyPath", func(t *testing.T) {
+		baseDir := t.TempDir()
+
+		targetFile := filepath.Join(baseDir, "go.mod")
+		err := WriteFile(cfg, targetFile, "module <main_package>\n", false)
+		if err != nil {
+			t.Errorf("Expected no error, got: %v", err)
+		}
+
+		data, err := os.ReadFile(targetFile)
+		if err != nil {
+			t.Fatalf("File does not exist after WriteFile: %v", err)
+		}
+		if string(data) != "module acme\n" {
+			t.Errorf("Expected wildcards to be replaced, got %q", data)
+		}
+	})
+
+	t.Run("AlreadyExists", func(t *testing.T) {
+		baseDir := t.TempDir()
+
+		targetFile := filepath.Join(baseDir, "go.mod")
+		if err := os.WriteFile(targetFile, []byte("original"), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+
+		err := WriteFile(cfg, targetFile, "replaced", false)
+		if err != nil {
+			t.Errorf("Expected nil error when file exists, but got: %v", err)
+		}
+
+		data, _ := os.ReadFile(targetFile)
+		if string(data) != "original" {
+			t.Errorf("Expected existing content to be kept, got %q", data)
+		}
+	})
+}
+
+// TestWriteFileConcurrent shares a Config between concurrent calls of the
+// exported helpers. Run with -race.
+func TestWriteFileConcurrent(t *testing.T) {
+	cfg := &config.Config{ProjectName: "acme", OutputDir: t.TempDir()}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			if err := WriteFile(cfg, fmt.Sprintf("key%d.txt", i), "<main_package> <secret_hex>", false); err != nil {
+				t.Errorf("Expected no error, got: %v", err)
+			}
+			if result := format.MatchWildCards(cfg, "<main_package>"); result != "acme" {
+				t.Errorf("Expected acme, got %q", result)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if cfg.RunBuiltins != nil {
+		t.Errorf("Expected the shared Config to be left unchanged")
+	}
+}
+
+// TestResolveVariables checks that defaults fill missing variables, supplied
+// values win and variables without a default are required.
+func TestResolveVariables(t *testing.T) {
+	jsonTemplate := &parsing.JSONTemplate{
+		Variables: map[string]parsing.Variable{
+			"module":  {Default: "github.com/example/<main_package>"},
+			"service": {Default: "Greeter"},
+		},
+	}
+
+	cfg := &config.Config{ProjectName: "acme", Variables: map[string]interface{}{"service": "Orders"}}
+
+	if err := ResolveVariables(cfg, jsonTemplate); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if cfg.Variables["module"] != "github.com/example/acme" {
+		t.Errorf("Expected module default to be expanded, got %v", cfg.Variables["module"])
+	}
+	if cfg.Variables["service"] != "Orders" {
+		t.Errorf("Expected supplied service to be kept, got %v", cfg.Variables["service"])
+	}
+
+	jsonTemplate.Variables["required"] = parsing.Variable{}
+	if err := ResolveVariables(cfg, jsonTemplate); err == nil {
+		t.Errorf("Expected an error for a required variable without value, got nil")
+	}
+}
+
+// TestResolveDerivedVariables checks that derived variables and defaults
+// referring to other variables are resolved in dependency order, and that
+// built-in variables are visible to them.
+func TestResolveDerivedVariables(t *testing.T) {
+	jsonTemplate := &parsing.JSONTemplate{
+		Variables: map[string]parsing.Variable{
+			"binary":  {Expr: `lower(replace(name, "-", "_"))`},
+			"name":    {Expr: "base(module)"},
+			"module":  {Default: "github.com/<org>/Order-Service"},
+			"org":     {Default: "acme"},
+			"license": {Default: "Copyright <year> <owner>"},
+			"owner":   {Expr: `org + " authors"`},
+		},
+	}
+
+	cfg := &config.Config{}
+	if err := ResolveVariables(cfg, jsonTemplate); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	expected := map[string]interface{}{
+		"module":  "github.com/acme/Order-Service",
+		"name":    "Order-Service",
+		"binary":  "order_service",
+		"license": fmt.Sprintf("Copyright %d acme authors", time.Now().Year()),
+	}
+	for name, value := range expected {
+		if cfg.Variables[name] != value {
+			t.Errorf("Expected %s to be %q, got %v", name, value, cfg.Variables[name])
+		}
+	}
+
+	jsonTemplate.Variables["org"] = parsing.Variable{Expr: "binary"}
+	err := ResolveVariables(&config.Config{}, jsonTemplate)
+	if err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Errorf("Expected a cycle error, got: %v", err)
+	}
+}
+
+// TestBuildPlanConditions checks that $if conditions are evaluated against
+// the variables and that skipped nodes are reported in the plan.
+func TestBuildPlanConditions(t *testing.T) {
+	jsonTemplate := &parsing.JSONTemplate{
+		Variables: map[string]parsing.Variable{
+			"with_websocket": {Default: true},
+			"deploy":         {Default: "none"},
+		},
+		Project: map[string]interface{}{
+			"websocket": map[string]interface{}{
+				parsing.AttrIf: "with_websocket",
+				"server.go":    "file",
+			},
+			"Dockerfile": map[string]interface{}{
+				parsing.AttrType: parsing.FileNode,
+				parsing.AttrIf:   `deploy == "k8s"`,
+			},
+			"main.go": parsing.NewFile("package main\n"),
+		},
+		Config: map[string]interface{}{"name": "svc"},
+	}
+
+	cfg := &config.Config{Variables: map[string]interface{}{"with_websocket": "false", "deploy": "k8s"}}
+	plan, err := BuildPlan(cfg, jsonTemplate)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	expected := []Action{
+		{Kind: ActionDir, Path: "svc/"},
+		{Kind: ActionFile, Path: "svc/Dockerfile"},
+		{Kind: ActionFile, Path: "svc/main.go", Content: "package main\n"},
+		{Kind: ActionSkip, Path: "svc/websocket/", Reason: `condition "with_websocket" is false`},
+	}
+	if len(plan.Actions) != len(expected) {
+		t.Fatalf("Expected %d actions, got %+v", len(expected), plan.Actions)
+	}
+	for i, action := range expected {
+		if !reflect.DeepEqual(plan.Actions[i], action) {
+			t.Errorf("Expected action %+v, got %+v", action, plan.Actions[i])
+		}
+	}
+}
+
+// TestBuildPlanRepeat checks that $each repeats a subtree once per list item,
+// with the loop variable available as a wildcard, and that collisions are
+// reported.
+func TestBuildPlanRepeat(t *testing.T) {
+	newTemplate := func(static string) *parsing.JSONTemplate {
+		return &parsing.JSONTemplate{
+			Variables: map[string]parsing.Variable{
+				"services": {Default: []interface{}{"api", "worker"}},
+			},
+			Project: map[string]interface{}{
+				"cmd": map[string]interface{}{
+					"<service>": map[string]interface{}{
+						parsing.AttrEach: "service in services",
+						"main.go":        parsing.NewFile("// <service>"),
+					},
+					static: map[string]interface{}{},
+				},
+			},
+			Config: map[string]interface{}{"name": "svc"},
+		}
+	}
+	cfg := &config.Config{Variables: map[string]interface{}{"services": "api,worker,migrator"}}
+	plan, err := BuildPlan(cfg, newTemplate("tools"))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	var files []string
+	for _, action := range plan.Actions {
+		if action.Kind == ActionFile {
+			files = append(files, action.Path+" "+action.Content)
+		}
+	}
+	expected := []string{
+		"svc/cmd/api/main.go // api",
+		"svc/cmd/worker/main.go // worker",
+		"svc/cmd/migrator/main.go // migrator",
+	}
+	if len(files) != len(expected) {
+		t.Fatalf("Expected files %v, got %v", expected, files)
+	}
+	for i := range expected {
+		if files[i] != expected[i] {
+			t.Errorf("Expected %s, got %s", expected[i], files[i])
+		}
+	}
+	if len(cfg.Variables) != 1 || cfg.Variables["services"] != "api,worker,migrator" {
+		t.Errorf("Expected BuildPlan to leave the variables of cfg unchanged, got %v", cfg.Variables)
+	}
+
+	cfg.Variables = map[string]interface{}{"services": "api,worker"}
+	if _, err := BuildPlan(cfg, newTemplate("worker")); err == nil {
+		t.Errorf("Expected a collision error between cmd/worker and the repeated node, got nil")
+	}
+
+	cfg.Variables = map[string]interface{}{"services": "api,api"}
+	if _, err := BuildPlan(cfg, newTemplate("tools")); err == nil {
+		t.Errorf("Expected a collision error for duplicate items, got nil")
+	}
+}
+
+// TestBootstrapApply generates a project in a temp dir and checks the result.
+func TestBootstrapApply(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	jsonTemplate := &parsing.JSONTemplate{
+		Project: map[string]interface{}{
+			"cmd": map[string]interface{}{
+				"<main_package>": map[string]interface{}{
+					"main.go": parsing.NewFile("package main // <main_package>\n"),
+				},
+			},
+		},
+		Config: map[string]interface{}{"name": "acme"},
+	}
+
+	if err := Bootstrap(&config.Config{}, jsonTemplate); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join("acme", "cmd", "acme", "main.go"))
+	if err != nil {
+		t.Fatalf("Expected main.go to be created: %v", err)
+	}
+	if string(data) != "package main // acme\n" {
+		t.Errorf("Expected wildcards to be replaced, got %q", data)
+	}
+}
+
+// TestBootstrapConcurrent runs several generations of one template at once,
+// each with its own variables and output directory, and checks that they do
+// not leak into each other. Run with -race.
+func TestBootstrapConcurrent(t *testing.T) {
+	jsonTemplate := &parsing.JSONTemplate{
+		Variables: map[string]parsing.Variable{
+			"service": {Default: "none"},
+			"binary":  {Expr: `"cmd-" + service`},
+		},
+		Project: map[string]interface{}{
+			"<binary>": map[string]interface{}{
+				"main.go": parsing.NewFile("// <main_package> <service>\n"),
+			},
+		},
+		Config: map[string]interface{}{"name": "svc"},
+	}
+
+	shared := &config.Config{Variables: map[string]interface{}{"service": "shared"}}
+
+	var wg sync.WaitGroup
+	dirs := make([]string, 8)
+	for i := range dirs {
+		dirs[i] = t.TempDir()
+
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			cfg := &config.Config{OutputDir: dirs[i], Variables: map[string]interface{}{"service": fmt.Sprint(i)}}
+			if err := Bootstrap(cfg, jsonTemplate); err != nil {
+				t.Errorf("Expected no error, got: %v", err)
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			if _, err := BuildPlan(shared, jsonTemplate); err != nil {
+				t.Errorf("Expected no error, got: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i, dir := range dirs {
+		data, err := os.ReadFile(filepath.Join(dir, "svc", fmt.Sprintf("cmd-%d", i), "main.go"))
+		if err != nil {
+			t.Fatalf("Expected main.go in the output directory of run %d: %v", i, err)
+		}
+		if expected := fmt.Sprintf("// svc %d\n", i); string(data) != expected {
+			t.Errorf("Expected %q, got %q", expected, data)
+		}
+	}
+}
+
+// TestBuildPlanName checks that the project name can be overridden and that
+// in place generation has no project folder.
+func TestBuildPlanName(t *testing.T) {
+	jsonTemplate := &parsing.JSONTemplate{
+		Project: map[string]interface{}{"<main_package>.go": parsing.NewFile("package <main_package>\n")},
+		Config:  map[string]interface{}{"name": "svc"},
+	}
+
+	tests := []struct {
+		cfg      *config.Config
+		root     string
+		expected []string
+	}{
+		{&config.Config{}, "svc/", []string{"svc/", "svc/svc.go"}},
+		{&config.Config{ProjectName: "api"}, "api/", []string{"api/", "api/api.go"}},
+		{&config.Config{ProjectName: "repo", InPlace: true}, "", []string{"repo.go"}},
+	}
+
+	for _, tt := range tests {
+		plan, err := BuildPlan(tt.cfg, jsonTemplate)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+
+		var paths []string
+		for _, action := range plan.Actions {
+			paths = append(paths, action.Path)
+		}
+		if plan.Root != tt.root || !reflect.DeepEqual(paths, tt.expected) {
+			t.Errorf("Expected root %q and %v, got %q and %v", tt.root, tt.expected, plan.Root, paths)
+		}
+	}
+
+	// config.name comes from the template, it may not be a string
+	jsonTemplate.Config = map[string]interface{}{"name": 42.0}
+	if _, err := BuildPlan(&config.Config{}, jsonTemplate); err == nil {
+		t.Errorf("Expected an error for a config.name that is not a string")
+	}
+}
+
+// TestApplyFS applies a plan to every FS implementation and checks what each
+// of them holds.
+func TestApplyFS(t *testing.T) {
+	plan := &Plan{Root: "svc/"}
+	plan.Dir("svc/")
+	plan.Dir("svc/cmd/")
+	plan.File("svc/cmd/main.go", "package main\n")
+	plan.Skip("svc/Dockerfile", "condition \"docker\" is false")
+
+	expected := &Result{
+		Created: []string{"svc/", "svc/cmd/", "svc/cmd/main.go"},
+		Skipped: []string{"svc/Dockerfile"},
+	}
+
+	t.Run("MemFS", func(t *testing.T) {
+		fsys := NewMemFS()
+		result, err := ApplyFS(context.Background(), fsys, &config.Config{}, plan)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if !reflect.DeepEqual(result, expected) {
+			t.Errorf("Expected %+v, got %+v", expected, result)
+		}
+		if data, _ := fsys.ReadFile("svc/cmd/main.go"); string(data) != "package main\n" {
+			t.Errorf("Expected main.go content, got %q", data)
+		}
+
+		// applying again keeps everything by default
+		result, _ = ApplyFS(context.Background(), fsys, &config.Config{}, plan)
+		if len(result.Created) != 0 || len(result.Skipped) != 4 {
+			t.Errorf("Expected everything to be kept, got %+v", result)
+		}
+
+		if err := fsys.WriteFile("missing/main.go", nil, 0644); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("Expected fs.ErrNotExist writing in a missing directory, got: %v", err)
+		}
+	})
+
+	t.Run("OSFS", func(t *testing.T) {
+		root := t.TempDir()
+		result, err := ApplyFS(context.Background(), NewOSFS(root), &config.Config{}, plan)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if !reflect.DeepEqual(result, expected) {
+			t.Errorf("Expected %+v, got %+v", expected, result)
+		}
+		if _, err := os.Stat(filepath.Join(root, "svc", "cmd", "main.go")); err != nil {
+			t.Errorf("Expected main.go under the root: %v", err)
+		}
+
+		if err := NewOSFS(root).WriteFile("../escape", nil, 0644); err == nil {
+			t.Errorf("Expected an error writing outside of the root, got nil")
+		}
+
+		// symbolic links cannot lead outside of the root either
+		outside := t.TempDir()
+		if err := os.Symlink(outside, filepath.Join(root, "link")); err != nil {
+			t.Fatalf("Failed to create the link: %v", err)
+		}
+		if err := NewOSFS(root).WriteFile("link/escape", nil, 0644); err == nil {
+			t.Errorf("Expected an error writing through a link leaving the root, got nil")
+		}
+		if _, err := os.Stat(filepath.Join(outside, "escape")); !os.IsNotExist(err) {
+			t.Errorf("Expected nothing to be written outside of the root, got: %v", err)
+		}
+
+		// and escaping paths fail the generation rather than being kept
+		escaping := &Plan{}
+		escaping.File("link/escape", "x")
+		if _, err := ApplyFS(context.Background(), NewOSFS(root), &config.Config{}, escaping); err == nil {
+			t.Errorf("Expected an error applying a plan leaving the root, got nil")
+		}
+
+		t.Chdir(root)
+		for _, name := range []string{"../escape", "/tmp/escape"} {
+			if err := NewOSFS("").WriteFile(name, nil, 0644); err == nil {
+				t.Errorf("Expected an error writing %s outside of the working directory, got nil", name)
+			}
+		}
+	})
+
+	for _, archive := range []struct {
+		name string
+		open func(w io.Writer) *ArchiveFS
+		list func(data []byte) ([]string, error)
+	}{
+		{"TarGz", NewTarGzFS, listTarGz},
+		{"Zip", NewZipFS, listZip},
+	} {
+		t.Run(archive.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			fsys := archive.open(&buf)
+
+			if _, err := ApplyFS(context.Background(), fsys, &config.Config{}, plan); err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if err := fsys.WriteFile("svc/cmd/main.go", nil, 0644); !errors.Is(err, fs.ErrExist) {
+				t.Errorf("Expected fs.ErrExist overwriting an entry, got: %v", err)
+			}
+			if err := fsys.Close(); err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+
+			entries, err := archive.list(buf.Bytes())
+			if err != nil {
+				t.Fatalf("Expected a valid archive, got: %v", err)
+			}
+			written := append(append([]string{}, expected.Created...), "svc/.go-bootstrap/", "svc/.go-bootstrap/manifest.json")
+			if !reflect.DeepEqual(entries, written) {
+				t.Errorf("Expected entries %v, got %v", written, entries)
+			}
+
+			// archives are reproducible
+			var again bytes.Buffer
+			fsys = archive.open(&again)
+			ApplyFS(context.Background(), fsys, &config.Config{}, plan)
+			fsys.Close()
+			if !bytes.Equal(buf.Bytes(), again.Bytes()) {
+				t.Errorf("Expected identical archives for the same plan")
+			}
+		})
+	}
+}
+
+// TestManifest checks that a generation records its template, its public
+// variables and a checksum of every file it wrote.
+func TestManifest(t *testing.T) {
+	jsonTemplate := &parsing.JSONTemplate{
+		Version: "1.2.0",
+		Source:  "cli",
+		Project: map[string]interface{}{
+			"cmd": map[string]interface{}{
+				"main.go": map[string]interface{}{"$type": "file", "$content": "package main\n"},
+			},
+		},
+		Config: map[string]interface{}{"name": "svc"},
+		Variables: map[string]parsing.Variable{
+			"port":  {Default: "8080"},
+			"token": {Default: "s3cret", Secret: true},
+			"key":   {Expr: "random_hex(8)"},
+		},
+	}
+
+	plan, err := BuildPlan(&config.Config{}, jsonTemplate)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	fsys := NewMemFS()
+	if _, err := ApplyFS(context.Background(), fsys, &config.Config{}, plan); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	data, err := fsys.ReadFile("svc/" + ManifestPath)
+	if err != nil {
+		t.Fatalf("Expected a manifest, got: %v", err)
+	}
+	manifest, err := ParseManifest(data)
+	if err != nil {
+		t.Fatalf("Expected a valid manifest, got: %v", err)
+	}
+
+	if manifest.Name != "svc" || manifest.Template.Source != "cli" || manifest.Template.Version != "1.2.0" || manifest.Version != config.VERSION {
+		t.Errorf("Unexpected manifest header: %+v", manifest)
+	}
+	if manifest.Variables["port"] != "8080" {
+		t.Errorf("Expected port to be recorded, got %v", manifest.Variables)
+	}
+	for _, name := range []string{"token", "key"} {
+		if _, exists := manifest.Variables[name]; exists {
+			t.Errorf("Expected %s not to be recorded", name)
+		}
+	}
+
+	expected := []ManifestEntry{
+		{Path: "cmd", Kind: ActionDir, Mode: "0755"},
+		{Path: "cmd/main.go", Kind: ActionFile, Mode: "0644", SHA256: Checksum([]byte("package main\n"))},
+	}
+	if !reflect.DeepEqual(manifest.Entries, expected) {
+		t.Errorf("Expected entries %+v, got %+v", expected, manifest.Entries)
+	}
+
+	// kept files remain in the manifest of a later generation
+	if _, err := ApplyFS(context.Background(), fsys, &config.Config{}, plan); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	data, _ = fsys.ReadFile("svc/" + ManifestPath)
+	if manifest, _ = ParseManifest(data); !reflect.DeepEqual(manifest.Entries, expected) {
+		t.Errorf("Expected entries to be kept, got %+v", manifest.Entries)
+	}
+}
+
+// TestManifestSecrets checks that secrets, and the variables derived from
+// them, never reach the manifest.
+func TestManifestSecrets(t *testing.T) {
+	secrets := []string{"hex", "base64", "key", "derived", "dsn", "token", "header"}
+	project := map[string]interface{}{}
+	for _, name := range secrets {
+		project[name] = map[string]interface{}{"$type": "file", "$content": "<" + name + ">"}
+	}
+
+	jsonTemplate := &parsing.JSONTemplate{
+		Project: project,
+		Config:  map[string]interface{}{"name": "svc"},
+		Variables: map[string]parsing.Variable{
+			"port":    {Default: "8080"},
+			"url":     {Default: "http://localhost:<port>"},
+			"hex":     {Expr: "secret_hex"},
+			"base64":  {Default: "<secret_base64>"},
+			"key":     {Expr: "random_hex(8)"},
+			"derived": {Expr: "upper(key)"},
+			"dsn":     {Default: "postgres://app:<derived>@db"},
+			"token":   {Default: "s3cret", Secret: true},
+			"header":  {Expr: "lower(token)"},
+		},
+	}
+
+	cfg := &config.Config{}
+	plan, err := BuildPlan(cfg, jsonTemplate)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	fsys := NewMemFS()
+	if _, err := ApplyFS(context.Background(), fsys, cfg, plan); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	data, err := fsys.ReadFile("svc/" + ManifestPath)
+	if err != nil {
+		t.Fatalf("Expected a manifest, got: %v", err)
+	}
+	manifest, _ := ParseManifest(data)
+
+	if manifest.Variables["url"] != "http://localhost:8080" {
+		t.Errorf("Expected url to be recorded, got %v", manifest.Variables)
+	}
+	for _, name := range secrets {
+		if _, exists := manifest.Variables[name]; exists {
+			t.Errorf("Expected %s not to be recorded", name)
+		}
+		value, _ := fsys.ReadFile("svc/" + name)
+		if len(value) == 0 || bytes.Contains(data, value) {
+			t.Errorf("Expected the value of %s (%q) not to appear in the manifest", name, value)
+		}
+	}
+}
+
+// TestPlanUpdate generates a project, edits it and updates it to a newer
+// template, checking every kind of change.
+func TestPlanUpdate(t *testing.T) {
+	template := func(files map[string]string) *parsing.JSONTemplate {
+		project := map[string]interface{}{"docs": map[string]interface{}{}}
+		for name, content := range files {
+			project[name] = map[string]interface{}{"$type": "file", "$content": content}
+		}
+		return &parsing.JSONTemplate{Project: project, Config: map[string]interface{}{"name": "svc"}}
+	}
+
+	base := template(map[string]string{
+		"Makefile":  "build:\n\tgo build\n\ntest:\n\tgo test\n",
+		"main.go":   "package main\n",
+		"notes.txt": "notes\n",
+		"old.txt":   "old\n",
+		"gone.txt":  "gone\n",
+		"port.txt":  "80\n",
+	})
+	next := template(map[string]string{
+		"Makefile":  "build:\n\tgo build\n\ntest:\n\tgo test -race\n",
+		"main.go":   "package main\n\nfunc main() {}\n",
+		"notes.txt": "new notes\n",
+		"gone.txt":  "still gone\n",
+		"new.txt":   "new\n",
+		"port.txt":  "80\n",
+	})
+
+	fsys := NewMemFS()
+	plan, _ := BuildPlan(&config.Config{InPlace: true}, base)
+	if _, err := ApplyFS(context.Background(), fsys, &config.Config{}, plan); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	fsys.WriteFile("Makefile", []byte("build:\n\tgo build -v\n\ntest:\n\tgo test\n"), 0644)
+	fsys.WriteFile("main.go", []byte("package app\n"), 0644)
+	fsys.Remove("gone.txt")
+	fsys.WriteFile("port.txt", []byte("8080\n"), 0644)
+
+	manifest, err := ReadManifest(fsys)
+	if err != nil {
+		t.Fatalf("Expected a manifest, got: %v", err)
+	}
+
+	update, err := PlanUpdate(fsys, &config.Config{}, manifest, base, next)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	kinds := make(map[string]UpdateKind)
+	for _, action := range update.Actions {
+		kinds[action.Path] = action.Kind
+	}
+	expected := map[string]UpdateKind{
+		"Makefile":  UpdateMerge,
+		"main.go":   UpdateConflict,
+		"notes.txt": UpdateReplace,
+		"old.txt":   UpdateRemove,
+		"gone.txt":  UpdateKeep,
+		"new.txt":   UpdateAdd,
+		"port.txt":  UpdateKeep,
+	}
+	if !reflect.DeepEqual(kinds, expected) {
+		t.Fatalf("Expected %v, got %v", expected, kinds)
+	}
+
+	err = update.Apply(fsys, true)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	contents := map[string]string{
+		"Makefile":    "build:\n\tgo build -v\n\ntest:\n\tgo test -race\n",
+		"main.go":     "package app\n",
+		"main.go.rej": "@@ line 1 @@\n<<<<<<< current\npackage app\n=======\npackage main\n\nfunc main() {}\n>>>>>>> template\n",
+		"notes.txt":   "new notes\n",
+		"new.txt":     "new\n",
+		"port.txt":    "8080\n",
+	}
+	for name, content := range contents {
+		if data, _ := fsys.ReadFile(name); string(data) != content {
+			t.Errorf("Expected %s to hold %q, got %q", name, content, data)
+		}
+	}
+	for _, name := range []string{"old.txt", "gone.txt"} {
+		if found, _ := exists(fsys, name); found {
+			t.Errorf("Expected %s not to exist", name)
+		}
+	}
+
+	manifest, _ = ReadManifest(fsys)
+	if entry, _ := manifest.Entry("notes.txt"); entry.SHA256 != Checksum([]byte("new notes\n")) {
+		t.Errorf("Expected the manifest to record the newer template, got %+v", entry)
+	}
+
+	// without the original template, only unchanged files are updated
+	update, _ = PlanUpdate(fsys, &config.Config{}, manifest, nil, template(map[string]string{
+		"Makefile":  "all: build\n",
+		"notes.txt": "newer notes\n",
+	}))
+	kinds = make(map[string]UpdateKind)
+	for _, action := range update.Actions {
+		kinds[action.Path] = action.Kind
+	}
+	if kinds["Makefile"] != UpdateConflict || kinds["notes.txt"] != UpdateReplace {
+		t.Errorf("Expected a conflict on Makefile and an update of notes.txt, got %v", kinds)
+	}
+}
+
+// TestPlanUpdateRunValues updates a project to the same template, which uses
+// secrets, dates and random variables, and checks that nothing changes.
+func TestPlanUpdateRunValues(t *testing.T) {
+	files := map[string]string{
+		".env":       "A=<secret_hex>\nB=<secret_base64>\n",
+		"LICENSE":    "Copyright <year>, <date>\n",
+		"key.txt":    "<key> <upper_key>\n",
+		"edited.env": "C=<secret_hex>\n",
+	}
+	project := make(map[string]interface{})
+	for name, content := range files {
+		project[name] = map[string]interface{}{"$type": "file", "$content": content}
+	}
+	jsonTemplate := &parsing.JSONTemplate{
+		Project: project,
+		Config:  map[string]interface{}{"name": "svc"},
+		Variables: map[string]parsing.Variable{
+			"key":       {Expr: "random_hex(8)"},
+			"upper_key": {Expr: "upper(key)"},
+		},
+	}
+
+	fsys := NewMemFS()
+	plan, _ := BuildPlan(&config.Config{InPlace: true}, jsonTemplate)
+	if _, err := ApplyFS(context.Background(), fsys, &config.Config{}, plan); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	fsys.WriteFile("edited.env", []byte("C=mine\n"), 0644)
+
+	contents := make(map[string]string)
+	for name := range files {
+		data, _ := fsys.ReadFile(name)
+		contents[name] = string(data)
+	}
+	recorded, _ := fsys.ReadFile(ManifestPath)
+
+	for i := 0; i < 2; i++ {
+		manifest, _ := ReadManifest(fsys)
+		update, err := PlanUpdate(fsys, &config.Config{}, manifest, jsonTemplate, jsonTemplate)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		expected := []UpdateAction{{Kind: UpdateKeep, Path: "edited.env", Reason: "modified locally, unchanged in the template"}}
+		if !reflect.DeepEqual(update.Actions, expected) {
+			t.Errorf("Expected only the edited file to be kept, got %+v", update.Actions)
+		}
+		if err := update.Apply(fsys, false); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+	}
+
+	for name, content := range contents {
+		if data, _ := fsys.ReadFile(name); string(data) != content {
+			t.Errorf("Expected %s to be left as %q, got %q", name, content, data)
+		}
+	}
+	if data, _ := fsys.ReadFile(ManifestPath); !bytes.Equal(data, recorded) {
+		t.Errorf("Expected the manifest to be left as it was, got:\n%s", data)
+	}
+
+	// secrets are not recorded, so required ones must be given again
+	jsonTemplate.Variables["token"] = parsing.Variable{Secret: true}
+	manifest, _ := ReadManifest(fsys)
+	_, err := PlanUpdate(fsys, &config.Config{}, manifest, jsonTemplate, jsonTemplate)
+	if err == nil || !strings.Contains(err.Error(), "not recorded in the manifest") {
+		t.Errorf("Expected an error explaining that secrets are not recorded, got: %v", err)
+	}
+}
+
+// TestCheck compares a project with its template and checks every status
+// and report format.
+func TestCheck(t *testing.T) {
+	jsonTemplate := &parsing.JSONTemplate{
+		Source: "platform.json",
+		Project: map[string]interface{}{
+			"Makefile":  map[string]interface{}{"$type": "file", "$content": "build:\n"},
+			"README.md": map[string]interface{}{"$type": "file", "$content": "# <main_package>\n"},
+			"cmd":       map[string]interface{}{"main.go": parsing.NewFile("package main\n")},
+			"deploy":    map[string]interface{}{"$if": "docker"},
+		},
+		Config:    map[string]interface{}{"name": "svc"},
+		Variables: map[string]parsing.Variable{"docker": {Default: false}},
+	}
+
+	project := fstest.MapFS{
+		"Makefile":         {Data: []byte("build:\n\tgo build\n")},
+		"README.md":        {Data: []byte("# api\n")},
+		"cmd/main.go":      {Data: []byte("package main\n")},
+		"notes.txt":        {Data: []byte("extra\n")},
+		".git/HEAD":        {Data: []byte("ref: refs/heads/main\n")},
+		".go-bootstrap/m":  {Data: []byte("{}")},
+		"deploy/Chart.yml": {Data: []byte("skipped by the template\n")},
+	}
+
+	report, err := Check(project, &config.Config{ProjectName: "api"}, jsonTemplate)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	expected := []CheckEntry{
+		{Path: "Makefile", Kind: ActionFile, Status: CheckModified},
+		{Path: "notes.txt", Kind: ActionFile, Status: CheckExtra},
+	}
+	if !reflect.DeepEqual(report.Drift(), expected) {
+		t.Errorf("Expected drift %+v, got %+v", expected, report.Drift())
+	}
+
+	delete(project, "cmd/main.go")
+	project["cmd/app.go"] = &fstest.MapFile{Data: []byte("package main\n")}
+	report, _ = Check(project, &config.Config{ProjectName: "api"}, jsonTemplate)
+	if drift := report.Drift(); len(drift) != 3 || drift[1].Path != "cmd/main.go" || drift[1].Status != CheckMissing {
+		t.Errorf("Expected cmd/main.go to be missing, got %+v", drift)
+	}
+
+	var buf bytes.Buffer
+	if err := report.WriteJUnit(&buf); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	for _, s := range []string{`tests="5" failures="3"`, `<failure type="missing" message="cmd/main.go is required by the template and missing">`} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("Expected the JUnit report to contain %s, got:\n%s", s, buf.String())
+		}
+	}
+
+	buf.Reset()
+	if err := report.WriteJSON(&buf); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	var decoded CheckReport
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil || !reflect.DeepEqual(&decoded, report) {
+		t.Errorf("Expected the JSON report to decode to the report, got %+v (%v)", decoded, err)
+	}
+
+	// generated projects are checked against the recorded checksums, as
+	// secrets render differently on every run
+	jsonTemplate = &parsing.JSONTemplate{
+		Project: map[string]interface{}{
+			".env":      map[string]interface{}{"$type": "file", "$content": "KEY=<secret_hex>\n"},
+			"README.md": map[string]interface{}{"$type": "file", "$content": "# <main_package>\n"},
+		},
+		Config: map[string]interface{}{"name": "svc"},
+	}
+	root := t.TempDir()
+	plan, _ := BuildPlan(&config.Config{InPlace: true}, jsonTemplate)
+	if _, err := ApplyFS(context.Background(), NewOSFS(root), &config.Config{}, plan); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	report, err = Check(os.DirFS(root), &config.Config{ProjectName: "svc"}, jsonTemplate)
+	if err != nil || len(report.Drift()) != 0 {
+		t.Errorf("Expected the generated project to match, got %+v (%v)", report, err)
+	}
+
+	os.WriteFile(filepath.Join(root, "README.md"), []byte("# svc\n\nEdited.\n"), 0644)
+	report, _ = Check(os.DirFS(root), &config.Config{ProjectName: "svc"}, jsonTemplate)
+	expected = []CheckEntry{{Path: "README.md", Kind: ActionFile, Status: CheckModified}}
+	if !reflect.DeepEqual(report.Drift(), expected) {
+		t.Errorf("Expected drift %+v, got %+v", expected, report.Drift())
+	}
+}
+
+// TestUndo removes a generated project, keeping modified files and the
+// paths it did not create unless forced.
+func TestUndo(t *testing.T) {
+	plan := &Plan{}
+	plan.Dir("cmd/")
+	plan.File("cmd/main.go", "package main\n")
+	plan.File("README.md", "# svc\n")
+
+	fsys := NewMemFS()
+	if _, err := ApplyFS(context.Background(), fsys, &config.Config{}, plan); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	fsys.WriteFile("README.md", []byte("# svc, edited\n"), 0644)
+	fsys.WriteFile("notes.txt", []byte("mine\n"), 0644)
+
+	manifest, _ := ReadManifest(fsys)
+	result, err := Undo(fsys, manifest, false)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	expected := &UndoResult{Removed: []string{"cmd/main.go", "cmd/"}, Modified: []string{"README.md"}}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, result)
+	}
+	if paths := fsys.Paths(); !reflect.DeepEqual(paths, []string{".go-bootstrap/", ".go-bootstrap/manifest.json", "README.md", "notes.txt"}) {
+		t.Errorf("Expected the modified file and the manifest to be kept, got %v", paths)
+	}
+
+	result, _ = Undo(fsys, manifest, true)
+	if !reflect.DeepEqual(result.Removed, []string{"README.md"}) {
+		t.Errorf("Expected -force to remove README.md, got %+v", result)
+	}
+	if paths := fsys.Paths(); !reflect.DeepEqual(paths, []string{"notes.txt"}) {
+		t.Errorf("Expected only notes.txt to be left, got %v", paths)
+	}
+}
+
+// TestCompare compares a directory with a plan and checks the report and its
+// outputs.
+func TestCompare(t *testing.T) {
+	plan := &Plan{}
+	plan.Dir("cmd/")
+	plan.File("cmd/main.go", "package main\n")
+	plan.File("Makefile", "build:\n\tgo build\n")
+	plan.File("README.md", "# svc\n")
+	plan.File("LICENSE", "")
+	plan.Dir("docs/")
+
+	dir := fstest.MapFS{
+		"cmd/main.go":   {Data: []byte("package main\n")},
+		"cmd/helper.go": {Data: []byte("package main\n")},
+		"Makefile":      {Data: []byte("build:\n\tgo build -v\n")},
+		"LICENSE":       {Data: []byte("MIT\n")},
+		"vendor/x.go":   {Data: []byte("package x\n")},
+	}
+
+	report, err := Compare(dir, plan)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	var statuses []string
+	for _, file := range report.Files {
+		statuses = append(statuses, fmt.Sprintf("%s %s", file.Status, file.Path))
+	}
+	expected := []string{"modified Makefile", "added README.md", "removed cmd/helper.go", "added docs/"}
+	if !reflect.DeepEqual(statuses, expected) {
+		t.Errorf("Expected %v, got %v", expected, statuses)
+	}
+
+	var buf bytes.Buffer
+	report.Print(&buf)
+	expectedOutput := "added README.md\nadded docs/\nremoved cmd/helper.go\n" +
+		"--- a/Makefile\n+++ b/Makefile\n@@ -1,2 +1,2 @@\n build:\n-\tgo build -v\n+\tgo build\n"
+	if buf.String() != expectedOutput {
+		t.Errorf("Expected %q, got %q", expectedOutput, buf.String())
+	}
+
+	buf.Reset()
+	report.PrintStat(&buf)
+	if !strings.Contains(buf.String(), "4 files changed, 2 insertions(+), 2 deletions(-)") {
+		t.Errorf("Expected the totals in the summary, got:\n%s", buf.String())
+	}
+}
+
+// TestBuildGeneratorPlan renders a generator into a generated project and
+// checks that its files are added to the manifest of the project.
+func TestBuildGeneratorPlan(t *testing.T) {
+	jsonTemplate := &parsing.JSONTemplate{
+		Project: map[string]interface{}{"http": map[string]interface{}{"routes.go": parsing.NewFile("package http\n")}},
+		Config:  map[string]interface{}{"name": "svc"},
+		Generators: map[string]parsing.Generator{
+			"handler": {
+				Variables: map[string]parsing.Variable{
+					"name": {},
+					"type": {Expr: "pascal(name)"},
+				},
+				Project: map[string]interface{}{
+					"http": map[string]interface{}{
+						"<name>_handler.go": map[string]interface{}{"$type": "file", "$content": "func <type>Handler() {} // <main_package>\n"},
+					},
+				},
+			},
+		},
+	}
+
+	fsys := NewMemFS()
+	plan, _ := BuildPlan(&config.Config{ProjectName: "shop", InPlace: true}, jsonTemplate)
+	ApplyFS(context.Background(), fsys, &config.Config{}, plan)
+
+	_, err := BuildGeneratorPlan(&config.Config{}, jsonTemplate, "model")
+	if err == nil || !strings.Contains(err.Error(), "available generators: handler") {
+		t.Errorf("Expected an unknown generator error, got: %v", err)
+	}
+	_, err = BuildGeneratorPlan(&config.Config{}, jsonTemplate, "handler")
+	if err == nil || !strings.Contains(err.Error(), "generator handler") {
+		t.Errorf("Expected a missing variable error, got: %v", err)
+	}
+
+	cfg := &config.Config{ProjectName: "shop", Variables: map[string]interface{}{"name": "order_items"}}
+	plan, err = BuildGeneratorPlan(cfg, jsonTemplate, "handler")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if _, err := ApplyFS(context.Background(), fsys, cfg, plan); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if data, _ := fsys.ReadFile("http/order_items_handler.go"); string(data) != "func OrderItemsHandler() {} // shop\n" {
+		t.Errorf("Expected the rendered handler, got %q", data)
+	}
+
+	manifest, _ := ReadManifest(fsys)
+	var paths []string
+	for _, entry := range manifest.Entries {
+		paths = append(paths, entry.Path)
+	}
+	expected := []string{"http", "http/routes.go", "http/order_items_handler.go"}
+	if !reflect.DeepEqual(paths, expected) || manifest.Variables["name"] != nil {
+		t.Errorf("Expected the manifest of the project with %v, got %+v", expected, manifest)
+	}
+}
+
+// TestGeneratorUpdate adds paths with a generator, then updates and undoes the
+// project: the generated paths are recorded as such, kept by the update and
+// removed by the undo.
+func TestGeneratorUpdate(t *testing.T) {
+	jsonTemplate := &parsing.JSONTemplate{
+		Project: map[string]interface{}{"http": map[string]interface{}{"routes.go": parsing.NewFile("package http\n")}},
+		Config:  map[string]interface{}{"name": "svc"},
+		Generators: map[string]parsing.Generator{
+			"handler": {
+				Variables: map[string]parsing.Variable{"name": {}},
+				Project: map[string]interface{}{
+					"http": map[string]interface{}{
+						"<name>": map[string]interface{}{
+							"handler.go": map[string]interface{}{"$type": "file", "$content": "package <name>\n"},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	fsys := NewMemFS()
+	plan, _ := BuildPlan(&config.Config{ProjectName: "shop", InPlace: true}, jsonTemplate)
+	ApplyFS(context.Background(), fsys, &config.Config{}, plan)
+
+	cfg := &config.Config{ProjectName: "shop", Variables: map[string]interface{}{"name": "orders"}}
+	plan, err := BuildGeneratorPlan(cfg, jsonTemplate, "handler")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if _, err := ApplyFS(context.Background(), fsys, cfg, plan); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	generated := func(manifest *Manifest) map[string]string {
+		entries := make(map[string]string)
+		for _, entry := range manifest.Entries {
+			entries[entry.Path] = entry.Generator
+		}
+		return entries
+	}
+	expected := map[string]string{"http": "", "http/routes.go": "", "http/orders": "handler", "http/orders/handler.go": "handler"}
+
+	manifest, _ := ReadManifest(fsys)
+	if entries := generated(manifest); !reflect.DeepEqual(entries, expected) {
+		t.Fatalf("Expected the entries %v, got %v", expected, entries)
+	}
+
+	update, err := PlanUpdate(fsys, &config.Config{}, manifest, jsonTemplate, jsonTemplate)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(update.Actions) != 0 || len(update.prune) != 0 {
+		t.Errorf("Expected nothing to update, got %+v, pruning %v", update.Actions, update.prune)
+	}
+	if err := update.Apply(fsys, false); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if data, _ := fsys.ReadFile("http/orders/handler.go"); string(data) != "package orders\n" {
+		t.Errorf("Expected the handler to be kept, got %q", data)
+	}
+	manifest, _ = ReadManifest(fsys)
+	if entries := generated(manifest); !reflect.DeepEqual(entries, expected) {
+		t.Errorf("Expected the update to keep the entries %v, got %v", expected, entries)
+	}
+
+	if _, err := Undo(fsys, manifest, false); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if paths := fsys.Paths(); len(paths) != 0 {
+		t.Errorf("Expected undo to remove every path, got %v", paths)
+	}
+}
+
+// TestInject covers every injection position, idempotency, a missing marker
+// and injections breaking Go syntax.
+func TestInject(t *testing.T) {
+	content := "func routes() {\n\t// go-bootstrap:routes\n}"
+
+	tests := []struct {
+		position InjectPosition
+		marker   string
+		snippet  string
+		expected string
+		injected bool
+	}{
+		{InjectBefore, "go-bootstrap:routes", "\tregister()", "func routes() {\n\tregister()\n\t// go-bootstrap:routes\n}", true},
+		{InjectAfter, "go-bootstrap:routes", "\tregister()\n", "func routes() {\n\t// go-bootstrap:routes\n\tregister()\n}", true},
+		{InjectAfter, "}", "// end\n", "func routes() {\n\t// go-bootstrap:routes\n}\n// end\n", true},
+		{InjectEnd, "", "// end", "func routes() {\n\t// go-bootstrap:routes\n}\n// end\n", true},
+		{InjectBefore, "go-bootstrap:routes", "func routes() {", content, false},
+	}
+
+	for _, tt := range tests {
+		result, injected, err := inject(content, tt.snippet, tt.position, tt.marker)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if result != tt.expected || injected != tt.injected {
+			t.Errorf("Injecting %q %s %q: expected %q (%v), got %q (%v)", tt.snippet, tt.position, tt.marker, tt.expected, tt.injected, result, injected)
+		}
+	}
+
+	if _, _, err := inject(content, "x", InjectBefore, "go-bootstrap:missing"); err == nil {
+		t.Errorf("Expected an error for a missing marker, got nil")
+	}
+
+	// applying twice injects once
+	plan := &Plan{}
+	plan.Inject("routes.go", "\tregister()\n", InjectBefore, "go-bootstrap:routes")
+
+	fsys := NewMemFS()
+	fsys.WriteFile("routes.go", []byte(content), 0644)
+	for _, expected := range []*Result{{Injected: []string{"routes.go"}}, {Skipped: []string{"routes.go"}}} {
+		result, err := ApplyFS(context.Background(), fsys, &config.Config{}, plan)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if !reflect.DeepEqual(result, expected) {
+			t.Errorf("Expected %+v, got %+v", expected, result)
+		}
+	}
+	if data, _ := fsys.ReadFile("routes.go"); strings.Count(string(data), "register()") != 1 {
+		t.Errorf("Expected a single injection, got %q", data)
+	}
+
+	// injections breaking Go files are refused
+	plan = &Plan{}
+	plan.Inject("routes.go", "\tregister(\n", InjectBefore, "go-bootstrap:routes")
+	_, err := ApplyFS(context.Background(), fsys, &config.Config{}, plan)
+	if err == nil || !strings.Contains(err.Error(), "Invalid Go source after injecting into routes.go") {
+		t.Errorf("Expected a syntax error, got: %v", err)
+	}
+}
+
+// TestEditFile checks that Go edits are applied once, with their wildcards
+// matched, and that a missing function is an error naming the file.
+func TestEditFile(t *testing.T) {
+	content := "package http\n\nfunc Routes() {\n\tmux := newMux()\n\treturn mux\n}\n"
+
+	plan := &Plan{}
+	cfg := &config.Config{Variables: map[string]interface{}{"type": "Orders"}}
+	plan.Edit("routes.go", []goedit.Edit{
+		matchEdit(cfg, goedit.Edit{Import: "example.com/<type>"}),
+		matchEdit(cfg, goedit.Edit{Func: "Routes", Statement: "register<type>Routes(mux)"}),
+	})
+
+	fsys := NewMemFS()
+	fsys.WriteFile("routes.go", []byte(content), 0644)
+	for _, expected := range []*Result{{Injected: []string{"routes.go"}}, {Skipped: []string{"routes.go"}}} {
+		result, err := ApplyFS(context.Background(), fsys, cfg, plan)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if !reflect.DeepEqual(result, expected) {
+			t.Errorf("Expected %+v, got %+v", expected, result)
+		}
+	}
+
+	data, _ := fsys.ReadFile("routes.go")
+	expected := "package http\n\nimport \"example.com/Orders\"\n\nfunc Routes() {\n\tmux := newMux()\n\tregisterOrdersRoutes(mux)\n\treturn mux\n}\n"
+	if string(data) != expected {
+		t.Errorf("Expected %q, got %q", expected, data)
+	}
+
+	plan = &Plan{}
+	plan.Edit("routes.go", []goedit.Edit{{Func: "Handlers", Statement: "x()"}})
+	_, err := ApplyFS(context.Background(), fsys, cfg, plan)
+	if err == nil || !strings.Contains(err.Error(), "routes.go") {
+		t.Errorf("Expected an error editing a missing function of routes.go, got: %v", err)
+	}
+}
+
+// TestBuildPlanNames checks that names leaving their directory are refused
+// once wildcards and $each are expanded, before anything is written.
+func TestBuildPlanNames(t *testing.T) {
+	tests := []struct {
+		project   map[string]interface{}
+		variables map[string]interface{}
+	}{
+		{map[string]interface{}{"../pwned.txt": "file"}, nil},
+		{map[string]interface{}{"<name>.go": "file"}, map[string]interface{}{"name": "../../pwned"}},
+		{map[string]interface{}{"<name>": map[string]interface{}{"x": "file"}}, map[string]interface{}{"name": ".."}},
+		{map[string]interface{}{"<name>": "file"}, map[string]interface{}{"name": "/etc/pwned"}},
+		{map[string]interface{}{"<item>": map[string]interface{}{parsing.AttrType: parsing.FileNode, parsing.AttrEach: "item in items"}}, map[string]interface{}{"items": []interface{}{"ok", "a/b"}}},
+	}
+
+	for _, tt := range tests {
+		jsonTemplate := &parsing.JSONTemplate{Project: tt.project, Config: map[string]interface{}{"name": "svc"}}
+		_, err := BuildPlan(&config.Config{Variables: tt.variables}, jsonTemplate)
+		if err == nil || !strings.Contains(err.Error(), "expected a single path element") {
+			t.Errorf("%v with %v: expected an invalid name error, got: %v", tt.project, tt.variables, err)
+		}
+	}
+}
+
+// TestBuildPlanSecrets checks that the files of a plan share its secrets and
+// that every plan built from the same Config draws new ones.
+func TestBuildPlanSecrets(t *testing.T) {
+	jsonTemplate := &parsing.JSONTemplate{
+		Project: map[string]interface{}{
+			".env":      parsing.NewFile("KEY=<secret_hex>"),
+			"copy.env":  parsing.NewFile("KEY=<secret_hex>"),
+			"README.md": parsing.NewFile("<date>"),
+		},
+		Config: map[string]interface{}{"name": "svc"},
+	}
+
+	cfg := &config.Config{}
+	contents := make(map[string]bool)
+	for i := 0; i < 2; i++ {
+		plan, err := BuildPlan(cfg, jsonTemplate)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+
+		env, copied := plan.Actions[1].Content, plan.Actions[3].Content
+		if env != copied || len(env) != len("KEY=")+64 {
+			t.Errorf("Expected both files to hold the same secret, got %q and %q", env, copied)
+		}
+		contents[env] = true
+	}
+
+	if len(contents) != 2 {
+		t.Errorf("Expected two plans to get different secrets, got %v", contents)
+	}
+	if cfg.RunBuiltins != nil {
+		t.Errorf("Expected the Config of the caller to be left unchanged")
+	}
+}
+
+// TestBuildPlanGoSource checks that generated Go files are gofmt-ed and that
+// a syntax error names the file, the template node and the rendered line, as
+// does an empty Go file.
+func TestBuildPlanGoSource(t *testing.T) {
+	jsonTemplate := &parsing.JSONTemplate{
+		Project: map[string]interface{}{
+			"cmd": map[string]interface{}{
+				"<name>.go": parsing.NewFile("package main\nfunc main()  {\n\tprintln( \"<name>\" )\n}"),
+			},
+			"README.md":  parsing.NewFile("func  x"),
+			"broken.txt": parsing.NewFile("package"),
+		},
+		Config:  map[string]interface{}{"name": "svc"},
+		Origins: map[string]string{"/project/cmd": "cmd.json"},
+	}
+
+	cfg := &config.Config{Variables: map[string]interface{}{"name": "app"}}
+	plan, err := BuildPlan(cfg, jsonTemplate)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	expected := map[string]string{
+		"svc/cmd/app.go": "package main\n\nfunc main() {\n\tprintln(\"app\")\n}\n",
+		"svc/README.md":  "func  x",
+		"svc/broken.txt": "package",
+	}
+	for _, action := range plan.Actions {
+		if content, exists := expected[action.Path]; exists && action.Content != content {
+			t.Errorf("Expected %s to hold %q, got %q", action.Path, content, action.Content)
+		}
+	}
+
+	jsonTemplate.Project.(map[string]interface{})["cmd"].(map[string]interface{})["<name>.go"] = parsing.NewFile("package main\n\nfunc main() {\n\tprintln(<name>\n}\n")
+	_, err = BuildPlan(cfg, jsonTemplate)
+	for _, part := range []string{"svc/cmd/app.go", "defined in cmd.json at /project/cmd/<name>.go", "line 4", `"\tprintln(app"`} {
+		if err == nil || !strings.Contains(err.Error(), part) {
+			t.Errorf("Expected an error containing %q, got: %v", part, err)
+		}
+	}
+
+	// empty Go files cannot build
+	jsonTemplate.Project.(map[string]interface{})["cmd"].(map[string]interface{})["<name>.go"] = "file"
+	_, err = BuildPlan(cfg, jsonTemplate)
+	for _, part := range []string{"Empty Go source in svc/cmd/app.go", "defined in cmd.json at /project/cmd/<name>.go"} {
+		if err == nil || !strings.Contains(err.Error(), part) {
+			t.Errorf("Expected an error containing %q, got: %v", part, err)
+		}
+	}
+}
+
+// TestVerifyBuild checks that a project that builds passes and that one
+// failing go vet is reported, with and without a go.mod file or a module
+// directive.
+func TestVerifyBuild(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go is not installed")
+	}
+
+	tests := []struct {
+		name     string
+		fsys     fstest.MapFS
+		expected string
+	}{
+		{"builds", fstest.MapFS{
+			"go.mod":          {Data: []byte("module example.com/svc\n\ngo 1.21\n")},
+			"main.go":         {Data: []byte("package main\n\nimport \"example.com/svc/internal/x\"\n\nfunc main() { x.Run() }\n")},
+			"internal/x/x.go": {Data: []byte("package x\n\nfunc Run() {}\n")},
+			".git/HEAD":       {Data: []byte("ref: refs/heads/main\n")},
+		}, ""},
+		{"no go.mod", fstest.MapFS{
+			"main.go": {Data: []byte("package main\n\nfunc main() {}\n")},
+		}, ""},
+		{"empty go.mod", fstest.MapFS{
+			"go.mod":          {Data: []byte("")},
+			"main.go":         {Data: []byte("package main\n\nimport \"svc/internal/x\"\n\nfunc main() { x.Run() }\n")},
+			"internal/x/x.go": {Data: []byte("package x\n\nfunc Run() {}\n")},
+		}, ""},
+		{"vet", fstest.MapFS{
+			"main.go": {Data: []byte("package main\n\nimport \"fmt\"\n\nfunc main() { fmt.Printf(\"%d\", \"x\") }\n")},
+		}, "go vet"},
+		{"build", fstest.MapFS{
+			"main.go": {Data: []byte("package main\n\nfunc main() { undefined() }\n")},
+		}, "go build"},
+	}
+
+	for _, tt := range tests {
+		err := VerifyBuild(context.Background(), &config.Config{}, tt.fsys, "svc", true)
+		if tt.expected == "" && err != nil {
+			t.Errorf("%s: expected no error, got: %v", tt.name, err)
+		}
+		if tt.expected != "" && (err == nil || !strings.Contains(err.Error(), tt.expected)) {
+			t.Errorf("%s: expected an error containing %q, got: %v", tt.name, tt.expected, err)
+		}
+	}
+
+	for name, expected := range map[string]string{"svc": "svc", "My Service!": "My-Service", "": "project", "../x": "x"} {
+		if path := modulePath(name); path != expected {
+			t.Errorf("Expected the module path of %q to be %q, got %q", name, expected, path)
+		}
+	}
+}
+
+func listTarGz(data []byte) ([]string, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return names, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, header.Name)
+	}
+}
+
+func listZip(data []byte) ([]string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	return names, nil
+}
+
 // osExit is a variable to allow overriding os.Exit in tests if needed. By default, it calls os.Exit.
 var osExit = os.Exit
 
@@ -0,0 +1,215 @@
+package bootstrap
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/paoloanzn/go-bootstrap/config"
+	"github.com/paoloanzn/go-bootstrap/parsing"
+)
+
+type CheckStatus string
+
+const (
+	CheckOK       CheckStatus = "ok"
+	CheckMissing  CheckStatus = "missing"  // required by the template, not found
+	CheckModified CheckStatus = "modified" // content differs from the generated one
+	CheckExtra    CheckStatus = "extra"    // top-level path the template does not define
+)
+
+// CheckEntry is the state of a path of a checked project, relative to the
+// project root.
+type CheckEntry struct {
+	Path   string      `json:"path"`
+	Kind   ActionKind  `json:"kind"`
+	Status CheckStatus `json:"status"`
+}
+
+// CheckReport compares a project with the structure its template defines.
+type CheckReport struct {
+	Template string       `json:"template"`
+	Entries  []CheckEntry `json:"entries"`
+}
+
+// checkIgnored are top-level paths never reported as extra.
+var checkIgnored = map[string]bool{".git": true, ManifestDir: true}
+
+// Check compares the project at the root of fsys with the one t generates
+// with the name and variables of cfg. Files and directories of the template
+// must exist, and top-level paths the template does not define are reported
+// as extra. Files recorded in the manifest of the project must hold the
+// content recorded there, so that files rendering random secrets or dates are
+// not reported; the others must hold the content of the template, if any.
+// Nodes whose condition is false, injections and Go edits are not checked.
+func Check(fsys fs.FS, cfg *config.Config, t *parsing.JSONTemplate) (*CheckReport, error) {
+	c := cfg.Clone()
+	c.InPlace = true
+
+	plan, err := BuildPlan(c, t)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &Manifest{}
+	data, err := fs.ReadFile(fsys, ManifestPath)
+	if err == nil {
+		manifest, err = ParseManifest(data)
+	}
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	report := &CheckReport{Template: plan.Source, Entries: []CheckEntry{}}
+	defined := make(map[string]bool)
+
+	for _, action := range plan.Actions {
+		name := strings.TrimSuffix(action.Path, "/")
+		top, _, _ := strings.Cut(name, "/")
+		defined[top] = true
+
+		if action.Kind == ActionSkip || action.Kind == ActionInject || action.Kind == ActionEdit {
+			continue
+		}
+
+		entry := CheckEntry{Path: name, Kind: action.Kind, Status: CheckOK}
+		info, err := fs.Stat(fsys, name)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			entry.Status = CheckMissing
+		case err != nil:
+			return nil, err
+		case info.IsDir() != (action.Kind == ActionDir):
+			entry.Status = CheckMissing
+		case action.Kind == ActionFile:
+			recorded, tracked := manifest.Entry(name)
+			tracked = tracked && recorded.Kind == ActionFile
+			if !tracked && action.Content == "" {
+				break
+			}
+
+			data, err := fs.ReadFile(fsys, name)
+			if err != nil {
+				return nil, err
+			}
+			if tracked && Checksum(data) != recorded.SHA256 || !tracked && string(data) != action.Content {
+				entry.Status = CheckModified
+			}
+		}
+		report.Entries = append(report.Entries, entry)
+	}
+
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if defined[e.Name()] || checkIgnored[e.Name()] {
+			continue
+		}
+
+		kind := ActionFile
+		if e.IsDir() {
+			kind = ActionDir
+		}
+		report.Entries = append(report.Entries, CheckEntry{Path: e.Name(), Kind: kind, Status: CheckExtra})
+	}
+
+	sort.SliceStable(report.Entries, func(i, j int) bool {
+		return report.Entries[i].Path < report.Entries[j].Path
+	})
+
+	return report, nil
+}
+
+// Drift returns the entries that are not CheckOK.
+func (r *CheckReport) Drift() []CheckEntry {
+	var drift []CheckEntry
+	for _, entry := range r.Entries {
+		if entry.Status != CheckOK {
+			drift = append(drift, entry)
+		}
+	}
+
+	return drift
+}
+
+// Print writes the drift of r in a human readable form.
+func (r *CheckReport) Print(w io.Writer) error {
+	drift := r.Drift()
+	if len(drift) == 0 {
+		_, err := fmt.Fprintf(w, "The project matches %s (%d paths checked).\n", r.Template, len(r.Entries))
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, entry := range drift {
+		fmt.Fprintf(tw, "%s\t%s\n", entry.Status, entry.Path)
+	}
+
+	return tw.Flush()
+}
+
+// WriteJSON writes r as indented JSON.
+func (r *CheckReport) WriteJSON(w io.Writer) error {
+	data, err := json.MarshalIndent(r, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+type junitSuite struct {
+	XMLName  xml.Name    `xml:"testsuite"`
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Type    string `xml:"type,attr"`
+	Message string `xml:"message,attr"`
+}
+
+// WriteJUnit writes r as a JUnit XML test suite with a test case per path,
+// for CI systems to report.
+func (r *CheckReport) WriteJUnit(w io.Writer) error {
+	suite := junitSuite{Name: config.AppName + " check", Tests: len(r.Entries)}
+	for _, entry := range r.Entries {
+		c := junitCase{ClassName: r.Template, Name: entry.Path}
+		if entry.Status != CheckOK {
+			c.Failure = &junitFailure{Type: string(entry.Status), Message: fmt.Sprintf("%s %s", entry.Path, checkMessages[entry.Status])}
+			suite.Failures++
+		}
+		suite.Cases = append(suite.Cases, c)
+	}
+
+	data, err := xml.MarshalIndent(suite, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "%s%s\n", xml.Header, data)
+	return err
+}
+
+var checkMessages = map[CheckStatus]string{
+	CheckMissing:  "is required by the template and missing",
+	CheckModified: "differs from the content of the template",
+	CheckExtra:    "is not defined by the template",
+}
@@ -0,0 +1,151 @@
+package bootstrap
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/paoloanzn/go-bootstrap/diff"
+)
+
+type DiffStatus string
+
+const (
+	DiffAdded    DiffStatus = "added"    // generated, not in the directory
+	DiffModified DiffStatus = "modified" // generated with another content
+	DiffRemoved  DiffStatus = "removed"  // in a generated directory, not generated
+)
+
+// FileDiff is a difference between a directory and a plan. Old is the content
+// in the directory and New the generated one. Directory paths end with a
+// slash.
+type FileDiff struct {
+	Status DiffStatus
+	Path   string
+	Old    string
+	New    string
+}
+
+// DiffReport lists the differences between a directory and a plan, by path.
+type DiffReport struct {
+	Files []FileDiff
+}
+
+// Compare compares the directory at the root of fsys with what applying plan
+// there would produce, without writing anything. Injections and Go edits are
+// left out. Files the plan leaves empty are only compared when missing, and
+// files of the directory are only listed as removed in the directories the
+// plan creates.
+func Compare(fsys fs.FS, plan *Plan) (*DiffReport, error) {
+	report := &DiffReport{}
+	generated := make(map[string]bool)
+	dirs := []string{"."}
+
+	for _, action := range plan.Actions {
+		name := strings.TrimSuffix(strings.TrimPrefix(action.Path, plan.Root), "/")
+		if name == "" || action.Kind == ActionSkip || action.Kind == ActionInject || action.Kind == ActionEdit {
+			continue
+		}
+		generated[name] = true
+
+		_, err := fs.Stat(fsys, name)
+		missing := errors.Is(err, fs.ErrNotExist)
+		if err != nil && !missing {
+			return nil, err
+		}
+
+		switch {
+		case action.Kind == ActionDir:
+			dirs = append(dirs, name)
+			if missing {
+				report.Files = append(report.Files, FileDiff{Status: DiffAdded, Path: name + "/"})
+			}
+		case missing:
+			report.Files = append(report.Files, FileDiff{Status: DiffAdded, Path: name, New: action.Content})
+		case action.Content != "":
+			data, err := fs.ReadFile(fsys, name)
+			if err != nil {
+				return nil, err
+			}
+			if string(data) != action.Content {
+				report.Files = append(report.Files, FileDiff{Status: DiffModified, Path: name, Old: string(data), New: action.Content})
+			}
+		}
+	}
+
+	for _, dir := range dirs {
+		entries, err := fs.ReadDir(fsys, dir)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, e := range entries {
+			name := path.Join(dir, e.Name())
+			if e.IsDir() || generated[name] || name == ManifestDir {
+				continue
+			}
+
+			data, err := fs.ReadFile(fsys, name)
+			if err != nil {
+				return nil, err
+			}
+			report.Files = append(report.Files, FileDiff{Status: DiffRemoved, Path: name, Old: string(data)})
+		}
+	}
+
+	sort.SliceStable(report.Files, func(i, j int) bool {
+		return report.Files[i].Path < report.Files[j].Path
+	})
+
+	return report, nil
+}
+
+// Print lists the added and removed paths of r, then writes the unified diff
+// of every modified file.
+func (r *DiffReport) Print(w io.Writer) error {
+	for _, status := range []DiffStatus{DiffAdded, DiffRemoved} {
+		for _, file := range r.Files {
+			if file.Status == status {
+				fmt.Fprintf(w, "%s %s\n", status, file.Path)
+			}
+		}
+	}
+
+	for _, file := range r.Files {
+		if file.Status != DiffModified {
+			continue
+		}
+
+		_, err := io.WriteString(w, diff.Unified("a/"+file.Path, "b/"+file.Path, file.Old, file.New))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// PrintStat writes the number of lines each file gains and loses, followed by
+// the totals.
+func (r *DiffReport) PrintStat(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 1, ' ', 0)
+
+	totalAdded, totalDeleted := 0, 0
+	for _, file := range r.Files {
+		added, deleted := diff.Stat(file.Old, file.New)
+		totalAdded += added
+		totalDeleted += deleted
+		fmt.Fprintf(tw, " %s\t| %d %s%s\t(%s)\n", file.Path, added+deleted, strings.Repeat("+", min(added, 40)), strings.Repeat("-", min(deleted, 40)), file.Status)
+	}
+	fmt.Fprintf(tw, " %d files changed, %d insertions(+), %d deletions(-)\n", len(r.Files), totalAdded, totalDeleted)
+
+	return tw.Flush()
+}
@@ -3,14 +3,16 @@ package bootstrap
 import (
 	"fmt"
 	"log"
-	"os"
+	"sort"
+	"strings"
 
 	"github.com/paoloanzn/go-bootstrap/config"
+	"github.com/paoloanzn/go-bootstrap/expr"
 	"github.com/paoloanzn/go-bootstrap/format"
 	"github.com/paoloanzn/go-bootstrap/parsing"
 )
 
-func CreateDir(path string, abortIfFailed bool) error {
+func CreateDir(cfg *config.Config, path string, abortIfFailed bool) error {
 	if path == "" {
 		if abortIfFailed {
 			log.Fatalf("Fatal: empty path is invalid\n")
@@ -19,27 +21,53 @@ func CreateDir(path string, abortIfFailed bool) error {
 		}
 	}
 
-	formattedPath := format.MatchWildCards(path)
+	formattedPath := format.MatchWildCards(cfg, path)
 	formattedPath = format.FormatPath(formattedPath)
 
-	if _, err := os.Stat(formattedPath); !os.IsNotExist(err) {
-		return nil
+	_, err := createDir(callerFS(cfg), cfg, formattedPath)
+	if err != nil && abortIfFailed {
+		log.Fatalf("Fatal: %v\n", err)
+	}
+
+	return err
+}
+
+// callerFS returns the filesystem CreateDir and WriteFile write to: paths
+// are confined to the output directory when one is set, and used as given
+// otherwise.
+func callerFS(cfg *config.Config) FS {
+	if cfg.OutputDir != "" {
+		return NewOSFS(cfg.OutputDir)
+	}
+
+	return &osFS{callerPaths: true}
+}
+
+// createDir creates the directory formattedPath in fsys and reports whether it
+// did not exist yet.
+func createDir(fsys FS, cfg *config.Config, formattedPath string) (bool, error) {
+	existing, err := exists(fsys, formattedPath)
+	if err != nil || existing {
+		return false, err
 	}
 
-	err := os.Mkdir(formattedPath, 0755)
+	err = fsys.Mkdir(formattedPath, 0755)
 	if err != nil {
-		if abortIfFailed {
-			log.Fatalf("Fatal: %v\n", err)
-		} else {
-			return err
-		}
+		return false, err
 	}
 
-	fmt.Printf("Created %s\n", formattedPath)
-	return nil
+	cfg.Logf("Created %s\n", formattedPath)
+	return true, nil
+}
+
+func CreateFile(cfg *config.Config, path string, abortIfFailed bool) error {
+	return WriteFile(cfg, path, "", abortIfFailed)
 }
 
-func CreateFile(path string, abortIfFailed bool) error {
+// WriteFile creates the file at path holding content. Wildcards are matched
+// both in the path and in the content. Existing files are handled according
+// to the conflict policy of cfg.
+func WriteFile(cfg *config.Config, path string, content string, abortIfFailed bool) error {
 	if path == "" {
 		if abortIfFailed {
 			log.Fatalf("Fatal: empty path is invalid\n")
@@ -48,83 +76,321 @@ func CreateFile(path string, abortIfFailed bool) error {
 		}
 	}
 
-	formattedPath := format.MatchWildCards(path)
+	formattedPath := format.MatchWildCards(cfg, path)
 	formattedPath = format.FormatPath(formattedPath)
 
-	if _, err := os.Stat(formattedPath); !os.IsNotExist(err) {
-		return nil
+	_, err := writeFile(callerFS(cfg), cfg, formattedPath, format.MatchWildCards(cfg, content))
+	if err != nil && abortIfFailed {
+		log.Fatalf("Fatal: %v\n", err)
 	}
 
-	f, err := os.Create(formattedPath)
+	return err
+}
+
+// writeFile writes the file formattedPath in fsys and reports whether it
+// existed before. Existing files are kept, overwritten or are an error
+// depending on the conflict policy of cfg.
+func writeFile(fsys FS, cfg *config.Config, formattedPath string, content string) (fileState, error) {
+	state := fileCreated
+	existing, err := exists(fsys, formattedPath)
 	if err != nil {
-		if abortIfFailed {
-			log.Fatalf("Fatal: %v\n", err)
+		return fileKept, err
+	}
+	if existing {
+		switch cfg.OnConflict {
+		case config.ConflictOverwrite:
+			state = fileOverwritten
+		case config.ConflictFail:
+			return fileKept, fmt.Errorf("%s already exists.", formattedPath)
+		default:
+			return fileKept, nil
+		}
+	}
+
+	err = fsys.WriteFile(formattedPath, []byte(content), 0644)
+	if err != nil {
+		return fileKept, err
+	}
+
+	if state == fileOverwritten {
+		cfg.Logf("Overwrote %s\n", formattedPath)
+	} else {
+		cfg.Logf("Created %s\n", formattedPath)
+	}
+	return state, nil
+}
+
+// TraverseNode adds to plan the files and directories described by pNode,
+// rooted at prefixPath. Nodes with an $each attribute are repeated once per
+// item of a list, and nodes whose $if condition is false are recorded as
+// skipped along with their whole subtree. The loop variables of $each are set
+// in cfg.Variables while their subtree is traversed, and restored after, so
+// cfg must not be shared with concurrent runs: BuildPlan passes a clone.
+func TraverseNode(cfg *config.Config, pNode map[string]interface{}, prefixPath string, plan *Plan) error {
+	names := make([]string, 0, len(pNode))
+	for name := range pNode {
+		if !parsing.IsAttribute(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	// created maps the names created in this directory to their template
+	// key, to detect repeated nodes colliding once expanded
+	created := make(map[string]string)
+
+	for _, name := range names {
+		value := pNode[name]
+
+		asserted, _ := value.(map[string]interface{})
+		loop, exists := asserted[parsing.AttrEach]
+		if !exists {
+			err := traverseEntry(cfg, name, value, prefixPath, plan, created)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		variable, items, err := evalLoop(cfg, loop)
+		if err != nil {
+			return fmt.Errorf("Error evaluating %s of %s%s: %v", parsing.AttrEach, prefixPath, name, err)
+		}
+		if len(items) == 0 {
+			plan.Skip(prefixPath+name, fmt.Sprintf("no items in %q", loop))
+			continue
+		}
+
+		if cfg.Variables == nil {
+			cfg.Variables = make(map[string]interface{})
+		}
+
+		previous, shadowed := cfg.Variables[variable]
+		for _, item := range items {
+			cfg.Variables[variable] = item
+
+			err = traverseEntry(cfg, name, value, prefixPath, plan, created)
+			if err != nil {
+				break
+			}
+		}
+
+		if shadowed {
+			cfg.Variables[variable] = previous
 		} else {
+			delete(cfg.Variables, variable)
+		}
+		if err != nil {
 			return err
 		}
 	}
-	defer f.Close()
 
-	fmt.Printf("Created %s\n", formattedPath)
 	return nil
 }
 
-func TraverseNode(pNode map[string]interface{}, prefixPath string) error {
-	for name, value := range pNode {
-		if value == "file" {
-			fullPath := fmt.Sprintf("%s%s", prefixPath, name)
-			err := CreateFile(fullPath, true)
+func traverseEntry(cfg *config.Config, name string, value interface{}, prefixPath string, plan *Plan, created map[string]string) error {
+	matchedName := format.MatchWildCards(cfg, name)
+	fullPath := fmt.Sprintf("%s%s", prefixPath, matchedName)
+	nodePath := plan.node + "/" + name
+
+	// names come from the template and the variables, both untrusted: a
+	// name must stay a single entry of its directory
+	if !parsing.IsValidName(matchedName) {
+		return fmt.Errorf("Invalid name %q for %s (%s): expected a single path element.", matchedName, prefixPath+name, plan.provenance(nodePath))
+	}
+
+	asserted, ok := value.(map[string]interface{})
+	if ok {
+		if condition, exists := asserted[parsing.AttrIf]; exists {
+			include, err := evalCondition(cfg, condition)
 			if err != nil {
-				return err
+				return fmt.Errorf("Error evaluating condition of %s: %v", fullPath, err)
+			}
+			if !include {
+				if parsing.IsDir(value) {
+					fullPath += "/"
+				}
+				plan.Skip(fullPath, fmt.Sprintf("condition %q is false", condition))
+				return nil
 			}
+		}
+	}
 
-			continue
+	if other, exists := created[matchedName]; exists {
+		return fmt.Errorf("%s is generated by both %s and %s.", fullPath, other, name)
+	}
+	created[matchedName] = name
+
+	if parsing.IsFile(value) {
+		content, err := parsing.FileContent(value)
+		if err != nil {
+			return err
 		}
 
-		asserted, ok := value.(map[string]interface{})
-		if !ok {
-			return fmt.Errorf("Error traversing template config file: Invalid structure.")
+		content = format.MatchWildCards(cfg, content)
+		if strings.HasSuffix(fullPath, ".go") {
+			if strings.TrimSpace(content) == "" {
+				return fmt.Errorf("Empty Go source in %s (%s): Go files need at least a package clause.", fullPath, plan.provenance(nodePath))
+			}
+			content, err = formatGo(content)
+			if err != nil {
+				return fmt.Errorf("Invalid Go source in %s (%s): %v", fullPath, plan.provenance(nodePath), err)
+			}
 		}
 
-		fullPath := fmt.Sprintf("%s%s/", prefixPath, name)
-		err := CreateDir(fullPath, true)
+		plan.File(fullPath, content)
+		return nil
+	}
+
+	if parsing.IsInject(value) {
+		content, err := parsing.FileContent(value)
 		if err != nil {
 			return err
 		}
 
-		err = TraverseNode(asserted, fullPath)
+		position, marker := InjectEnd, ""
+		if s, exists := asserted[parsing.AttrBefore].(string); exists {
+			position, marker = InjectBefore, s
+		}
+		if s, exists := asserted[parsing.AttrAfter].(string); exists {
+			position, marker = InjectAfter, s
+		}
+
+		plan.Inject(fullPath, format.MatchWildCards(cfg, content), position, format.MatchWildCards(cfg, marker))
+		return nil
+	}
+
+	if parsing.IsGoEdit(value) {
+		edits, err := parsing.GoEdits(value)
 		if err != nil {
 			return err
 		}
+
+		for i := range edits {
+			edits[i] = matchEdit(cfg, edits[i])
+		}
+		plan.Edit(fullPath, edits)
+		return nil
 	}
 
-	return nil
+	if !ok {
+		return fmt.Errorf("Error traversing template config file: Invalid structure.")
+	}
+
+	fullPath += "/"
+	plan.Dir(fullPath)
+
+	parent := plan.node
+	plan.node = nodePath
+	defer func() { plan.node = parent }()
+
+	return TraverseNode(cfg, asserted, fullPath, plan)
+}
+
+// conditionEnv returns the variables visible to $if and $each expressions and
+// to derived variables.
+func conditionEnv(cfg *config.Config) map[string]interface{} {
+	env := make(map[string]interface{})
+	for name, value := range format.Builtins(cfg) {
+		env[name] = value
+	}
+
+	env["main_package"] = cfg.ProjectName
+	for name, value := range cfg.Variables {
+		env[name] = value
+	}
+
+	return env
+}
+
+// evalCondition evaluates a $if attribute against the template variables.
+func evalCondition(cfg *config.Config, condition interface{}) (bool, error) {
+	s, ok := condition.(string)
+	if !ok {
+		return false, fmt.Errorf("Invalid %s attribute: expected a string.", parsing.AttrIf)
+	}
+
+	return expr.EvalBool(s, conditionEnv(cfg))
+}
+
+// evalLoop evaluates an $each attribute, "<variable> in <list>", and returns
+// the loop variable and the items to repeat the node for.
+func evalLoop(cfg *config.Config, loop interface{}) (string, []interface{}, error) {
+	s, ok := loop.(string)
+	if !ok {
+		return "", nil, fmt.Errorf("Invalid %s attribute: expected a string.", parsing.AttrEach)
+	}
+
+	variable, list, err := expr.ParseLoop(s)
+	if err != nil {
+		return "", nil, err
+	}
+
+	value, err := list.Eval(conditionEnv(cfg))
+	if err != nil {
+		return "", nil, err
+	}
+
+	items, ok := value.([]interface{})
+	if !ok {
+		return "", nil, fmt.Errorf("%v is not a list", value)
+	}
+
+	return variable, items, nil
 }
 
-func Bootstrap(pJsonTemplate *parsing.JSONTemplate) error {
+// BuildPlan resolves the template variables and computes what Bootstrap
+// creates, without touching the filesystem. cfg is left unchanged, so one
+// Config can serve concurrent runs. The date and the secrets are drawn anew
+// unless cfg.RunBuiltins holds them already.
+func BuildPlan(cfg *config.Config, pJsonTemplate *parsing.JSONTemplate) (*Plan, error) {
 	projectConfig := pJsonTemplate.Config
 
 	projectFolderName, exists := projectConfig["name"]
 	if !exists {
-		return fmt.Errorf("Error parsing config.name from template config file.")
+		return nil, fmt.Errorf("Error parsing config.name from template config file.")
+	}
+	name, ok := projectFolderName.(string)
+	if !ok {
+		return nil, fmt.Errorf("Invalid config.name in template config file: expected a string.")
+	}
+	cfg = cfg.Clone()
+	if cfg.RunBuiltins == nil {
+		cfg.RunBuiltins = format.RunBuiltins()
+	}
+	if cfg.ProjectName == "" {
+		cfg.ProjectName = name
 	}
-	config.Cfg.ProjectName = projectFolderName.(string)
 
-	err := CreateDir(projectFolderName.(string), true)
+	err := ResolveVariables(cfg, pJsonTemplate)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	asserted, ok := pJsonTemplate.Project.(map[string]interface{})
 	if !ok {
-		log.Fatalf("Invalid project json configuration.")
+		return nil, fmt.Errorf("Invalid project json configuration.")
 	}
 
-	rootPath := fmt.Sprintf("%s/", projectFolderName.(string))
-	err = TraverseNode(asserted, rootPath)
+	plan := &Plan{
+		Name:      cfg.ProjectName,
+		Source:    pJsonTemplate.Source,
+		Version:   pJsonTemplate.Version,
+		Variables: publicVariables(cfg, pJsonTemplate),
+		template:  pJsonTemplate,
+		node:      "/project",
+		random:    randomVariables(cfg, pJsonTemplate),
+	}
+	if !cfg.InPlace {
+		plan.Root = fmt.Sprintf("%s/", cfg.ProjectName)
+		plan.Dir(plan.Root)
+	}
+
+	err = TraverseNode(cfg, asserted, plan.Root, plan)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	plan.template = nil
 
-	return nil
+	return plan, nil
 }
@@ -0,0 +1,58 @@
+package bootstrap
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"github.com/paoloanzn/go-bootstrap/config"
+	"github.com/paoloanzn/go-bootstrap/format"
+	"github.com/paoloanzn/go-bootstrap/goedit"
+)
+
+// editFile applies the Go edits of action to its file in fsys. It reports
+// false when the file already has every edit.
+func editFile(fsys FS, cfg *config.Config, action Action) (bool, error) {
+	r, ok := fsys.(readFileFS)
+	if !ok {
+		return false, fmt.Errorf("Cannot edit %s: the output cannot be read back.", action.Path)
+	}
+
+	data, err := r.ReadFile(action.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, fmt.Errorf("Cannot edit %s: the file does not exist.", action.Path)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	edited := false
+	for _, edit := range action.Edits {
+		var changed bool
+		data, changed, err = edit.Apply(data)
+		if err != nil {
+			return false, fmt.Errorf("%v (editing %s, %s)", err, action.Path, edit)
+		}
+		edited = edited || changed
+	}
+	if !edited {
+		return false, nil
+	}
+
+	err = fsys.WriteFile(action.Path, data, 0644)
+	if err != nil {
+		return false, err
+	}
+
+	cfg.Logf("Edited %s\n", action.Path)
+	return true, nil
+}
+
+// matchEdit matches the wildcards of every field of edit.
+func matchEdit(cfg *config.Config, edit goedit.Edit) goedit.Edit {
+	for _, field := range []*string{&edit.Import, &edit.Name, &edit.Func, &edit.Statement, &edit.Struct, &edit.Field, &edit.Type, &edit.Method} {
+		*field = format.MatchWildCards(cfg, *field)
+	}
+
+	return edit
+}
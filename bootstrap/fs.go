@@ -0,0 +1,155 @@
+package bootstrap
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// FS is a writable filesystem projects are generated into. Names are slash
+// separated paths, relative to the root of the filesystem. As with the os
+// package, the parent directory must exist before an entry is created in it.
+type FS interface {
+	// Stat returns a fs.ErrNotExist error when name does not exist.
+	Stat(name string) (fs.FileInfo, error)
+	Mkdir(name string, perm fs.FileMode) error
+	// WriteFile creates or truncates name.
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+}
+
+// exists reports whether name exists in fsys. Errors other than
+// fs.ErrNotExist, such as a name escaping the filesystem, are returned.
+func exists(fsys FS, name string) (bool, error) {
+	_, err := fsys.Stat(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+type osFS struct {
+	root string
+
+	// callerPaths marks the filesystem of CreateDir and WriteFile, whose
+	// paths are chosen by their caller rather than by a template and are
+	// used as given.
+	callerPaths bool
+}
+
+// NewOSFS returns the filesystem of the operating system rooted at root, the
+// working directory when empty. Names cannot escape root, neither with ".."
+// nor through symbolic links, see os.Root.
+func NewOSFS(root string) UpdateFS {
+	return &osFS{root: root}
+}
+
+// open returns the root names are resolved in, with name made a local path.
+// With callerPaths, the root is nil and name is used as given.
+func (o *osFS) open(name string) (*os.Root, string, error) {
+	local := filepath.FromSlash(name)
+	if o.callerPaths {
+		return nil, local, nil
+	}
+
+	if !filepath.IsLocal(local) {
+		if o.root == "" {
+			return nil, "", fmt.Errorf("%s is outside of the working directory.", name)
+		}
+		return nil, "", fmt.Errorf("%s is outside of %s.", name, o.root)
+	}
+
+	dir := o.root
+	if dir == "" {
+		dir = "."
+	}
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return root, local, nil
+}
+
+func (o *osFS) Stat(name string) (fs.FileInfo, error) {
+	root, p, err := o.open(name)
+	if err != nil {
+		return nil, err
+	}
+	if root == nil {
+		return os.Stat(p)
+	}
+	defer root.Close()
+
+	return root.Stat(p)
+}
+
+func (o *osFS) ReadFile(name string) ([]byte, error) {
+	root, p, err := o.open(name)
+	if err != nil {
+		return nil, err
+	}
+	if root == nil {
+		return os.ReadFile(p)
+	}
+	defer root.Close()
+
+	f, err := root.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return io.ReadAll(f)
+}
+
+func (o *osFS) Mkdir(name string, perm fs.FileMode) error {
+	root, p, err := o.open(name)
+	if err != nil {
+		return err
+	}
+	if root == nil {
+		return os.Mkdir(p, perm)
+	}
+	defer root.Close()
+
+	return root.Mkdir(p, perm)
+}
+
+func (o *osFS) Remove(name string) error {
+	root, p, err := o.open(name)
+	if err != nil {
+		return err
+	}
+	if root == nil {
+		return os.Remove(p)
+	}
+	defer root.Close()
+
+	return root.Remove(p)
+}
+
+func (o *osFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	root, p, err := o.open(name)
+	if err != nil {
+		return err
+	}
+	if root == nil {
+		return os.WriteFile(p, data, perm)
+	}
+	defer root.Close()
+
+	f, err := root.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
@@ -0,0 +1,53 @@
+package bootstrap
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/paoloanzn/go-bootstrap/config"
+	"github.com/paoloanzn/go-bootstrap/parsing"
+)
+
+// BuildGeneratorPlan is BuildPlan for the generator name of t, rendered in
+// place into an existing project with the name and variables of cfg.
+func BuildGeneratorPlan(cfg *config.Config, t *parsing.JSONTemplate, name string) (*Plan, error) {
+	generator, exists := t.Generators[name]
+	if !exists {
+		return nil, fmt.Errorf("Unknown generator %s, available generators: %s.", name, GeneratorNames(t))
+	}
+
+	sub := &parsing.JSONTemplate{
+		Version:   t.Version,
+		Variables: generator.Variables,
+		Project:   generator.Project,
+		Config:    t.Config,
+		Source:    t.Source,
+	}
+
+	c := cfg.Clone()
+	c.InPlace = true
+
+	plan, err := BuildPlan(c, sub)
+	if err != nil {
+		return nil, fmt.Errorf("%v (generator %s)", err, name)
+	}
+	plan.Generator = name
+
+	return plan, nil
+}
+
+// GeneratorNames returns the generators of t, comma separated.
+func GeneratorNames(t *parsing.JSONTemplate) string {
+	if len(t.Generators) == 0 {
+		return "none"
+	}
+
+	names := make([]string, 0, len(t.Generators))
+	for name := range t.Generators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,96 @@
+package bootstrap
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+
+	"github.com/paoloanzn/go-bootstrap/config"
+	"github.com/paoloanzn/go-bootstrap/diff"
+)
+
+// injectFile applies the injection action to its file in fsys. It reports
+// false when the file already holds the content, so that running a template
+// again does not insert it twice.
+func injectFile(fsys FS, cfg *config.Config, action Action) (bool, error) {
+	r, ok := fsys.(readFileFS)
+	if !ok {
+		return false, fmt.Errorf("Cannot inject into %s: the output cannot be read back.", action.Path)
+	}
+
+	data, err := r.ReadFile(action.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, fmt.Errorf("Cannot inject into %s: the file does not exist.", action.Path)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	content, injected, err := inject(string(data), action.Content, action.Position, action.Marker)
+	if err != nil {
+		return false, fmt.Errorf("%v (injecting into %s)", err, action.Path)
+	}
+	if !injected {
+		return false, nil
+	}
+	if strings.HasSuffix(action.Path, ".go") {
+		content, err = formatGo(content)
+		if err != nil {
+			return false, fmt.Errorf("Invalid Go source after injecting into %s: %v", action.Path, err)
+		}
+	}
+
+	err = fsys.WriteFile(action.Path, []byte(content), 0644)
+	if err != nil {
+		return false, err
+	}
+
+	cfg.Logf("Injected into %s\n", action.Path)
+	return true, nil
+}
+
+// inject inserts snippet into content, before or after the first line holding
+// marker, or at the end. Snippets are inserted as whole lines, and not at all
+// when content already holds them.
+func inject(content string, snippet string, position InjectPosition, marker string) (string, bool, error) {
+	if snippet != "" && !strings.HasSuffix(snippet, "\n") {
+		snippet += "\n"
+	}
+	if snippet == "" || strings.Contains(content, snippet) {
+		return content, false, nil
+	}
+
+	if position == InjectEnd {
+		if content != "" && !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+		return content + snippet, true, nil
+	}
+
+	lines := diff.Lines(content)
+	for i, line := range lines {
+		if !strings.Contains(line, marker) {
+			continue
+		}
+
+		if position == InjectAfter {
+			if !strings.HasSuffix(line, "\n") {
+				lines[i] += "\n"
+			}
+			i++
+		}
+
+		var sb strings.Builder
+		for _, l := range lines[:i] {
+			sb.WriteString(l)
+		}
+		sb.WriteString(snippet)
+		for _, l := range lines[i:] {
+			sb.WriteString(l)
+		}
+		return sb.String(), true, nil
+	}
+
+	return content, false, fmt.Errorf("Marker %q not found.", marker)
+}
@@ -0,0 +1,193 @@
+package bootstrap
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/paoloanzn/go-bootstrap/config"
+)
+
+const (
+	// ManifestDir is the directory of the manifest, at the project root.
+	ManifestDir = ".go-bootstrap"
+
+	// ManifestPath is the manifest path relative to the project root.
+	ManifestPath = ManifestDir + "/manifest.json"
+)
+
+// Manifest records what a generation produced, so that the project can later
+// be checked, updated or cleaned up. Paths are relative to the project root.
+type Manifest struct {
+	Tool      string                 `json:"tool"`
+	Version   string                 `json:"version"`
+	Name      string                 `json:"name"`
+	Template  ManifestTemplate       `json:"template"`
+	Variables map[string]interface{} `json:"variables"`
+	Entries   []ManifestEntry        `json:"entries"`
+}
+
+// ManifestTemplate identifies the template a project was generated from.
+type ManifestTemplate struct {
+	Source  string `json:"source"`
+	Version string `json:"version,omitempty"`
+}
+
+// ManifestEntry is a directory or a file written by a generation. SHA256 is
+// only set for files, Generator for the paths a generator added, which the
+// template itself does not define.
+type ManifestEntry struct {
+	Path      string     `json:"path"`
+	Kind      ActionKind `json:"kind"`
+	Mode      string     `json:"mode"`
+	SHA256    string     `json:"sha256,omitempty"`
+	Generator string     `json:"generator,omitempty"`
+}
+
+// Entry returns the entry of path.
+func (m *Manifest) Entry(path string) (ManifestEntry, bool) {
+	for _, entry := range m.Entries {
+		if entry.Path == path {
+			return entry, true
+		}
+	}
+
+	return ManifestEntry{}, false
+}
+
+// add records entries, replacing the entries of the same paths.
+func (m *Manifest) add(entries []ManifestEntry) {
+	for _, entry := range entries {
+		replaced := false
+		for i := range m.Entries {
+			if m.Entries[i].Path == entry.Path {
+				m.Entries[i] = entry
+				replaced = true
+			}
+		}
+		if !replaced {
+			m.Entries = append(m.Entries, entry)
+		}
+	}
+}
+
+// ParseManifest parses the content of a manifest file.
+func ParseManifest(data []byte) (*Manifest, error) {
+	m := &Manifest{}
+	err := json.Unmarshal(data, m)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid manifest: %v", err)
+	}
+
+	return m, nil
+}
+
+// Checksum returns the SHA-256 of content as recorded in manifests.
+func Checksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// newManifest records the entries of plan that result shows were written.
+// Entries kept from an earlier generation, and the ones generators added, are
+// carried over from previous, which may be nil.
+func newManifest(plan *Plan, result *Result, previous *Manifest) *Manifest {
+	m := &Manifest{
+		Tool:      config.AppName,
+		Version:   config.VERSION,
+		Name:      plan.Name,
+		Template:  ManifestTemplate{Source: plan.Source, Version: plan.Version},
+		Variables: plan.Variables,
+		Entries:   []ManifestEntry{},
+	}
+
+	written := make(map[string]bool)
+	for _, p := range append(append([]string{}, result.Created...), result.Overwritten...) {
+		written[p] = true
+	}
+
+	for _, action := range plan.Actions {
+		rel := strings.TrimSuffix(strings.TrimPrefix(action.Path, plan.Root), "/")
+		if action.Kind == ActionSkip || rel == "" {
+			continue
+		}
+
+		if !written[action.Path] {
+			if previous == nil {
+				continue
+			}
+			if entry, exists := previous.Entry(rel); exists {
+				m.Entries = append(m.Entries, entry)
+			}
+			continue
+		}
+
+		entry := ManifestEntry{Path: rel, Kind: action.Kind, Mode: "0755", Generator: plan.Generator}
+		if action.Kind == ActionFile {
+			entry.Mode = "0644"
+			entry.SHA256 = Checksum([]byte(action.Content))
+		}
+		m.Entries = append(m.Entries, entry)
+	}
+
+	if previous != nil && plan.Generator == "" {
+		for _, entry := range previous.Entries {
+			if _, exists := m.Entry(entry.Path); entry.Generator != "" && !exists {
+				m.Entries = append(m.Entries, entry)
+			}
+		}
+	}
+
+	return m
+}
+
+// readFileFS is implemented by the FS implementations that can read back what
+// they wrote.
+type readFileFS interface {
+	ReadFile(name string) ([]byte, error)
+}
+
+// writeManifest writes the manifest of an applied plan to fsys, replacing the
+// one of an earlier generation.
+func writeManifest(fsys FS, plan *Plan, result *Result) error {
+	dir := path.Join(plan.Root, ManifestDir)
+	name := path.Join(plan.Root, ManifestPath)
+
+	var previous *Manifest
+	if r, ok := fsys.(readFileFS); ok {
+		if data, err := r.ReadFile(name); err == nil {
+			previous, _ = ParseManifest(data)
+		}
+	}
+
+	m := newManifest(plan, result, previous)
+	if plan.Generator != "" {
+		// projects without a manifest were not generated by go-bootstrap
+		if previous == nil {
+			return nil
+		}
+		previous.add(m.Entries)
+		m = previous
+	}
+
+	data, err := json.MarshalIndent(m, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	found, err := exists(fsys, dir)
+	if err != nil {
+		return err
+	}
+	if !found {
+		err = fsys.Mkdir(dir, 0755)
+		if err != nil {
+			return err
+		}
+	}
+
+	return fsys.WriteFile(name, append(data, '\n'), 0644)
+}
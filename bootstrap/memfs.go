@@ -0,0 +1,166 @@
+package bootstrap
+
+import (
+	"io/fs"
+	"path"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemFS is an in-memory FS, to preview or test a generation without touching
+// the disk. It is safe for concurrent use.
+type MemFS struct {
+	mu    sync.Mutex
+	dirs  map[string]bool
+	files map[string][]byte
+}
+
+// NewMemFS returns an empty MemFS.
+func NewMemFS() *MemFS {
+	return &MemFS{
+		dirs:  map[string]bool{".": true},
+		files: make(map[string][]byte),
+	}
+}
+
+func (m *MemFS) Stat(name string) (fs.FileInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	name = path.Clean(name)
+	if m.dirs[name] {
+		return &memInfo{name: path.Base(name), dir: true}, nil
+	}
+	if data, exists := m.files[name]; exists {
+		return &memInfo{name: path.Base(name), size: int64(len(data))}, nil
+	}
+
+	return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+}
+
+func (m *MemFS) Mkdir(name string, perm fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	name = path.Clean(name)
+	if err := m.checkCreate("mkdir", name); err != nil {
+		return err
+	}
+	if m.files[name] != nil {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrExist}
+	}
+
+	m.dirs[name] = true
+	return nil
+}
+
+func (m *MemFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	name = path.Clean(name)
+	if err := m.checkCreate("open", name); err != nil {
+		return err
+	}
+
+	m.files[name] = append([]byte{}, data...)
+	return nil
+}
+
+// checkCreate checks that name can be created: it is not a directory and its
+// parent is one.
+func (m *MemFS) checkCreate(op string, name string) error {
+	if m.dirs[name] {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrExist}
+	}
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	if !m.dirs[path.Dir(name)] {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+
+	return nil
+}
+
+// ReadFile returns the content of the file name.
+func (m *MemFS) ReadFile(name string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	data, exists := m.files[path.Clean(name)]
+	if !exists {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	return append([]byte{}, data...), nil
+}
+
+// Remove removes the file or the empty directory name.
+func (m *MemFS) Remove(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	name = path.Clean(name)
+	if _, exists := m.files[name]; exists {
+		delete(m.files, name)
+		return nil
+	}
+	if !m.dirs[name] || name == "." {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+
+	for p := range m.files {
+		if path.Dir(p) == name {
+			return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrExist}
+		}
+	}
+	for p := range m.dirs {
+		if p != name && path.Dir(p) == name {
+			return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrExist}
+		}
+	}
+
+	delete(m.dirs, name)
+	return nil
+}
+
+// Paths returns every entry of m in lexical order. Directory paths end with a
+// slash.
+func (m *MemFS) Paths() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var paths []string
+	for dir := range m.dirs {
+		if dir != "." {
+			paths = append(paths, dir+"/")
+		}
+	}
+	for file := range m.files {
+		paths = append(paths, file)
+	}
+	sort.Strings(paths)
+
+	return paths
+}
+
+type memInfo struct {
+	name string
+	size int64
+	dir  bool
+}
+
+func (i *memInfo) Name() string       { return i.name }
+func (i *memInfo) Size() int64        { return i.size }
+func (i *memInfo) ModTime() time.Time { return time.Time{} }
+func (i *memInfo) IsDir() bool        { return i.dir }
+func (i *memInfo) Sys() interface{}   { return nil }
+
+func (i *memInfo) Mode() fs.FileMode {
+	if i.dir {
+		return fs.ModeDir | 0755
+	}
+	return 0644
+}
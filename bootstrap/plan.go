@@ -0,0 +1,132 @@
+package bootstrap
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/paoloanzn/go-bootstrap/goedit"
+	"github.com/paoloanzn/go-bootstrap/parsing"
+)
+
+type ActionKind string
+
+const (
+	ActionDir  ActionKind = "dir"
+	ActionFile ActionKind = "file"
+	ActionSkip ActionKind = "skip"
+	// ActionInject inserts content into an existing file.
+	ActionInject ActionKind = "inject"
+	// ActionEdit edits an existing Go file through its syntax tree.
+	ActionEdit ActionKind = "edit"
+)
+
+// InjectPosition is where an injection inserts its content.
+type InjectPosition string
+
+const (
+	InjectBefore InjectPosition = "before" // before the marker line
+	InjectAfter  InjectPosition = "after"  // after the marker line
+	InjectEnd    InjectPosition = "end"    // at the end of the file
+)
+
+// Action is a single step of a Plan. Paths have their wildcards matched and
+// directory paths end with a slash.
+type Action struct {
+	Kind    ActionKind
+	Path    string
+	Content string
+	Reason  string // why the node is skipped
+
+	// Position and Marker locate the content of an injection.
+	Position InjectPosition
+	Marker   string
+
+	// Edits are the changes of a Go edit.
+	Edits []goedit.Edit
+}
+
+// Plan lists what a bootstrap creates, in creation order.
+type Plan struct {
+	// Root is the project folder relative to the output directory, with a
+	// trailing slash, or empty when generating in place.
+	Root    string
+	Actions []Action
+
+	// Name, Source, Version and Variables describe the generation in the
+	// manifest. Variables hold the resolved values, without secrets.
+	Name      string
+	Source    string
+	Version   string
+	Variables map[string]interface{}
+
+	// Generator is the generator the plan renders, empty for a whole
+	// project. Generators add their paths to the manifest of the project.
+	Generator string
+
+	// template and node locate the node being traversed in the template
+	// while the plan is built, for error messages.
+	template *parsing.JSONTemplate
+	node     string
+	// random holds the values of the variables drawn by random functions,
+	// see randomVariables.
+	random map[string]interface{}
+}
+
+// provenance describes where the template node at path was defined.
+func (p *Plan) provenance(path string) string {
+	if p.template == nil {
+		return fmt.Sprintf("at %s", path)
+	}
+
+	return p.template.Provenance(path)
+}
+
+func (p *Plan) Dir(path string) {
+	p.Actions = append(p.Actions, Action{Kind: ActionDir, Path: path})
+}
+
+func (p *Plan) File(path string, content string) {
+	p.Actions = append(p.Actions, Action{Kind: ActionFile, Path: path, Content: content})
+}
+
+func (p *Plan) Inject(path string, content string, position InjectPosition, marker string) {
+	p.Actions = append(p.Actions, Action{Kind: ActionInject, Path: path, Content: content, Position: position, Marker: marker})
+}
+
+func (p *Plan) Edit(path string, edits []goedit.Edit) {
+	p.Actions = append(p.Actions, Action{Kind: ActionEdit, Path: path, Edits: edits})
+}
+
+func (p *Plan) Skip(path string, reason string) {
+	p.Actions = append(p.Actions, Action{Kind: ActionSkip, Path: path, Reason: reason})
+}
+
+// Print writes a human readable version of the plan, as shown by dry runs.
+func (p *Plan) Print(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	for _, action := range p.Actions {
+		switch action.Kind {
+		case ActionSkip:
+			fmt.Fprintf(tw, "skip\t%s (%s)\n", action.Path, action.Reason)
+		case ActionInject:
+			if action.Position == InjectEnd {
+				fmt.Fprintf(tw, "inject\t%s (at the end)\n", action.Path)
+			} else {
+				fmt.Fprintf(tw, "inject\t%s (%s %q)\n", action.Path, action.Position, action.Marker)
+			}
+		case ActionEdit:
+			edits := make([]string, 0, len(action.Edits))
+			for _, edit := range action.Edits {
+				edits = append(edits, edit.String())
+			}
+			fmt.Fprintf(tw, "edit\t%s (%s)\n", action.Path, strings.Join(edits, ", "))
+		default:
+			fmt.Fprintf(tw, "create\t%s\n", action.Path)
+		}
+	}
+
+	return tw.Flush()
+}
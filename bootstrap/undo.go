@@ -0,0 +1,88 @@
+package bootstrap
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+// UndoResult lists the paths of an undo, relative to the project root.
+// Directory paths end with a slash.
+type UndoResult struct {
+	Removed []string
+	// Modified holds the files changed since the generation, removed only
+	// when forced.
+	Modified []string
+}
+
+// Undo removes from fsys the files recorded in manifest that are unchanged
+// since the generation, as verified by their checksum, then the recorded
+// directories left empty. Modified files are kept unless force is set. The
+// manifest itself is removed once no recorded file is left.
+func Undo(fsys UpdateFS, manifest *Manifest, force bool) (*UndoResult, error) {
+	result := &UndoResult{}
+	kept := false
+
+	for _, entry := range manifest.Entries {
+		if entry.Kind != ActionFile {
+			continue
+		}
+
+		data, err := fsys.ReadFile(entry.Path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return result, err
+		}
+
+		if Checksum(data) != entry.SHA256 {
+			result.Modified = append(result.Modified, entry.Path)
+			if !force {
+				kept = true
+				continue
+			}
+		}
+
+		err = fsys.Remove(entry.Path)
+		if err != nil {
+			return result, err
+		}
+		result.Removed = append(result.Removed, entry.Path)
+	}
+
+	if !kept {
+		err := fsys.Remove(ManifestPath)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return result, err
+		}
+	}
+
+	// children are removed before their parent, whichever generation or
+	// generator recorded them
+	dirs := []string{ManifestDir}
+	for _, entry := range manifest.Entries {
+		if entry.Kind == ActionDir {
+			dirs = append(dirs, entry.Path)
+		}
+	}
+	sort.SliceStable(dirs, func(i, j int) bool {
+		return strings.Count(dirs[i], "/") < strings.Count(dirs[j], "/")
+	})
+	for i := len(dirs) - 1; i >= 0; i-- {
+		err := fsys.Remove(dirs[i])
+		if errors.Is(err, fs.ErrExist) || errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return result, err
+		}
+		if dirs[i] != ManifestDir {
+			result.Removed = append(result.Removed, path.Clean(dirs[i])+"/")
+		}
+	}
+
+	return result, nil
+}
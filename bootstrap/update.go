@@ -0,0 +1,337 @@
+package bootstrap
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"reflect"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/paoloanzn/go-bootstrap/config"
+	"github.com/paoloanzn/go-bootstrap/diff"
+	"github.com/paoloanzn/go-bootstrap/format"
+	"github.com/paoloanzn/go-bootstrap/parsing"
+)
+
+// UpdateFS is an FS that can also read and remove files, as needed to update
+// a project in place.
+type UpdateFS interface {
+	FS
+	ReadFile(name string) ([]byte, error)
+	// Remove removes a file or an empty directory.
+	Remove(name string) error
+}
+
+type UpdateKind string
+
+const (
+	UpdateAdd      UpdateKind = "add"      // new in the template
+	UpdateReplace  UpdateKind = "update"   // changed in the template only
+	UpdateMerge    UpdateKind = "merge"    // changed on both sides, merged cleanly
+	UpdateConflict UpdateKind = "conflict" // changed differently on both sides
+	UpdateRemove   UpdateKind = "remove"   // removed from the template, unchanged locally
+	UpdateKeep     UpdateKind = "keep"     // left as it is, see Reason
+)
+
+// UpdateAction is a change of an UpdatePlan. Paths are relative to the
+// project root and directory paths end with a slash.
+type UpdateAction struct {
+	Kind    UpdateKind
+	Path    string
+	Content string
+	Merge   *diff.Merge // set for conflicts
+	Reason  string
+}
+
+// UpdatePlan lists the changes that bring a project to a newer version of
+// its template.
+type UpdatePlan struct {
+	Actions []UpdateAction
+
+	// plan is the generation of the newer template, recorded in the
+	// manifest once applied
+	plan *Plan
+	// prune lists the directories removed from the template, deepest first
+	prune []string
+	// recorded holds the files left as they are although next renders them
+	// differently, whose manifest entry is kept
+	recorded map[string]bool
+}
+
+// PlanUpdate compares the project in fsys, generated from base as recorded in
+// manifest, with a generation of next using the same variables, overridden by
+// the variables of cfg. Each file is merged three ways, between what was
+// generated, what the project holds and what next generates. base may be nil
+// when the original template is no longer available: files unchanged since
+// the generation are then still updated, the others conflict. The same holds
+// for files base does not render again as recorded in manifest.
+//
+// Both templates are rendered with the same date, secrets and random
+// variables, so that unchanged files using them are left as they are rather
+// than rendered with new values.
+func PlanUpdate(fsys UpdateFS, cfg *config.Config, manifest *Manifest, base, next *parsing.JSONTemplate) (*UpdatePlan, error) {
+	run := cfg.Clone()
+	run.RunBuiltins = format.RunBuiltins()
+
+	nextCfg := updateConfig(run, manifest, next)
+	generated := make(map[string]string)
+	if base != nil {
+		basePlan, err := BuildPlan(updateConfig(run, manifest, base), base)
+		if err != nil {
+			return nil, fmt.Errorf("%v (rendering %s)", err, manifest.Template.Source)
+		}
+		for _, action := range basePlan.Actions {
+			if action.Kind == ActionFile {
+				generated[action.Path] = action.Content
+			}
+		}
+
+		for name, value := range basePlan.random {
+			if _, set := nextCfg.Variables[name]; !set && reflect.DeepEqual(base.Variables[name], next.Variables[name]) {
+				nextCfg.Variables[name] = value
+			}
+		}
+	}
+
+	nextPlan, err := BuildPlan(nextCfg, next)
+	if err != nil {
+		return nil, err
+	}
+
+	u := &UpdatePlan{plan: nextPlan, recorded: make(map[string]bool)}
+	planned := make(map[string]bool)
+
+	for _, action := range nextPlan.Actions {
+		planned[strings.TrimSuffix(action.Path, "/")] = true
+
+		switch action.Kind {
+		case ActionDir:
+			found, err := exists(fsys, action.Path)
+			if err != nil {
+				return nil, err
+			}
+			if !found {
+				u.Actions = append(u.Actions, UpdateAction{Kind: UpdateAdd, Path: action.Path})
+			}
+		case ActionFile:
+			err := u.planFile(fsys, manifest, action, generated)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// paths added by generators are left to the project
+	for _, entry := range manifest.Entries {
+		if planned[entry.Path] || entry.Generator != "" {
+			continue
+		}
+
+		if entry.Kind == ActionDir {
+			u.prune = append(u.prune, entry.Path)
+			continue
+		}
+
+		current, err := fsys.ReadFile(entry.Path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if Checksum(current) == entry.SHA256 {
+			u.Actions = append(u.Actions, UpdateAction{Kind: UpdateRemove, Path: entry.Path})
+		} else {
+			u.Actions = append(u.Actions, UpdateAction{Kind: UpdateKeep, Path: entry.Path, Reason: "removed from the template, modified locally"})
+		}
+	}
+
+	sort.Sort(sort.Reverse(sort.StringSlice(u.prune)))
+	return u, nil
+}
+
+func (u *UpdatePlan) planFile(fsys UpdateFS, manifest *Manifest, action Action, generated map[string]string) error {
+	entry, tracked := manifest.Entry(action.Path)
+
+	data, err := fsys.ReadFile(action.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		if tracked {
+			u.Actions = append(u.Actions, UpdateAction{Kind: UpdateKeep, Path: action.Path, Reason: "removed locally"})
+		} else {
+			u.Actions = append(u.Actions, UpdateAction{Kind: UpdateAdd, Path: action.Path, Content: action.Content})
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	current := string(data)
+	if current == action.Content {
+		return nil
+	}
+
+	// a file the template did not change differs only by local edits and by
+	// the values drawn for the run, such as secrets: it is left as it is,
+	// along with its manifest entry
+	original, rendered := generated[action.Path]
+	if tracked && rendered && original == action.Content {
+		u.recorded[action.Path] = true
+		if Checksum(data) != entry.SHA256 {
+			u.Actions = append(u.Actions, UpdateAction{Kind: UpdateKeep, Path: action.Path, Reason: "modified locally, unchanged in the template"})
+		}
+		return nil
+	}
+
+	if tracked && Checksum(data) == entry.SHA256 {
+		u.Actions = append(u.Actions, UpdateAction{Kind: UpdateReplace, Path: action.Path, Content: action.Content})
+		return nil
+	}
+
+	// the original template only gives the generated content when it renders
+	// it again identically, e.g. not when it was edited in place since
+	if !tracked || Checksum([]byte(original)) != entry.SHA256 {
+		original = ""
+	}
+
+	// without the generated content, the whole file conflicts
+	m := diff.Merge3(original, current, action.Content)
+	if len(m.Conflicts) > 0 {
+		u.Actions = append(u.Actions, UpdateAction{Kind: UpdateConflict, Path: action.Path, Merge: m})
+	} else {
+		u.Actions = append(u.Actions, UpdateAction{Kind: UpdateMerge, Path: action.Path, Content: m.Resolved()})
+	}
+
+	return nil
+}
+
+// updateConfig returns the configuration generating t again for the project
+// of manifest. Variables t derives are derived again rather than taken from
+// the manifest, so that their expressions can change.
+func updateConfig(cfg *config.Config, manifest *Manifest, t *parsing.JSONTemplate) *config.Config {
+	c := cfg.Clone()
+	c.ProjectName = manifest.Name
+	c.InPlace = true
+
+	c.Variables = make(map[string]interface{})
+	for name, value := range manifest.Variables {
+		if t.Variables[name].Expr == "" {
+			c.Variables[name] = value
+		}
+	}
+	for name, value := range cfg.Variables {
+		c.Variables[name] = value
+	}
+
+	return c
+}
+
+// Count returns the number of actions of kind.
+func (u *UpdatePlan) Count(kind UpdateKind) int {
+	n := 0
+	for _, action := range u.Actions {
+		if action.Kind == kind {
+			n++
+		}
+	}
+
+	return n
+}
+
+// Apply writes the changes of u to fsys and records the newer template in the
+// manifest. Conflicts are written between conflict markers or, with reject,
+// the current lines are kept and the conflicts are written to a .rej file
+// next to the file. Directories removed from the template are removed once
+// empty.
+func (u *UpdatePlan) Apply(fsys UpdateFS, reject bool) error {
+	for _, action := range u.Actions {
+		var err error
+
+		switch {
+		case action.Kind == UpdateAdd && strings.HasSuffix(action.Path, "/"):
+			err = fsys.Mkdir(strings.TrimSuffix(action.Path, "/"), 0755)
+		case action.Kind == UpdateAdd, action.Kind == UpdateReplace, action.Kind == UpdateMerge:
+			err = fsys.WriteFile(action.Path, []byte(action.Content), 0644)
+		case action.Kind == UpdateConflict && reject:
+			err = fsys.WriteFile(action.Path, []byte(action.Merge.Resolved()), 0644)
+			if err == nil {
+				err = fsys.WriteFile(action.Path+".rej", []byte(rejects(action.Merge)), 0644)
+			}
+		case action.Kind == UpdateConflict:
+			err = fsys.WriteFile(action.Path, []byte(action.Merge.Text("current", "template")), 0644)
+		case action.Kind == UpdateRemove:
+			err = fsys.Remove(action.Path)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, dir := range u.prune {
+		err := fsys.Remove(dir)
+		if err != nil && !errors.Is(err, fs.ErrExist) && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+
+	// the manifest now records the newer generation
+	result := &Result{}
+	for _, action := range u.plan.Actions {
+		if action.Kind != ActionSkip && !u.recorded[action.Path] {
+			result.Created = append(result.Created, action.Path)
+		}
+	}
+
+	err := writeManifest(fsys, u.plan, result)
+	if err != nil {
+		return fmt.Errorf("Failed to write the manifest: %v", err)
+	}
+
+	return nil
+}
+
+// rejects formats the conflicts of m as written to .rej files.
+func rejects(m *diff.Merge) string {
+	var sb strings.Builder
+	for _, conflict := range m.Conflicts {
+		fmt.Fprintf(&sb, "@@ line %d @@\n", conflict.Line)
+		sb.WriteString(conflict.Markers("current", "template"))
+	}
+
+	return sb.String()
+}
+
+// Print writes a human readable version of the update, as shown by dry runs.
+func (u *UpdatePlan) Print(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	for _, action := range u.Actions {
+		switch action.Kind {
+		case UpdateKeep:
+			fmt.Fprintf(tw, "%s\t%s (%s)\n", action.Kind, action.Path, action.Reason)
+		case UpdateConflict:
+			fmt.Fprintf(tw, "%s\t%s (%d conflicts)\n", action.Kind, action.Path, len(action.Merge.Conflicts))
+		default:
+			fmt.Fprintf(tw, "%s\t%s\n", action.Kind, action.Path)
+		}
+	}
+
+	return tw.Flush()
+}
+
+// ReadManifest reads the manifest of the project at the root of fsys.
+func ReadManifest(fsys UpdateFS) (*Manifest, error) {
+	data, err := fsys.ReadFile(ManifestPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("No %s found: the project was not generated by %s, or before manifests were recorded.", ManifestPath, config.AppName)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseManifest(data)
+}
@@ -0,0 +1,236 @@
+package bootstrap
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/paoloanzn/go-bootstrap/config"
+	"github.com/paoloanzn/go-bootstrap/expr"
+	"github.com/paoloanzn/go-bootstrap/format"
+	"github.com/paoloanzn/go-bootstrap/parsing"
+)
+
+// ResolveVariables fills cfg.Variables with the values of the template
+// variables that were not supplied: their default, with wildcards matched so
+// that a default can refer to <main_package> or to other variables, or the
+// value of their expression. Variables are resolved after the ones they refer
+// to, and cycles are errors.
+//
+// Supplied values are converted to the type of the default, so that
+// -var with_docker=false is a boolean.
+func ResolveVariables(cfg *config.Config, pJsonTemplate *parsing.JSONTemplate) error {
+	if cfg.Variables == nil {
+		cfg.Variables = make(map[string]interface{})
+	}
+
+	// pending maps the variables left to resolve to the ones they refer to
+	pending := make(map[string][]string)
+
+	for name, variable := range pJsonTemplate.Variables {
+		if value, exists := cfg.Variables[name]; exists {
+			converted, err := convertVariable(value, variable.Default)
+			if err != nil {
+				return fmt.Errorf("Invalid value for template variable %s: %v", name, err)
+			}
+			cfg.Variables[name] = converted
+			continue
+		}
+
+		switch {
+		case variable.Expr != "":
+			e, err := expr.Parse(variable.Expr)
+			if err != nil {
+				return fmt.Errorf("%v (variable %s)", err, name)
+			}
+			pending[name] = expr.Variables(e)
+		case variable.Default == nil && variable.Secret:
+			return fmt.Errorf("Missing value for secret template variable %s: secrets are not recorded in the manifest, give it again on every run.", name)
+		case variable.Default == nil:
+			return fmt.Errorf("Missing value for template variable %s.", name)
+		default:
+			s, _ := variable.Default.(string)
+			pending[name] = format.WildCardNames(s)
+		}
+	}
+
+	names := make([]string, 0, len(pending))
+	for name := range pending {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	r := &resolver{cfg: cfg, template: pJsonTemplate, pending: pending, visiting: make(map[string]bool)}
+	for _, name := range names {
+		err := r.resolve(name, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type resolver struct {
+	cfg      *config.Config
+	template *parsing.JSONTemplate
+	pending  map[string][]string
+	visiting map[string]bool
+}
+
+// resolve resolves the variables name refers to, then name itself. path is
+// the chain of variables that led to name, reported on cycles.
+func (r *resolver) resolve(name string, path []string) error {
+	dependencies, exists := r.pending[name]
+	if !exists {
+		return nil // supplied, already resolved or not a template variable
+	}
+
+	path = append(path, name)
+	if r.visiting[name] {
+		return fmt.Errorf("Template variables refer to each other in a cycle: %s.", strings.Join(path, " -> "))
+	}
+	r.visiting[name] = true
+
+	for _, dependency := range dependencies {
+		err := r.resolve(dependency, path)
+		if err != nil {
+			return err
+		}
+	}
+
+	variable := r.template.Variables[name]
+	value := variable.Default
+	if variable.Expr != "" {
+		var err error
+		value, err = expr.Eval(variable.Expr, conditionEnv(r.cfg))
+		if err != nil {
+			return fmt.Errorf("Error evaluating template variable %s: %v", name, err)
+		}
+	} else if s, ok := value.(string); ok {
+		value = format.MatchWildCards(r.cfg, s)
+	}
+
+	r.cfg.Variables[name] = value
+	delete(r.pending, name)
+
+	return nil
+}
+
+// publicVariables returns the resolved variables of cfg, without the secret
+// ones, see secretVariables.
+func publicVariables(cfg *config.Config, pJsonTemplate *parsing.JSONTemplate) map[string]interface{} {
+	secret := secretVariables(pJsonTemplate)
+
+	public := make(map[string]interface{}, len(cfg.Variables))
+	for name, value := range cfg.Variables {
+		if !secret[name] {
+			public[name] = value
+		}
+	}
+
+	return public
+}
+
+// secretVariables returns the variables of t whose value is or derives from a
+// secret: the ones declared secret, the ones drawn by random_hex or
+// random_base64, and the ones referring to a secret_* builtin or to another
+// secret variable, in their expression or in the wildcards of their default.
+func secretVariables(t *parsing.JSONTemplate) map[string]bool {
+	secret := make(map[string]bool)
+	visited := make(map[string]bool)
+
+	var visit func(name string) bool
+	visit = func(name string) bool {
+		variable, exists := t.Variables[name]
+		if !exists {
+			return strings.HasPrefix(name, "secret_")
+		}
+		if visited[name] {
+			return secret[name] // cycles are reported when resolving
+		}
+		visited[name] = true
+
+		var references []string
+		if variable.Expr != "" {
+			e, err := expr.Parse(variable.Expr)
+			if err != nil {
+				return false
+			}
+			if drawsRandom(e) {
+				secret[name] = true
+			}
+			references = expr.Variables(e)
+		} else if s, ok := variable.Default.(string); ok {
+			references = format.WildCardNames(s)
+		}
+
+		if variable.Secret {
+			secret[name] = true
+		}
+		for _, reference := range references {
+			if visit(reference) {
+				secret[name] = true
+			}
+		}
+
+		return secret[name]
+	}
+
+	for name := range t.Variables {
+		visit(name)
+	}
+
+	return secret
+}
+
+// randomVariables returns the values of the variables of t whose expression
+// draws random values, so that another render of the same run can reuse them.
+func randomVariables(cfg *config.Config, t *parsing.JSONTemplate) map[string]interface{} {
+	random := make(map[string]interface{})
+	for name, variable := range t.Variables {
+		e, err := expr.Parse(variable.Expr)
+		if variable.Expr != "" && err == nil && drawsRandom(e) {
+			random[name] = cfg.Variables[name]
+		}
+	}
+
+	return random
+}
+
+// drawsRandom reports whether e calls random_hex or random_base64.
+func drawsRandom(e expr.Expr) bool {
+	for _, function := range expr.Functions(e) {
+		if strings.HasPrefix(function, "random_") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// convertVariable converts a value supplied as a string to the type of like.
+func convertVariable(value interface{}, like interface{}) (interface{}, error) {
+	s, ok := value.(string)
+	if !ok {
+		return value, nil
+	}
+
+	switch like.(type) {
+	case bool:
+		return strconv.ParseBool(s)
+	case float64:
+		return strconv.ParseFloat(s, 64)
+	case []interface{}:
+		items := []interface{}{}
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		return items, nil
+	default:
+		return s, nil
+	}
+}
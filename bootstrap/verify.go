@@ -0,0 +1,156 @@
+package bootstrap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	goformat "go/format"
+	"go/scanner"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/paoloanzn/go-bootstrap/config"
+	"github.com/paoloanzn/go-bootstrap/diff"
+)
+
+// formatGo returns the Go source content gofmt-ed. Syntax errors quote the
+// line they are on.
+func formatGo(content string) (string, error) {
+	formatted, err := goformat.Source([]byte(content))
+	if err == nil {
+		return string(formatted), nil
+	}
+
+	var list scanner.ErrorList
+	if !errors.As(err, &list) || len(list) == 0 {
+		return "", err
+	}
+
+	first := list[0]
+	lines := diff.Lines(content)
+	if first.Pos.Line < 1 || first.Pos.Line > len(lines) {
+		return "", fmt.Errorf("line %d: %s", first.Pos.Line, first.Msg)
+	}
+
+	return "", fmt.Errorf("line %d: %s: %q", first.Pos.Line, first.Msg, strings.TrimRight(lines[first.Pos.Line-1], "\n"))
+}
+
+// VerifyBuild checks that the Go project at the root of fsys builds and
+// passes go vet. The project is copied to a temporary directory first, so
+// the commands cannot touch it. Projects without a go.mod file, or whose
+// go.mod declares no module, get one declaring the module name, e.g. the
+// Name of the plan, made a valid module path.
+//
+// The copy is not otherwise sandboxed: the go command downloads the modules
+// the project requires, through GOPROXY, unless offline restricts it to the
+// module cache.
+func VerifyBuild(ctx context.Context, cfg *config.Config, fsys fs.FS, name string, offline bool) error {
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		return fmt.Errorf("Cannot verify the build: %v", err)
+	}
+
+	dir, err := os.MkdirTemp("", "go-bootstrap-verify-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	err = copyTree(fsys, dir)
+	if err != nil {
+		return fmt.Errorf("Cannot verify the build: %v", err)
+	}
+
+	goMod := filepath.Join(dir, "go.mod")
+	data, err := os.ReadFile(goMod)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if !declaresModule(data) {
+		data = append([]byte(fmt.Sprintf("module %s\n", modulePath(name))), data...)
+		err = os.WriteFile(goMod, data, 0644)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, command := range []string{"build", "vet"} {
+		cmd := exec.CommandContext(ctx, goBin, command, "./...")
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GOWORK=off", "GOFLAGS=-mod=mod")
+		if offline {
+			cmd.Env = append(cmd.Env, "GOPROXY=off")
+		}
+
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("The generated project fails go %s ./...: %v\n%s", command, err, strings.TrimSpace(string(output)))
+		}
+	}
+
+	cfg.Logf("Verified that the project builds and passes go vet\n")
+	return nil
+}
+
+// declaresModule reports whether the go.mod content data has a module
+// directive.
+func declaresModule(data []byte) bool {
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 0 && fields[0] == "module" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// modulePath turns name into a module path, replacing the characters module
+// paths cannot hold with dashes.
+func modulePath(name string) string {
+	path := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', strings.ContainsRune("-._~/", r):
+			return r
+		default:
+			return '-'
+		}
+	}, name)
+
+	path = strings.Trim(path, "-./")
+	if path == "" {
+		return "project"
+	}
+	return path
+}
+
+// copyTree copies the files and directories of fsys into dir, leaving out
+// the .git directory.
+func copyTree(fsys fs.FS, dir string) error {
+	return fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return fs.SkipDir
+			}
+			return os.MkdirAll(target, 0755)
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+
+		return os.WriteFile(target, data, 0644)
+	})
+}
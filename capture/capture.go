@@ -0,0 +1,214 @@
+package capture
+
+import (
+	"bytes"
+	"fmt"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/paoloanzn/go-bootstrap/bootstrap"
+	"github.com/paoloanzn/go-bootstrap/format"
+	"github.com/paoloanzn/go-bootstrap/parsing"
+)
+
+type Options struct {
+	// ProjectName is replaced with <main_package> in paths and contents.
+	// Defaults to the base name of the captured directory.
+	ProjectName string
+
+	// WithContents embeds the content of text files in the template.
+	// Binary files are always captured as empty files.
+	WithContents bool
+
+	// Exclude lists extra paths, relative to the captured directory, to skip.
+	Exclude []string
+
+	// AnyName allows project names that are likely to appear in the project
+	// for other reasons, such as go, a keyword or a predeclared identifier.
+	AnyName bool
+}
+
+// minNameLength is the length under which project names are refused without
+// Options.AnyName.
+const minNameLength = 3
+
+// Capture walks root and builds a template reproducing its structure.
+// .gitignore files are honored and the .git directory is always skipped.
+func Capture(root string, opts Options) (*parsing.JSONTemplate, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory.", root)
+	}
+
+	if opts.ProjectName == "" {
+		abs, err := filepath.Abs(root)
+		if err != nil {
+			return nil, err
+		}
+		opts.ProjectName = filepath.Base(abs)
+	}
+	if !opts.AnyName && commonName(opts.ProjectName) {
+		return nil, fmt.Errorf("The project name %q is too common to be replaced with %s safely: give another one with -name, or allow it with -any-name.", opts.ProjectName, format.MainPackage)
+	}
+
+	c := &capturer{opts: opts, exclude: make(map[string]bool)}
+	for _, e := range opts.Exclude {
+		c.exclude[filepath.ToSlash(filepath.Clean(e))] = true
+	}
+
+	project, err := c.walk(root, "", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &parsing.JSONTemplate{
+		Project: project,
+		Config: map[string]interface{}{
+			"name": opts.ProjectName,
+		},
+	}, nil
+}
+
+type capturer struct {
+	opts    Options
+	exclude map[string]bool
+}
+
+func (c *capturer) walk(dir string, rel string, rules []ignoreRule) (map[string]interface{}, error) {
+	local, err := loadIgnoreFile(dir, rel)
+	if err != nil {
+		return nil, err
+	}
+	rules = append(rules[:len(rules):len(rules)], local...)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	node := make(map[string]interface{})
+	for _, entry := range entries {
+		name := entry.Name()
+		entryRel := name
+		if rel != "" {
+			entryRel = rel + "/" + name
+		}
+
+		if entry.IsDir() && (name == ".git" || name == bootstrap.ManifestDir) {
+			continue
+		}
+		if c.exclude[entryRel] || isIgnored(rules, entryRel, entry.IsDir()) {
+			continue
+		}
+
+		key := c.replaceName(name)
+		if _, exists := node[key]; exists {
+			return nil, fmt.Errorf("Capturing %s: %s collides with another entry once the project name is replaced.", entryRel, key)
+		}
+
+		fullPath := filepath.Join(dir, name)
+		switch {
+		case entry.IsDir():
+			child, err := c.walk(fullPath, entryRel, rules)
+			if err != nil {
+				return nil, err
+			}
+			node[key] = child
+		case entry.Type().IsRegular():
+			value, err := c.file(fullPath)
+			if err != nil {
+				return nil, err
+			}
+			node[key] = value
+		default:
+			// symlinks, sockets and devices have no template representation
+		}
+	}
+
+	return node, nil
+}
+
+func (c *capturer) file(path string) (interface{}, error) {
+	if !c.opts.WithContents {
+		return parsing.FileNode, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) == 0 || !isText(data) {
+		return parsing.FileNode, nil
+	}
+
+	return parsing.NewFile(c.replaceName(string(data))), nil
+}
+
+// replaceName replaces the occurrences of the project name in s that are a
+// whole word, e.g. a path element or an identifier, so that "acme" is replaced
+// in "github.com/acme/acme" and "acme.go" but not in "acmecorp".
+func (c *capturer) replaceName(s string) string {
+	name := c.opts.ProjectName
+	if name == "" {
+		return s
+	}
+
+	var sb strings.Builder
+	start := 0
+	for offset := 0; offset < len(s); {
+		i := strings.Index(s[offset:], name)
+		if i < 0 {
+			break
+		}
+
+		i += offset
+		end := i + len(name)
+		if isBoundary(s[:i], true) && isBoundary(s[end:], false) {
+			sb.WriteString(s[start:i])
+			sb.WriteString(format.MainPackage)
+			start, offset = end, end
+		} else {
+			offset = i + 1
+		}
+	}
+	sb.WriteString(s[start:])
+
+	return sb.String()
+}
+
+// isBoundary reports whether a word can end at the end of before, or start at
+// the start of after.
+func isBoundary(s string, before bool) bool {
+	var r rune
+	if before {
+		r, _ = utf8.DecodeLastRuneInString(s)
+	} else {
+		r, _ = utf8.DecodeRuneInString(s)
+	}
+
+	return r == utf8.RuneError || !isWordRune(r)
+}
+
+// isWordRune reports whether r can be part of a project name.
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-'
+}
+
+// commonName reports whether name is short or a word of Go itself, likely to
+// appear in the project for other reasons than naming it.
+func commonName(name string) bool {
+	return len(name) < minNameLength || token.IsKeyword(name) || types.Universe.Lookup(name) != nil
+}
+
+func isText(data []byte) bool {
+	return utf8.Valid(data) && bytes.IndexByte(data, 0) < 0
+}
@@ -0,0 +1,195 @@
+package capture
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paoloanzn/go-bootstrap/parsing"
+)
+
+// writeTree creates the given files (path -> content) under a new temp dir.
+func writeTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	root := t.TempDir()
+	for path, content := range files {
+		fullPath := filepath.Join(root, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			t.Fatalf("Failed to create parent dir: %v", err)
+		}
+		if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+
+	return root
+}
+
+// TestCaptureStructure checks that the directory tree is captured, the project
+// name is replaced in paths and ignored entries are skipped.
+func TestCaptureStructure(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		".gitignore":        "build/\n*.log\n!keep.log\n",
+		"cmd/acme/main.go":  "package main\n",
+		"build/acme":        "binary",
+		"debug.log":         "noise",
+		"keep.log":          "kept",
+		"internal/.gitkeep": "",
+		".git/HEAD":         "ref: refs/heads/main\n",
+	})
+
+	jsonTemplate, err := Capture(root, Options{ProjectName: "acme"})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if jsonTemplate.Config["name"] != "acme" {
+		t.Errorf("Expected config.name to be 'acme', got %v", jsonTemplate.Config["name"])
+	}
+
+	project := jsonTemplate.Project.(map[string]interface{})
+	cmd := project["cmd"].(map[string]interface{})
+	main, exists := cmd["<main_package>"].(map[string]interface{})
+	if !exists {
+		t.Fatalf("Expected cmd/<main_package> directory, got %v", cmd)
+	}
+	if main["main.go"] != parsing.FileNode {
+		t.Errorf("Expected main.go to be a plain file, got %v", main["main.go"])
+	}
+
+	for _, name := range []string{"build", "debug.log", ".git"} {
+		if _, exists := project[name]; exists {
+			t.Errorf("Expected %s to be skipped", name)
+		}
+	}
+	for _, name := range []string{"keep.log", ".gitignore", "internal"} {
+		if _, exists := project[name]; !exists {
+			t.Errorf("Expected %s to be captured", name)
+		}
+	}
+}
+
+// TestCaptureContents checks that text contents are embedded with the project
+// name replaced, while binary files stay empty.
+func TestCaptureContents(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"go.mod":   "module github.com/acme/acme\n",
+		"logo.png": "\x89PNG\x00\x01",
+	})
+
+	jsonTemplate, err := Capture(root, Options{ProjectName: "acme", WithContents: true})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	project := jsonTemplate.Project.(map[string]interface{})
+	content, err := parsing.FileContent(project["go.mod"])
+	if err != nil {
+		t.Fatalf("Expected no error reading content, got: %v", err)
+	}
+	expected := "module github.com/<main_package>/<main_package>\n"
+	if content != expected {
+		t.Errorf("Expected content %q, got %q", expected, content)
+	}
+
+	if project["logo.png"] != parsing.FileNode {
+		t.Errorf("Expected binary file to be captured without content, got %v", project["logo.png"])
+	}
+}
+
+// TestCaptureShortName checks that a short, common project name is only
+// replaced as a whole word, and that names Go itself uses are refused.
+func TestCaptureShortName(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"go.mod":         "module github.com/acme/api\n\ngo 1.22\n",
+		"cmd/api/api.go": "package main\n\nimport \"github.com/acme/api/internal/rapid\"\n\nvar apiVersion = rapid.Version\n",
+	})
+
+	jsonTemplate, err := Capture(root, Options{ProjectName: "api", WithContents: true})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	project := jsonTemplate.Project.(map[string]interface{})
+	tests := []struct {
+		node     interface{}
+		expected string
+	}{
+		{project["go.mod"], "module github.com/acme/<main_package>\n\ngo 1.22\n"},
+		{project["cmd"].(map[string]interface{})["<main_package>"].(map[string]interface{})["<main_package>.go"], "package main\n\nimport \"github.com/acme/<main_package>/internal/rapid\"\n\nvar apiVersion = rapid.Version\n"},
+	}
+	for _, tt := range tests {
+		content, err := parsing.FileContent(tt.node)
+		if err != nil {
+			t.Fatalf("Expected no error reading content, got: %v", err)
+		}
+		if content != tt.expected {
+			t.Errorf("Expected content %q, got %q", tt.expected, content)
+		}
+	}
+
+	for _, name := range []string{"go", "string", "type"} {
+		if _, err := Capture(root, Options{ProjectName: name}); err == nil {
+			t.Errorf("Expected an error for the project name %q, got nil", name)
+		}
+	}
+	if _, err := Capture(root, Options{ProjectName: "go", AnyName: true}); err != nil {
+		t.Errorf("Expected -any-name to allow the project name go, got: %v", err)
+	}
+}
+
+// TestCaptureNotADirectory checks the error returned for a regular file.
+func TestCaptureNotADirectory(t *testing.T) {
+	root := writeTree(t, map[string]string{"file.txt": ""})
+
+	_, err := Capture(filepath.Join(root, "file.txt"), Options{})
+	if err == nil {
+		t.Errorf("Expected an error when capturing a file, got nil")
+	}
+}
+
+// TestIsIgnored covers the supported subset of the gitignore syntax.
+func TestIsIgnored(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		base     string
+		path     string
+		isDir    bool
+		expected bool
+	}{
+		{"*.log", "", "a/b/c.log", false, true},
+		{"/vendor", "", "vendor", true, true},
+		{"/vendor", "", "a/vendor", true, false},
+		{"bin/", "", "bin", false, false},
+		{"bin/", "", "bin", true, true},
+		{"docs/**/*.md", "", "docs/a/b/c.md", false, true},
+		{"**/tmp", "", "a/tmp", true, true},
+		{"local.txt", "sub", "sub/local.txt", false, true},
+		{"local.txt", "sub", "local.txt", false, false},
+		{"file[0-9].txt", "", "file7.txt", false, true},
+		{"[]a]", "", "]", false, true},
+		{"[]a]", "", "a", false, true},
+		{"[!]a]x", "", "bx", false, true},
+		{"[!]a]x", "", "]x", false, false},
+		{"[abc", "", "[abc", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+"/"+tt.path, func(t *testing.T) {
+			rule, ok := parseIgnoreLine(tt.pattern, tt.base)
+			if !ok {
+				t.Fatalf("Expected pattern %q to be parsed", tt.pattern)
+			}
+			result := isIgnored([]ignoreRule{rule}, tt.path, tt.isDir)
+			if result != tt.expected {
+				t.Errorf("isIgnored(%q, %q) = %v; want %v", tt.pattern, tt.path, result, tt.expected)
+			}
+		})
+	}
+
+	// patterns that cannot be translated are skipped instead of panicking
+	if _, ok := parseIgnoreLine("[z-a]", ""); ok {
+		t.Errorf("Expected the invalid range [z-a] to be skipped")
+	}
+}
@@ -0,0 +1,162 @@
+package capture
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+type ignoreRule struct {
+	re       *regexp.Regexp
+	base     string // directory of the .gitignore, relative to the root
+	negate   bool
+	dirOnly  bool
+	basename bool // pattern has no slash and matches at any depth
+}
+
+// loadIgnoreFile reads the .gitignore in dir, if any. base is the path of dir
+// relative to the captured root, using forward slashes.
+func loadIgnoreFile(dir string, base string) ([]ignoreRule, error) {
+	f, err := os.Open(filepath.Join(dir, ".gitignore"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		rule, ok := parseIgnoreLine(scanner.Text(), base)
+		if ok {
+			rules = append(rules, rule)
+		}
+	}
+
+	return rules, scanner.Err()
+}
+
+func parseIgnoreLine(line string, base string) (ignoreRule, bool) {
+	rule := ignoreRule{base: base}
+
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return rule, false
+	}
+
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\`) {
+		line = line[1:] // escaped leading '#' or '!'
+	}
+
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+
+	if line == "" {
+		return rule, false
+	}
+
+	rule.basename = !strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+
+	// patterns git accepts but we cannot translate are skipped rather than
+	// failing the capture
+	re, err := regexp.Compile("^" + globToRegexp(line) + "$")
+	if err != nil {
+		return rule, false
+	}
+
+	rule.re = re
+	return rule, true
+}
+
+// globToRegexp translates a gitignore glob into a regular expression.
+func globToRegexp(glob string) string {
+	var b strings.Builder
+
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			b.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "/**") && i+3 == len(glob):
+			b.WriteString("/.*")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			// a ']' right after '[' or '[!' is part of the class
+			start := i + 1
+			if start < len(glob) && glob[start] == '!' {
+				start++
+			}
+			if start < len(glob) && glob[start] == ']' {
+				start++
+			}
+			end := strings.IndexByte(glob[start:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : start+end]
+			b.WriteString("[")
+			if strings.HasPrefix(class, "!") {
+				b.WriteString("^")
+				class = class[1:]
+			}
+			b.WriteString(strings.ReplaceAll(class, "]", `\]`))
+			b.WriteString("]")
+			i = start + end
+		case c == '\\' && i+1 < len(glob):
+			i++
+			b.WriteString(regexp.QuoteMeta(string(glob[i])))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	return b.String()
+}
+
+// isIgnored reports whether relPath, relative to the captured root, is
+// excluded by rules. The last matching rule wins, as in git.
+func isIgnored(rules []ignoreRule, relPath string, isDir bool) bool {
+	ignored := false
+
+	for _, rule := range rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+
+		target := relPath
+		if rule.base != "" {
+			if !strings.HasPrefix(relPath, rule.base+"/") {
+				continue
+			}
+			target = strings.TrimPrefix(relPath, rule.base+"/")
+		}
+		if rule.basename {
+			target = target[strings.LastIndex(target, "/")+1:]
+		}
+
+		if rule.re.MatchString(target) {
+			ignored = !rule.negate
+		}
+	}
+
+	return ignored
+}
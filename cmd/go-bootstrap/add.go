@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/paoloanzn/go-bootstrap/bootstrap"
+	"github.com/paoloanzn/go-bootstrap/config"
+	"github.com/paoloanzn/go-bootstrap/loader"
+	"github.com/paoloanzn/go-bootstrap/parsing"
+	"github.com/paoloanzn/go-bootstrap/source"
+)
+
+// generatorNameVariable receives the name given after the generator, as in
+// `add handler orders`.
+const generatorNameVariable = "name"
+
+func runAdd(args []string) error {
+	fs := flag.NewFlagSet("add", flag.ExitOnError)
+	templateRef := fs.String("template", "", "template defining the generator (default: the one the project was generated from)")
+	dir := fs.String("dir", ".", "a directory of the project, whose root is found through its manifest or go.mod")
+	vars := make(varsFlag)
+	fs.Var(vars, "var", "set a generator variable (name=value), can be repeated")
+	offline := fs.Bool("offline", false, "use only cached copies of remote templates, and of modules with -verify-build")
+	dryRun := fs.Bool("dry-run", false, "print what would be created without writing anything")
+	force := fs.Bool("force", false, "overwrite existing files, same as -on-conflict overwrite")
+	onConflict := fs.String("on-conflict", "", "what to do with existing files: skip, overwrite or fail")
+	verifyBuild := fs.Bool("verify-build", false, "check that the project still builds and passes go vet, in a temporary copy")
+
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) < 1 || len(positional) > 2 {
+		return fmt.Errorf("Usage: go-bootstrap add <generator> [name] [-var name=value]... [-template template] [-dir dir] [-force] [-on-conflict policy] [-offline] [-dry-run] [-verify-build]")
+	}
+	if len(positional) == 2 {
+		if _, exists := vars[generatorNameVariable]; !exists {
+			vars[generatorNameVariable] = positional[1]
+		}
+	}
+
+	root, err := findProjectRoot(*dir)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	cfg.Logger = log.New(os.Stdout, "", 0)
+	cfg.OutputDir = root
+	cfg.Variables = make(map[string]interface{})
+
+	var jsonTemplate *parsing.JSONTemplate
+	opts := source.Options{Offline: *offline}
+
+	manifest, err := bootstrap.ReadManifest(bootstrap.NewOSFS(root))
+	switch {
+	case err == nil:
+		cfg.ProjectName = manifest.Name
+		for name, value := range manifest.Variables {
+			cfg.Variables[name] = value
+		}
+
+		ref := *templateRef
+		if ref == "" {
+			ref = manifest.Template.Source
+		}
+		jsonTemplate, err = loadSelected(ref, opts, manifest)
+	case *templateRef != "":
+		cfg.ProjectName = filepath.Base(root)
+		jsonTemplate, err = loader.Load(*templateRef, opts)
+	default:
+		return fmt.Errorf("%v Use -template to name the template defining the generator.", err)
+	}
+	if err != nil {
+		return err
+	}
+
+	for name, value := range vars {
+		cfg.Variables[name] = value
+	}
+
+	switch {
+	case *force && *onConflict != "" && *onConflict != "overwrite":
+		return fmt.Errorf("-force cannot be combined with -on-conflict %s.", *onConflict)
+	case *force:
+		cfg.OnConflict = config.ConflictOverwrite
+	case *onConflict != "":
+		cfg.OnConflict, err = config.ParseConflictPolicy(*onConflict)
+		if err != nil {
+			return err
+		}
+	}
+
+	plan, err := bootstrap.BuildGeneratorPlan(cfg, jsonTemplate, positional[0])
+	if err != nil {
+		return err
+	}
+
+	if *dryRun {
+		return plan.Print(os.Stdout)
+	}
+
+	result, err := bootstrap.Apply(cfg, plan)
+	if err != nil {
+		return err
+	}
+
+	skipped := make(map[string]bool)
+	for _, path := range result.Skipped {
+		skipped[path] = true
+	}
+	for _, action := range plan.Actions {
+		if !skipped[action.Path] || !fileExists(filepath.Join(root, action.Path)) {
+			continue
+		}
+
+		switch action.Kind {
+		case bootstrap.ActionFile:
+			fmt.Printf("Kept %s (exists, use -force to overwrite it)\n", action.Path)
+		case bootstrap.ActionInject:
+			fmt.Printf("Kept %s (already injected)\n", action.Path)
+		case bootstrap.ActionEdit:
+			fmt.Printf("Kept %s (already edited)\n", action.Path)
+		}
+	}
+
+	if *verifyBuild {
+		return bootstrap.VerifyBuild(context.Background(), cfg, os.DirFS(root), plan.Name, *offline)
+	}
+
+	return nil
+}
+
+// findProjectRoot returns the closest directory from dir upwards holding a
+// generation manifest or a go.mod file.
+func findProjectRoot(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+
+	for current := abs; ; current = filepath.Dir(current) {
+		for _, marker := range []string{filepath.FromSlash(bootstrap.ManifestPath), "go.mod"} {
+			if fileExists(filepath.Join(current, marker)) {
+				return current, nil
+			}
+		}
+
+		if filepath.Dir(current) == current {
+			return "", fmt.Errorf("No project found in %s or its parents: expected a %s or go.mod file.", abs, bootstrap.ManifestPath)
+		}
+	}
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// parseArgs parses args with fs, allowing flags to appear after positional
+// arguments (`capture <dir> -o out.json`). It returns the positional arguments.
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+
+	for {
+		err := fs.Parse(args)
+		if err != nil {
+			return nil, err
+		}
+
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// varsFlag collects repeated -var name=value flags.
+type varsFlag map[string]interface{}
+
+func (v varsFlag) String() string {
+	return fmt.Sprint(map[string]interface{}(v))
+}
+
+func (v varsFlag) Set(s string) error {
+	name, value, ok := strings.Cut(s, "=")
+	if !ok || name == "" {
+		return fmt.Errorf("expected name=value, got %q", s)
+	}
+
+	v[name] = value
+	return nil
+}
+
+// listFlag collects repeated flags, also accepting comma-separated values.
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(s string) error {
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*l = append(*l, item)
+		}
+	}
+
+	return nil
+}
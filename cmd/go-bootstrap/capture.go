@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/paoloanzn/go-bootstrap/capture"
+)
+
+func runCapture(args []string) error {
+	fs := flag.NewFlagSet("capture", flag.ExitOnError)
+	output := fs.String("o", "", "write the template to this file instead of stdout")
+	name := fs.String("name", "", "project name to replace with <main_package> (default: directory name)")
+	withContents := fs.Bool("contents", false, "embed the content of text files")
+	anyName := fs.Bool("any-name", false, "replace the project name even when it is short or a Go keyword or identifier")
+
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return fmt.Errorf("Usage: go-bootstrap capture <dir> [-o template.json] [-name name] [-any-name] [-contents]")
+	}
+	dir := positional[0]
+
+	opts := capture.Options{
+		ProjectName:  *name,
+		WithContents: *withContents,
+		AnyName:      *anyName,
+	}
+
+	// never capture the template being written
+	if *output != "" {
+		rel, err := filepath.Rel(dir, *output)
+		if err == nil && filepath.IsLocal(rel) {
+			opts.Exclude = append(opts.Exclude, rel)
+		}
+	}
+
+	jsonTemplate, err := capture.Capture(dir, opts)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(jsonTemplate, "", "    ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	if *output == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+
+	err = os.WriteFile(*output, data, 0644)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Captured %s into %s\n", dir, *output)
+	return nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/paoloanzn/go-bootstrap/bootstrap"
+	"github.com/paoloanzn/go-bootstrap/config"
+	"github.com/paoloanzn/go-bootstrap/loader"
+	"github.com/paoloanzn/go-bootstrap/parsing"
+	"github.com/paoloanzn/go-bootstrap/source"
+)
+
+func runCheck(args []string) error {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	templateRef := fs.String("template", "", "template to check against (default: the one the project was generated from)")
+	vars := make(varsFlag)
+	fs.Var(vars, "var", "set a template variable (name=value), can be repeated")
+	profile := fs.String("profile", "", "apply a profile of the template")
+	var features listFlag
+	fs.Var(&features, "feature", "enable a feature of the template, can be repeated")
+	name := fs.String("name", "", "project name, instead of the recorded one or the directory name")
+	outputFormat := fs.String("format", "text", "report format: text, json or junit")
+	offline := fs.Bool("offline", false, "use only cached copies of remote templates")
+
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 1 {
+		return fmt.Errorf("Usage: go-bootstrap check [dir] [-template template] [-var name=value]... [-profile name] [-feature name]... [-name name] [-format text|json|junit] [-offline]\nExits with status 1 when the project differs from the template, 2 on errors.")
+	}
+
+	dir := "."
+	if len(positional) == 1 {
+		dir = positional[0]
+	}
+
+	var write func(r *bootstrap.CheckReport) error
+	switch *outputFormat {
+	case "text":
+		write = func(r *bootstrap.CheckReport) error { return r.Print(os.Stdout) }
+	case "json":
+		write = func(r *bootstrap.CheckReport) error { return r.WriteJSON(os.Stdout) }
+	case "junit":
+		write = func(r *bootstrap.CheckReport) error { return r.WriteJUnit(os.Stdout) }
+	default:
+		return fmt.Errorf("Unknown format %s: expected text, json or junit.", *outputFormat)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	cfg.Variables = make(map[string]interface{})
+
+	// projects generated with a manifest are checked with their variables
+	manifest, err := bootstrap.ReadManifest(bootstrap.NewOSFS(dir))
+	if err == nil {
+		cfg.ProjectName = manifest.Name
+		for key, value := range manifest.Variables {
+			cfg.Variables[key] = value
+		}
+		if *templateRef == "" {
+			*templateRef = manifest.Template.Source
+		}
+	}
+	for key, value := range vars {
+		cfg.Variables[key] = value
+	}
+
+	if *templateRef == "" {
+		return fmt.Errorf("No template to check against: use -template.")
+	}
+
+	if *name != "" {
+		cfg.ProjectName = *name
+	}
+	if cfg.ProjectName == "" {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return err
+		}
+		cfg.ProjectName = filepath.Base(abs)
+	}
+
+	// without a selection, the recorded one is checked
+	opts := source.Options{Offline: *offline}
+	var jsonTemplate *parsing.JSONTemplate
+	if manifest != nil && *profile == "" && len(features) == 0 {
+		jsonTemplate, err = loadSelected(*templateRef, opts, manifest)
+	} else {
+		jsonTemplate, err = loader.Load(*templateRef, opts)
+		if err == nil {
+			err = loader.Select(jsonTemplate, *profile, features)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	report, err := bootstrap.Check(os.DirFS(dir), cfg, jsonTemplate)
+	if err != nil {
+		return err
+	}
+
+	err = write(report)
+	if err != nil {
+		return err
+	}
+
+	if drift := len(report.Drift()); drift > 0 {
+		return &driftError{fmt.Errorf("%d paths differ from the template.", drift)}
+	}
+
+	return nil
+}
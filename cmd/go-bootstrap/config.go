@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/paoloanzn/go-bootstrap/config"
+)
+
+const configUsage = "Usage: go-bootstrap config <get|set|list> [args]"
+
+func runConfig(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf(configUsage)
+	}
+
+	switch args[0] {
+	case "get":
+		if len(args) != 2 {
+			return fmt.Errorf("Usage: go-bootstrap config get <key>")
+		}
+
+		c, err := config.Load()
+		if err != nil {
+			return err
+		}
+
+		value, err := c.Get(args[1])
+		if err != nil {
+			return err
+		}
+		fmt.Println(value)
+
+	case "set":
+		if len(args) != 3 {
+			return fmt.Errorf("Usage: go-bootstrap config set <key> <value>")
+		}
+
+		// environment overrides are not persisted
+		c, path, err := config.LoadFile()
+		if err != nil {
+			return err
+		}
+
+		err = c.Set(args[1], args[2])
+		if err != nil {
+			return err
+		}
+
+		err = c.Save(path)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Set %s in %s\n", args[1], path)
+
+	case "list":
+		c, err := config.Load()
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, key := range config.Keys() {
+			value, _ := c.Get(key)
+			fmt.Fprintf(w, "%s\t%s\n", key, value)
+		}
+		return w.Flush()
+
+	default:
+		return fmt.Errorf(configUsage)
+	}
+
+	return nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/paoloanzn/go-bootstrap/bootstrap"
+	"github.com/paoloanzn/go-bootstrap/config"
+	"github.com/paoloanzn/go-bootstrap/loader"
+	"github.com/paoloanzn/go-bootstrap/source"
+)
+
+func runDiff(args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	against := fs.String("against", ".", "directory to compare the generated project with")
+	stat := fs.Bool("stat", false, "print a summary of the changed lines per file instead of the diffs")
+	vars := make(varsFlag)
+	fs.Var(vars, "var", "set a template variable (name=value), can be repeated")
+	profile := fs.String("profile", "", "apply a profile of the template")
+	var features listFlag
+	fs.Var(&features, "feature", "enable a feature of the template, can be repeated")
+	name := fs.String("name", "", "name of the project and <main_package>, instead of the directory name")
+	offline := fs.Bool("offline", false, "use only cached copies of remote templates")
+
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return fmt.Errorf("Usage: go-bootstrap diff <template> [-against dir] [-stat] [-var name=value]... [-profile name] [-feature name]... [-name name] [-offline]\nExits with status 1 when the directory differs from the template, 2 on errors.")
+	}
+
+	jsonTemplate, err := loader.Load(positional[0], source.Options{Offline: *offline})
+	if err != nil {
+		return err
+	}
+
+	err = loader.Select(jsonTemplate, *profile, features)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	cfg.Variables = vars
+	cfg.ProjectName = *name
+	cfg.InPlace = true
+
+	if cfg.ProjectName == "" {
+		abs, err := filepath.Abs(*against)
+		if err != nil {
+			return err
+		}
+		cfg.ProjectName = filepath.Base(abs)
+	}
+
+	plan, err := bootstrap.BuildPlan(cfg, jsonTemplate)
+	if err != nil {
+		return err
+	}
+
+	report, err := bootstrap.Compare(os.DirFS(*against), plan)
+	if err != nil {
+		return err
+	}
+
+	if *stat {
+		err = report.PrintStat(os.Stdout)
+	} else {
+		err = report.Print(os.Stdout)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(report.Files) > 0 {
+		return &driftError{fmt.Errorf("%d files differ from the template.", len(report.Files))}
+	}
+
+	return nil
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/paoloanzn/go-bootstrap/bootstrap"
+	"github.com/paoloanzn/go-bootstrap/config"
+	"github.com/paoloanzn/go-bootstrap/loader"
+	"github.com/paoloanzn/go-bootstrap/source"
+)
+
+func runInit(args []string) error {
+	fs := flag.NewFlagSet("init", flag.ExitOnError)
+	vars := make(varsFlag)
+	fs.Var(vars, "var", "set a template variable (name=value), can be repeated")
+	offline := fs.Bool("offline", false, "use only cached copies of remote templates, and of modules with -verify-build")
+	dryRun := fs.Bool("dry-run", false, "print what would be created without writing anything")
+	profile := fs.String("profile", "", "apply a profile of the template")
+	var features listFlag
+	fs.Var(&features, "feature", "enable a feature of the template, can be repeated")
+	output := fs.String("output", "", "create the project in this directory instead of the working directory")
+	fs.StringVar(output, "o", "", "shorthand for -output")
+	name := fs.String("name", "", "name of the project folder and <main_package>, instead of config.name")
+	inPlace := fs.Bool("in-place", false, "generate directly in the output directory, e.g. an existing repository")
+	force := fs.Bool("force", false, "overwrite existing files, same as -on-conflict overwrite")
+	onConflict := fs.String("on-conflict", "", "what to do with existing files: skip, overwrite or fail")
+	outputArchive := fs.String("output-archive", "", "write the project to a .zip or .tar.gz archive instead of the disk")
+	answers := fs.String("answers", "", "read variable values from a JSON file, -var takes precedence")
+	verifyBuild := fs.Bool("verify-build", false, "check that the generated project builds and passes go vet, in a temporary copy")
+
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	cfg.Logger = log.New(os.Stdout, "", 0)
+
+	if len(positional) == 0 && cfg.Template != "" {
+		positional = append(positional, cfg.Template)
+	}
+	if len(positional) != 1 {
+		return fmt.Errorf("Usage: go-bootstrap init <template> [-var name=value]... [-answers file] [-profile name] [-feature name]... [-o dir] [-name name] [-in-place] [-force] [-on-conflict policy] [-offline] [-dry-run] [-output-archive file] [-verify-build]")
+	}
+	if *verifyBuild && (*dryRun || *outputArchive != "") {
+		return fmt.Errorf("-verify-build cannot be combined with -dry-run or -output-archive.")
+	}
+	// an archive is written as a whole, nothing of the disk applies to it
+	if *outputArchive != "" && (*output != "" || *inPlace || *force || *onConflict != "") {
+		return fmt.Errorf("-output-archive cannot be combined with -o, -in-place, -force or -on-conflict.")
+	}
+
+	if *answers != "" {
+		err = readAnswers(*answers, vars)
+		if err != nil {
+			return err
+		}
+	}
+
+	jsonTemplate, err := loader.Load(positional[0], source.Options{Offline: *offline})
+	if err != nil {
+		return err
+	}
+
+	// let the user pick when nothing was requested on the command line
+	hasLayers := len(jsonTemplate.Profiles) > 0 || len(jsonTemplate.Features) > 0
+	if hasLayers && *profile == "" && len(features) == 0 && isInteractive() {
+		*profile, features, err = promptSelection(jsonTemplate, os.Stdin, os.Stdout)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = loader.Select(jsonTemplate, *profile, features)
+	if err != nil {
+		return err
+	}
+
+	cfg.Variables = vars
+	cfg.OutputDir = *output
+	cfg.ProjectName = *name
+	cfg.InPlace = *inPlace
+
+	if cfg.InPlace && cfg.ProjectName == "" {
+		// the project is the directory itself
+		abs, err := filepath.Abs(cfg.OutputDir)
+		if err != nil {
+			return err
+		}
+		cfg.ProjectName = filepath.Base(abs)
+	}
+
+	policySet := *force || *onConflict != ""
+	switch {
+	case *force && *onConflict != "" && *onConflict != "overwrite":
+		return fmt.Errorf("-force cannot be combined with -on-conflict %s.", *onConflict)
+	case *force:
+		cfg.OnConflict = config.ConflictOverwrite
+	case *onConflict != "":
+		cfg.OnConflict, err = config.ParseConflictPolicy(*onConflict)
+		if err != nil {
+			return err
+		}
+	}
+
+	plan, err := bootstrap.BuildPlan(cfg, jsonTemplate)
+	if err != nil {
+		return err
+	}
+
+	if *outputArchive != "" && !*dryRun {
+		return writeArchive(*outputArchive, cfg, plan)
+	}
+
+	if !cfg.InPlace && !policySet {
+		err = checkTarget(filepath.Join(cfg.OutputDir, plan.Root))
+		if err != nil {
+			return err
+		}
+	}
+
+	if *dryRun {
+		return plan.Print(os.Stdout)
+	}
+
+	if cfg.OutputDir != "" {
+		err = os.MkdirAll(cfg.OutputDir, 0755)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = bootstrap.Apply(cfg, plan)
+	if err != nil || !*verifyBuild {
+		return err
+	}
+
+	return bootstrap.VerifyBuild(context.Background(), cfg, os.DirFS(filepath.Join(cfg.OutputDir, plan.Root)), plan.Name, *offline)
+}
+
+// checkTarget refuses to generate into an existing directory that is not
+// empty, where files could silently mix with the generated ones.
+func checkTarget(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(entries) > 0 {
+		return fmt.Errorf("%s already exists and is not empty; use -force to overwrite its files or -on-conflict to choose what to do with them.", dir)
+	}
+
+	return nil
+}
+
+// writeArchive applies plan to a new archive at path, a zip or a gzipped
+// tarball depending on its extension.
+func writeArchive(path string, cfg *config.Config, plan *bootstrap.Plan) error {
+	var newFS func(w io.Writer) *bootstrap.ArchiveFS
+	switch {
+	case strings.HasSuffix(path, ".zip"):
+		newFS = bootstrap.NewZipFS
+	case strings.HasSuffix(path, ".tar.gz"), strings.HasSuffix(path, ".tgz"):
+		newFS = bootstrap.NewTarGzFS
+	default:
+		return fmt.Errorf("Unsupported archive %s: expected a .zip, .tar.gz or .tgz file.", path)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	fsys := newFS(f)
+	_, err = bootstrap.ApplyFS(context.Background(), fsys, cfg, plan)
+	if err == nil {
+		err = fsys.Close()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	// never leave a truncated archive behind
+	if err != nil {
+		os.Remove(path)
+		return err
+	}
+
+	return nil
+}
+
+// readAnswers adds the variable values of the JSON object in path to vars,
+// keeping the values already set on the command line.
+func readAnswers(path string, vars varsFlag) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("Failed to read file: %v", err)
+	}
+
+	var values map[string]interface{}
+	err = json.Unmarshal(data, &values)
+	if err != nil {
+		return fmt.Errorf("Invalid answers file %s: %v", path, err)
+	}
+
+	for name, value := range values {
+		if _, exists := vars[name]; !exists {
+			vars[name] = value
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/paoloanzn/go-bootstrap/bootstrap"
+	"github.com/paoloanzn/go-bootstrap/loader"
+	"github.com/paoloanzn/go-bootstrap/source"
+	"github.com/paoloanzn/go-bootstrap/templates"
+)
+
+func runList(args []string) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	for _, name := range templates.Names() {
+		jsonTemplate, err := loader.Load(name, source.Options{})
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(w, "%s\t%s\n", name, jsonTemplate.Description)
+
+		var variables []string
+		for variable := range jsonTemplate.Variables {
+			variables = append(variables, variable)
+		}
+		sort.Strings(variables)
+
+		for _, variable := range variables {
+			v := jsonTemplate.Variables[variable]
+			description := v.Description
+			if v.Expr != "" {
+				description = fmt.Sprintf("%s (= %s)", description, v.Expr)
+			} else if v.Default != nil {
+				description = fmt.Sprintf("%s (default: %v)", description, v.Default)
+			}
+			fmt.Fprintf(w, "  <%s>\t%s\n", variable, description)
+		}
+
+		if len(jsonTemplate.Profiles) > 0 {
+			fmt.Fprintf(w, "  profiles\t%s\n", strings.Join(sortedLayers(jsonTemplate.Profiles), ", "))
+		}
+		if len(jsonTemplate.Features) > 0 {
+			fmt.Fprintf(w, "  features\t%s\n", strings.Join(sortedLayers(jsonTemplate.Features), ", "))
+		}
+		if len(jsonTemplate.Generators) > 0 {
+			fmt.Fprintf(w, "  generators\t%s\n", bootstrap.GeneratorNames(jsonTemplate))
+		}
+	}
+
+	return w.Flush()
+}
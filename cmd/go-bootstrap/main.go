@@ -1,13 +1,12 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"os"
 
-	"github.com/paoloanzn/go-bootstrap/bootstrap"
 	"github.com/paoloanzn/go-bootstrap/config"
-	"github.com/paoloanzn/go-bootstrap/parsing"
 )
 
 func main() {
@@ -19,16 +18,61 @@ func main() {
 
 	switch command {
 	case "init":
-		if len(os.Args) < 3 {
-			os.Exit(1)
+		err := runInit(os.Args[2:])
+		if err != nil {
+			log.Fatalf("Fatal: %v\n", err)
+		}
+
+	case "capture":
+		err := runCapture(os.Args[2:])
+		if err != nil {
+			log.Fatalf("Fatal: %v\n", err)
+		}
+
+	case "list":
+		err := runList(os.Args[2:])
+		if err != nil {
+			log.Fatalf("Fatal: %v\n", err)
+		}
+
+	case "template":
+		err := runTemplate(os.Args[2:])
+		if err != nil {
+			log.Fatalf("Fatal: %v\n", err)
+		}
+
+	case "add":
+		err := runAdd(os.Args[2:])
+		if err != nil {
+			log.Fatalf("Fatal: %v\n", err)
 		}
 
-		jsonTemplate, err := parsing.ParseTemplate(os.Args[2])
+	case "update":
+		err := runUpdate(os.Args[2:])
 		if err != nil {
 			log.Fatalf("Fatal: %v\n", err)
 		}
 
-		err = bootstrap.Bootstrap(jsonTemplate)
+	case "check":
+		err := runCheck(os.Args[2:])
+		if err != nil {
+			fatalCompare(err)
+		}
+
+	case "undo":
+		err := runUndo(os.Args[2:])
+		if err != nil {
+			log.Fatalf("Fatal: %v\n", err)
+		}
+
+	case "diff":
+		err := runDiff(os.Args[2:])
+		if err != nil {
+			fatalCompare(err)
+		}
+
+	case "config":
+		err := runConfig(os.Args[2:])
 		if err != nil {
 			log.Fatalf("Fatal: %v\n", err)
 		}
@@ -37,3 +81,27 @@ func main() {
 		fmt.Printf("version %s\n", config.VERSION)
 	}
 }
+
+// Exit statuses of check and diff, as with diff(1): exitDrift when the project
+// differs from the template, exitFailure when they could not compare them.
+const (
+	exitDrift   = 1
+	exitFailure = 2
+)
+
+// driftError is returned by check and diff when the project differs from the
+// template.
+type driftError struct {
+	error
+}
+
+// fatalCompare logs err and exits with the status of check and diff for it.
+func fatalCompare(err error) {
+	log.Printf("Fatal: %v\n", err)
+
+	var drift *driftError
+	if errors.As(err, &drift) {
+		os.Exit(exitDrift)
+	}
+	os.Exit(exitFailure)
+}
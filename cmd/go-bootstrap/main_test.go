@@ -3,7 +3,9 @@ package main
 import (
 	"bytes"
 	"fmt"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -13,8 +15,8 @@ import (
 // helper function to run the main.go file as a subprocess using 'go run'.
 // This allows us to capture exit codes and output without directly invoking os.Exit in the test process.
 func runMain(args ...string) (output string, exitCode int, err error) {
-	// Prepare command: assume main.go is in the same directory as this test file.
-	cmdArgs := append([]string{"run", "main.go"}, args...)
+	// Prepare command: run the package in the same directory as this test file.
+	cmdArgs := append([]string{"run", "."}, args...)
 	cmd := exec.Command("go", cmdArgs...)
 
 	// Capture combined output
@@ -102,3 +104,393 @@ func TestMainDefault(t *testing.T) {
 	}
 	// Inline comment: This test ensures that the version information is correctly output in the default case.
 }
+
+// TestMainList tests the 'list' command.
+// Expected outcome: every built-in template is printed with its description.
+func TestMainList(t *testing.T) {
+	output, exitCode, err := runMain("list")
+	if err != nil {
+		t.Fatalf("Did not expect an error for list command, got: %v, output: %s", err, output)
+	}
+	if exitCode != 0 {
+		t.Fatalf("Expected exit code 0 for list command, got %d, output: %s", exitCode, output)
+	}
+
+	for _, name := range []string{"base", "server", "cli", "library", "worker", "grpc-service"} {
+		if !strings.Contains(output, name) {
+			t.Errorf("Expected list output to contain %s, got '%s'", name, output)
+		}
+	}
+}
+
+// TestMainConfig tests the 'config' command and the default template.
+// Expected outcome: settings are persisted, the environment overrides them and
+// init falls back to the configured template.
+func TestMainConfig(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	output, _, err := runMain("config", "set", "template", "library")
+	if err != nil {
+		t.Fatalf("Did not expect an error for config set, got: %v, output: %s", err, output)
+	}
+
+	t.Setenv("GO_BOOTSTRAP_AUTHOR", "Jane Doe")
+	output, _, err = runMain("config", "list")
+	if err != nil {
+		t.Fatalf("Did not expect an error for config list, got: %v, output: %s", err, output)
+	}
+	for _, expected := range []string{"template       library", "author         Jane Doe"} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected config list output to contain '%s', got '%s'", expected, output)
+		}
+	}
+
+	output, _, err = runMain("init", "-dry-run")
+	if err != nil {
+		t.Fatalf("Did not expect an error for init with a default template, got: %v, output: %s", err, output)
+	}
+	if !strings.Contains(output, "go-library/go.mod") {
+		t.Errorf("Expected init to use the configured template, got '%s'", output)
+	}
+
+	_, exitCode, _ := runMain("config", "set", "colour", "blue")
+	if exitCode != 1 {
+		t.Errorf("Expected exit code 1 for an unknown setting, got %d", exitCode)
+	}
+}
+
+// TestReadAnswers checks that -var values take precedence over the answers
+// file.
+func TestReadAnswers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "answers.json")
+	err := os.WriteFile(path, []byte(`{"deploy": "k8s", "with_websocket": false}`), 0644)
+	if err != nil {
+		t.Fatalf("Failed to write answers: %v", err)
+	}
+
+	vars := varsFlag{"deploy": "nomad"}
+	if err := readAnswers(path, vars); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if vars["deploy"] != "nomad" || vars["with_websocket"] != false {
+		t.Errorf("Expected deploy=nomad and with_websocket=false, got %v", vars)
+	}
+}
+
+// TestMainInitArchive tests 'init -output-archive'.
+// Expected outcome: the project is written to a reproducible archive and not
+// to the disk, and the options of the disk are refused.
+func TestMainInitArchive(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.tar.gz")
+	second := filepath.Join(dir, "second.tar.gz")
+
+	for _, path := range []string{first, second} {
+		output, _, err := runMain("init", "library", "-output-archive", path)
+		if err != nil {
+			t.Fatalf("Did not expect an error for init -output-archive, got: %v, output: %s", err, output)
+		}
+	}
+
+	a, err := os.ReadFile(first)
+	if err != nil {
+		t.Fatalf("Expected the archive to be written: %v", err)
+	}
+	b, _ := os.ReadFile(second)
+	if !bytes.Equal(a, b) {
+		t.Errorf("Expected identical archives for identical runs")
+	}
+	if _, err := os.Stat("go-library"); err == nil {
+		t.Errorf("Expected nothing to be written to the disk")
+	}
+
+	_, exitCode, _ := runMain("init", "library", "-output-archive", filepath.Join(dir, "out.rar"))
+	if exitCode != 1 {
+		t.Errorf("Expected exit code 1 for an unsupported archive, got %d", exitCode)
+	}
+
+	// options of the disk make no sense for an archive
+	for _, flag := range [][]string{{"-o", dir}, {"-in-place"}, {"-force"}, {"-on-conflict", "fail"}} {
+		args := append([]string{"init", "library", "-output-archive", filepath.Join(dir, "out.zip")}, flag...)
+		output, exitCode, _ := runMain(args...)
+		if exitCode != 1 || !strings.Contains(output, "cannot be combined") {
+			t.Errorf("Expected %s to be refused with -output-archive, got exit code %d, output: %s", flag[0], exitCode, output)
+		}
+	}
+}
+
+// TestMainInitOutput tests 'init -o' and the target directory checks.
+// Expected outcome: a non-empty target is refused unless -force is given, and
+// -in-place generates into an existing directory without replacing its files.
+func TestMainInitOutput(t *testing.T) {
+	dir := t.TempDir()
+
+	output, _, err := runMain("init", "base", "-o", dir, "-name", "demo")
+	if err != nil {
+		t.Fatalf("Did not expect an error for init -o, got: %v, output: %s", err, output)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "demo", "cmd", "demo", "main.go")); err != nil {
+		t.Errorf("Expected the project in %s/demo: %v", dir, err)
+	}
+
+	output, exitCode, _ := runMain("init", "base", "-o", dir, "-name", "demo")
+	if exitCode != 1 || !strings.Contains(output, "not empty") {
+		t.Errorf("Expected a non-empty target to be refused, got exit code %d, output: %s", exitCode, output)
+	}
+
+	output, _, err = runMain("init", "base", "-o", dir, "-name", "demo", "-force")
+	if err != nil || !strings.Contains(output, "Overwrote") {
+		t.Errorf("Expected -force to overwrite existing files, got: %v, output: %s", err, output)
+	}
+
+	repo := filepath.Join(dir, "repo")
+	os.Mkdir(repo, 0755)
+	os.WriteFile(filepath.Join(repo, "README.md"), []byte("existing\n"), 0644)
+
+	output, _, err = runMain("init", "base", "-o", repo, "-in-place")
+	if err != nil {
+		t.Fatalf("Did not expect an error for init -in-place, got: %v, output: %s", err, output)
+	}
+	if _, err := os.Stat(filepath.Join(repo, "cmd", "repo", "main.go")); err != nil {
+		t.Errorf("Expected the project content directly in %s: %v", repo, err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(repo, "README.md")); string(data) != "existing\n" {
+		t.Errorf("Expected the existing README.md to be kept, got %q", data)
+	}
+}
+
+// TestMainUpdate tests 'update' from one local template to a newer one.
+// Expected outcome: template changes are merged with local edits, conflicting
+// edits get conflict markers and make update fail.
+func TestMainUpdate(t *testing.T) {
+	dir := t.TempDir()
+	writeTemplate := func(name string, makefile string, main string) string {
+		path := filepath.Join(dir, name+".json")
+		content := fmt.Sprintf(`{"config": {"name": "svc"}, "project": {
+			"Makefile": {"$type": "file", "$content": %q},
+			"main.go": {"$type": "file", "$content": %q}}}`, makefile, main)
+		os.WriteFile(path, []byte(content), 0644)
+		return path
+	}
+	v1 := writeTemplate("v1", "build:\n\tgo build\n\ntest:\n\tgo test\n", "package main\n\nfunc main() {}\n")
+	v2 := writeTemplate("v2", "build:\n\tgo build\n\ntest:\n\tgo test -race\n", "package main\n\nfunc main() {\n}\n")
+
+	output, _, err := runMain("init", v1, "-o", dir)
+	if err != nil {
+		t.Fatalf("Did not expect an error for init, got: %v, output: %s", err, output)
+	}
+
+	project := filepath.Join(dir, "svc")
+	os.WriteFile(filepath.Join(project, "Makefile"), []byte("build:\n\tgo build -v\n\ntest:\n\tgo test\n"), 0644)
+	os.WriteFile(filepath.Join(project, "main.go"), []byte("package main\n\nfunc main() { run() }\n"), 0644)
+
+	output, exitCode, _ := runMain("update", project, "-template", v2)
+	if exitCode != 1 || !strings.Contains(output, "1 files have conflicts") {
+		t.Errorf("Expected update to report a conflict, got exit code %d, output: %s", exitCode, output)
+	}
+
+	if data, _ := os.ReadFile(filepath.Join(project, "Makefile")); string(data) != "build:\n\tgo build -v\n\ntest:\n\tgo test -race\n" {
+		t.Errorf("Expected both changes in the Makefile, got %q", data)
+	}
+	if data, _ := os.ReadFile(filepath.Join(project, "main.go")); !strings.Contains(string(data), "<<<<<<< current") {
+		t.Errorf("Expected conflict markers in main.go, got %q", data)
+	}
+}
+
+// TestMainCheck tests 'check' on a generated project.
+// Expected outcome: a fresh project matches its template and a modified one
+// fails with a JUnit report naming the drift, with another exit code than
+// errors; the recorded profile is checked.
+func TestMainCheck(t *testing.T) {
+	dir := t.TempDir()
+
+	output, _, err := runMain("init", "base", "-o", dir, "-name", "demo")
+	if err != nil {
+		t.Fatalf("Did not expect an error for init, got: %v, output: %s", err, output)
+	}
+	project := filepath.Join(dir, "demo")
+
+	output, _, err = runMain("check", project)
+	if err != nil || !strings.Contains(output, "The project matches") {
+		t.Errorf("Expected the project to match its template, got: %v, output: %s", err, output)
+	}
+
+	os.WriteFile(filepath.Join(project, "notes.txt"), nil, 0644)
+	output, exitCode, _ := runMain("check", project, "-template", "base", "-format", "junit")
+	if exitCode != 1 || !strings.Contains(output, `<failure type="extra" message="notes.txt is not defined by the template">`) || !strings.Contains(output, "exit status 1") {
+		t.Errorf("Expected notes.txt to be reported as extra, got exit code %d, output: %s", exitCode, output)
+	}
+
+	// failing to check is told apart from drift
+	output, exitCode, _ = runMain("check", project, "-template", "missing-template")
+	// go run exits with 1 and reports the status of the command
+	if exitCode != 1 || !strings.Contains(output, "exit status 2") {
+		t.Errorf("Expected exit status 2 for a missing template, got %d, output: %s", exitCode, output)
+	}
+
+	// the recorded profile and features are checked by default
+	output, _, err = runMain("init", "server", "-o", dir, "-name", "shop", "-profile", "standard")
+	if err != nil {
+		t.Fatalf("Did not expect an error for init, got: %v, output: %s", err, output)
+	}
+	output, _, err = runMain("check", filepath.Join(dir, "shop"))
+	if err != nil || !strings.Contains(output, "The project matches") {
+		t.Errorf("Expected the project to match its profile, got: %v, output: %s", err, output)
+	}
+}
+
+// TestMainUndo tests 'undo' on a generated project.
+// Expected outcome: generated files are removed, a modified file is kept
+// until -force is given.
+func TestMainUndo(t *testing.T) {
+	dir := t.TempDir()
+
+	output, _, err := runMain("init", "base", "-o", dir, "-name", "demo")
+	if err != nil {
+		t.Fatalf("Did not expect an error for init, got: %v, output: %s", err, output)
+	}
+	project := filepath.Join(dir, "demo")
+	os.WriteFile(filepath.Join(project, "README.md"), []byte("edited\n"), 0644)
+
+	output, exitCode, _ := runMain("undo", project)
+	if exitCode != 1 || !strings.Contains(output, "Kept README.md (modified)") {
+		t.Errorf("Expected README.md to be kept, got exit code %d, output: %s", exitCode, output)
+	}
+	if _, err := os.Stat(filepath.Join(project, "cmd")); !os.IsNotExist(err) {
+		t.Errorf("Expected the cmd directory to be removed, got: %v", err)
+	}
+
+	output, _, err = runMain("undo", project, "-force")
+	if err != nil {
+		t.Fatalf("Did not expect an error for undo -force, got: %v, output: %s", err, output)
+	}
+	if entries, _ := os.ReadDir(project); len(entries) != 0 {
+		t.Errorf("Expected an empty project directory, got %d entries", len(entries))
+	}
+}
+
+// TestMainDiff tests 'diff' against a directory.
+// Expected outcome: differences are printed and fail the command with exit
+// code 1, errors with 2, and nothing is written to the directory.
+func TestMainDiff(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "LICENSE"), []byte("existing\n"), 0644)
+
+	output, exitCode, _ := runMain("diff", "library", "-against", dir)
+	if exitCode != 1 || !strings.Contains(output, "+Copyright") || !strings.Contains(output, "-existing") {
+		t.Errorf("Expected the differences to be printed, got exit code %d, output: %s", exitCode, output)
+	}
+	output, exitCode, _ = runMain("diff", "missing-template", "-against", dir)
+	// go run exits with 1 and reports the status of the command
+	if exitCode != 1 || !strings.Contains(output, "exit status 2") {
+		t.Errorf("Expected exit status 2 for a missing template, got %d, output: %s", exitCode, output)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("Expected diff not to write anything, got %d entries", len(entries))
+	}
+
+	output, _, _ = runMain("diff", "library", "-against", dir, "-stat")
+	if !strings.Contains(output, "files changed") {
+		t.Errorf("Expected a summary with -stat, got: %s", output)
+	}
+}
+
+// TestMainAdd tests 'add' with the handler generator of the server template.
+// Expected outcome: the project root is found from a subdirectory and the
+// handler, its test and its routes are added and registered once; existing
+// files are kept.
+func TestMainAdd(t *testing.T) {
+	dir := t.TempDir()
+
+	output, _, err := runMain("init", "server", "-o", dir, "-name", "shop", "-profile", "minimal")
+	if err != nil {
+		t.Fatalf("Did not expect an error for init, got: %v, output: %s", err, output)
+	}
+	project := filepath.Join(dir, "shop")
+
+	output, _, err = runMain("add", "handler", "orders", "-dir", filepath.Join(project, "cmd"))
+	if err != nil {
+		t.Fatalf("Did not expect an error for add, got: %v, output: %s", err, output)
+	}
+	for _, name := range []string{"orders_handler.go", "orders_handler_test.go", "orders_routes.go"} {
+		if _, err := os.Stat(filepath.Join(project, "http", name)); err != nil {
+			t.Errorf("Expected http/%s: %v", name, err)
+		}
+	}
+
+	output, _, err = runMain("add", "handler", "orders", "-dir", project)
+	if err != nil || !strings.Contains(output, "Kept http/orders_handler.go") {
+		t.Errorf("Expected existing files to be kept, got: %v, output: %s", err, output)
+	}
+	if data, _ := os.ReadFile(filepath.Join(project, "http", "routes.go")); strings.Count(string(data), "registerOrdersRoutes(mux)") != 1 {
+		t.Errorf("Expected the routes to be registered once, got:\n%s", data)
+	}
+
+	output, exitCode, _ := runMain("add", "model", "orders", "-dir", project)
+	if exitCode != 1 || !strings.Contains(output, "Unknown generator model") {
+		t.Errorf("Expected an unknown generator error, got exit code %d, output: %s", exitCode, output)
+	}
+}
+
+// TestMainVerifyBuild tests 'init -verify-build' with built-in templates, with
+// a template without go.mod, with one rendering a vet error and with one
+// rendering invalid Go.
+// Expected outcome: the built-in templates and the project without go.mod
+// verify, the vet error fails
+// the command after generation, and the syntax error fails generation,
+// quoting the rendered line.
+func TestMainVerifyBuild(t *testing.T) {
+	dir := t.TempDir()
+	writeTemplate := func(name string, main string) string {
+		path := filepath.Join(dir, name+".json")
+		content := fmt.Sprintf(`{"config": {"name": %q}, "project": {
+			"go.mod": {"$type": "file", "$content": "module example.com/<main_package>\n\ngo 1.21\n"},
+			"main.go": {"$type": "file", "$content": %q}}}`, name, main)
+		os.WriteFile(path, []byte(content), 0644)
+		return path
+	}
+
+	valid := writeTemplate("valid", "package main\n\nfunc main() {}\n")
+	output, _, err := runMain("init", valid, "-o", dir, "-verify-build")
+	if err != nil || !strings.Contains(output, "Verified") {
+		t.Errorf("Expected the project to verify, got: %v, output: %s", err, output)
+	}
+
+	// the built-in templates build as generated
+	for _, args := range [][]string{{"base"}, {"server", "-profile", "full"}} {
+		output, _, err = runMain(append([]string{"init", args[0], "-o", dir, "-name", "builtin" + args[0], "-verify-build", "-offline"}, args[1:]...)...)
+		if err != nil || !strings.Contains(output, "Verified") {
+			t.Errorf("Expected the %s template to verify, got: %v, output: %s", args[0], err, output)
+		}
+	}
+
+	// without go.mod, the module is named after the project
+	noMod := filepath.Join(dir, "nomod.json")
+	os.WriteFile(noMod, []byte(`{"config": {"name": "nomod"}, "project": {
+		"main.go": {"$type": "file", "$content": "package main\n\nimport \"<main_package>/internal/x\"\n\nfunc main() { x.Run() }\n"},
+		"internal": {"x": {"x.go": {"$type": "file", "$content": "package x\n\nfunc Run() {}\n"}}}}}`), 0644)
+	output, _, err = runMain("init", noMod, "-o", dir, "-verify-build")
+	if err != nil || !strings.Contains(output, "Verified") {
+		t.Errorf("Expected the project without go.mod to verify, got: %v, output: %s", err, output)
+	}
+
+	vet := writeTemplate("vet", "package main\n\nimport \"fmt\"\n\nfunc main() { fmt.Printf(\"%d\", \"<main_package>\") }\n")
+	output, exitCode, _ := runMain("init", vet, "-o", dir, "-verify-build")
+	if exitCode != 1 || !strings.Contains(output, "fails go vet") {
+		t.Errorf("Expected a go vet failure, got exit code %d, output: %s", exitCode, output)
+	}
+
+	syntax := writeTemplate("syntax", "package main\n\nfunc main() {\n\tprintln(\"<main_package>\"\n}\n")
+	output, exitCode, _ = runMain("init", syntax, "-o", dir)
+	if exitCode != 1 || !strings.Contains(output, `line 4`) || !strings.Contains(output, `println(\"syntax\"`) {
+		t.Errorf("Expected a syntax error quoting the rendered line, got exit code %d, output: %s", exitCode, output)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "syntax")); !os.IsNotExist(err) {
+		t.Errorf("Expected nothing to be generated, got: %v", err)
+	}
+
+	output, exitCode, _ = runMain("init", valid, "-o", dir, "-verify-build", "-dry-run")
+	if exitCode != 1 || !strings.Contains(output, "cannot be combined") {
+		t.Errorf("Expected -verify-build to refuse -dry-run, got exit code %d, output: %s", exitCode, output)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/paoloanzn/go-bootstrap/parsing"
+)
+
+// isInteractive reports whether stdin is a terminal a user can answer from.
+func isInteractive() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// promptSelection asks which profile and features to apply. Answers are
+// numbers or names, features being separated by commas or spaces. An empty
+// answer selects nothing.
+func promptSelection(jsonTemplate *parsing.JSONTemplate, in io.Reader, out io.Writer) (string, []string, error) {
+	reader := bufio.NewReader(in)
+
+	var profile string
+	if len(jsonTemplate.Profiles) > 0 {
+		choices := sortedLayers(jsonTemplate.Profiles)
+		answers, err := ask(reader, out, "Profile", choices, jsonTemplate.Profiles)
+		if err != nil {
+			return "", nil, err
+		}
+		if len(answers) > 1 {
+			return "", nil, fmt.Errorf("Select a single profile.")
+		}
+		if len(answers) == 1 {
+			profile = answers[0]
+		}
+	}
+
+	var features []string
+	if len(jsonTemplate.Features) > 0 {
+		choices := sortedLayers(jsonTemplate.Features)
+		answers, err := ask(reader, out, "Features", choices, jsonTemplate.Features)
+		if err != nil {
+			return "", nil, err
+		}
+		features = answers
+	}
+
+	return profile, features, nil
+}
+
+func ask(reader *bufio.Reader, out io.Writer, title string, choices []string, layers map[string]parsing.Layer) ([]string, error) {
+	fmt.Fprintf(out, "%s:\n", title)
+	for i, name := range choices {
+		fmt.Fprintf(out, "  %d) %s\t%s\n", i+1, name, layers[name].Description)
+	}
+	fmt.Fprint(out, "> ")
+
+	line, err := reader.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	var answers []string
+	for _, field := range strings.FieldsFunc(line, func(r rune) bool { return r == ',' || r == ' ' || r == '\n' || r == '\r' }) {
+		if i, err := strconv.Atoi(field); err == nil {
+			if i < 1 || i > len(choices) {
+				return nil, fmt.Errorf("Invalid choice %d.", i)
+			}
+			field = choices[i-1]
+		}
+		if _, exists := layers[field]; !exists {
+			return nil, fmt.Errorf("Invalid choice %s.", field)
+		}
+		answers = append(answers, field)
+	}
+
+	return answers, nil
+}
+
+func sortedLayers(layers map[string]parsing.Layer) []string {
+	names := make([]string, 0, len(layers))
+	for name := range layers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/paoloanzn/go-bootstrap/parsing"
+)
+
+// TestPromptSelection checks that answers are accepted by number or by name.
+func TestPromptSelection(t *testing.T) {
+	jsonTemplate := &parsing.JSONTemplate{
+		Profiles: map[string]parsing.Layer{"full": {}, "minimal": {}},
+		Features: map[string]parsing.Layer{"ci": {}, "docker": {}, "metrics": {}},
+	}
+
+	var out bytes.Buffer
+	profile, features, err := promptSelection(jsonTemplate, strings.NewReader("2\n1, metrics\n"), &out)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if profile != "minimal" {
+		t.Errorf("Expected profile 'minimal', got '%s'", profile)
+	}
+	if strings.Join(features, ",") != "ci,metrics" {
+		t.Errorf("Expected features ci,metrics, got %v", features)
+	}
+
+	_, _, err = promptSelection(jsonTemplate, strings.NewReader("7\n"), &out)
+	if err == nil {
+		t.Errorf("Expected an error for an out of range choice, got nil")
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/paoloanzn/go-bootstrap/registry"
+)
+
+const templateUsage = "Usage: go-bootstrap template <add|remove|list|show> [args]"
+
+func runTemplate(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf(templateUsage)
+	}
+
+	r, err := registry.Open()
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "add":
+		if len(args) != 3 {
+			return fmt.Errorf("Usage: go-bootstrap template add <name> <path|git-url>")
+		}
+
+		entry, err := r.Add(args[1], args[2])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Added %s from %s (%s)\n", entry.Name, entry.Source, entry.Checksum)
+
+	case "remove":
+		if len(args) != 2 {
+			return fmt.Errorf("Usage: go-bootstrap template remove <name>")
+		}
+
+		err := r.Remove(args[1])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Removed %s\n", args[1])
+
+	case "list":
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, entry := range r.List() {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", entry.Name, entry.Version, entry.Source)
+		}
+		return w.Flush()
+
+	case "show":
+		if len(args) != 2 {
+			return fmt.Errorf("Usage: go-bootstrap template show <name>")
+		}
+
+		entry, exists := r.Get(args[1])
+		if !exists {
+			return fmt.Errorf("Template %s is not registered.", args[1])
+		}
+
+		data, err := r.Read(entry.Name)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("name:     %s\n", entry.Name)
+		fmt.Printf("source:   %s\n", entry.Source)
+		fmt.Printf("version:  %s\n", entry.Version)
+		fmt.Printf("checksum: %s\n", entry.Checksum)
+		fmt.Printf("added:    %s\n\n", entry.Added.Format("2006-01-02 15:04:05"))
+		os.Stdout.Write(data)
+
+	default:
+		return fmt.Errorf(templateUsage)
+	}
+
+	return nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/paoloanzn/go-bootstrap/bootstrap"
+)
+
+func runUndo(args []string) error {
+	fs := flag.NewFlagSet("undo", flag.ExitOnError)
+	force := fs.Bool("force", false, "also remove the files modified since the generation")
+
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return fmt.Errorf("Usage: go-bootstrap undo <dir> [-force]")
+	}
+
+	fsys := bootstrap.NewOSFS(positional[0])
+	manifest, err := bootstrap.ReadManifest(fsys)
+	if err != nil {
+		return err
+	}
+
+	result, err := bootstrap.Undo(fsys, manifest, *force)
+	for _, path := range result.Removed {
+		fmt.Printf("Removed %s\n", path)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(result.Modified) > 0 && !*force {
+		for _, path := range result.Modified {
+			fmt.Printf("Kept %s (modified)\n", path)
+		}
+		return fmt.Errorf("%d files were modified since the generation and were kept; use -force to remove them too.", len(result.Modified))
+	}
+
+	return nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/paoloanzn/go-bootstrap/bootstrap"
+	"github.com/paoloanzn/go-bootstrap/config"
+	"github.com/paoloanzn/go-bootstrap/loader"
+	"github.com/paoloanzn/go-bootstrap/parsing"
+	"github.com/paoloanzn/go-bootstrap/source"
+)
+
+func runUpdate(args []string) error {
+	fs := flag.NewFlagSet("update", flag.ExitOnError)
+	templateRef := fs.String("template", "", "template to update to (default: the one the project was generated from)")
+	vars := make(varsFlag)
+	fs.Var(vars, "var", "set a template variable (name=value), can be repeated")
+	offline := fs.Bool("offline", false, "use only cached copies of remote templates")
+	dryRun := fs.Bool("dry-run", false, "print what would change without writing anything")
+	reject := fs.Bool("reject", false, "keep the current lines of conflicting files and write the conflicts to .rej files")
+
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 1 {
+		return fmt.Errorf("Usage: go-bootstrap update [dir] [-template template] [-var name=value]... [-reject] [-offline] [-dry-run]")
+	}
+
+	dir := "."
+	if len(positional) == 1 {
+		dir = positional[0]
+	}
+
+	fsys := bootstrap.NewOSFS(dir)
+	manifest, err := bootstrap.ReadManifest(fsys)
+	if err != nil {
+		return err
+	}
+
+	opts := source.Options{Offline: *offline}
+
+	// the original template gives the generated content files merge against
+	base, err := loadSelected(manifest.Template.Source, opts, manifest)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: merging without the original template: %v\n", err)
+		base = nil
+	}
+
+	ref := *templateRef
+	if ref == "" {
+		ref = manifest.Template.Source
+	}
+	next, err := loadSelected(ref, opts, manifest)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	cfg.Variables = vars
+
+	update, err := bootstrap.PlanUpdate(fsys, cfg, manifest, base, next)
+	if err != nil {
+		return err
+	}
+
+	err = update.Print(os.Stdout)
+	if err != nil || *dryRun {
+		return err
+	}
+
+	err = update.Apply(fsys, *reject)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%d added, %d updated, %d merged, %d with conflicts, %d removed, %d kept\n",
+		update.Count(bootstrap.UpdateAdd), update.Count(bootstrap.UpdateReplace), update.Count(bootstrap.UpdateMerge),
+		update.Count(bootstrap.UpdateConflict), update.Count(bootstrap.UpdateRemove), update.Count(bootstrap.UpdateKeep))
+
+	if conflicts := update.Count(bootstrap.UpdateConflict); conflicts > 0 {
+		if *reject {
+			return fmt.Errorf("%d files have conflicts, see their .rej files.", conflicts)
+		}
+		return fmt.Errorf("%d files have conflicts, resolve the conflict markers in them.", conflicts)
+	}
+
+	return nil
+}
+
+// loadSelected loads the template ref with the profile and features recorded
+// in manifest.
+func loadSelected(ref string, opts source.Options, manifest *bootstrap.Manifest) (*parsing.JSONTemplate, error) {
+	jsonTemplate, err := loader.Load(ref, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	profile, _ := manifest.Variables[loader.ProfileVariable].(string)
+	var features []string
+	list, _ := manifest.Variables[loader.FeaturesVariable].([]interface{})
+	for _, feature := range list {
+		if s, ok := feature.(string); ok {
+			features = append(features, s)
+		}
+	}
+
+	err = loader.Select(jsonTemplate, profile, features)
+	if err != nil {
+		return nil, err
+	}
+
+	return jsonTemplate, nil
+}
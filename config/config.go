@@ -1,13 +1,138 @@
 package config
 
-import ()
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
 
+// Config holds the state of a single run: the user configuration and the
+// values resolved while generating a project. A Config is not safe for
+// concurrent use, give each run its own, see Clone.
 type Config struct {
-	ProjectName string
+	// ProjectName names the project folder and <main_package>. It overrides
+	// config.name of the template when set.
+	ProjectName string `json:"-"`
+
+	// Variables holds the values of template variables, keyed by name.
+	Variables map[string]interface{} `json:"-"`
+
+	// OutputDir is the directory projects are created in, the working
+	// directory when empty.
+	OutputDir string `json:"-"`
+
+	// Logger reports the created paths. Nothing is reported when nil.
+	Logger *log.Logger `json:"-"`
+
+	// OnConflict decides what happens to files that already exist.
+	OnConflict ConflictPolicy `json:"-"`
+
+	// InPlace generates the content of the project directly in OutputDir,
+	// for instance in an existing repository, instead of in a new folder.
+	InPlace bool `json:"-"`
+
+	// RunBuiltins holds the built-in values drawn once per run, the date and
+	// the secrets. Clones share it; BuildPlan draws them for every plan when
+	// it is nil, and several renders of one run can share them by setting it.
+	RunBuiltins map[string]interface{} `json:"-"`
+
+	// Settings of the user configuration file, see Load. They provide the
+	// values of the built-in variables of the same name.
+	Author       string `json:"author,omitempty"`
+	Email        string `json:"email,omitempty"`
+	ModulePrefix string `json:"module_prefix,omitempty"`
+	License      string `json:"license,omitempty"`
+
+	// Template is the template used by init when none is given.
+	Template string `json:"template,omitempty"`
+}
+
+// ConflictPolicy decides what happens when a generated file already exists.
+type ConflictPolicy int
+
+const (
+	// ConflictSkip keeps the existing file.
+	ConflictSkip ConflictPolicy = iota
+	// ConflictOverwrite replaces the existing file.
+	ConflictOverwrite
+	// ConflictFail aborts the generation before anything is written.
+	ConflictFail
+)
+
+var conflictPolicies = []string{"skip", "overwrite", "fail"}
+
+func (p ConflictPolicy) String() string {
+	if int(p) < len(conflictPolicies) {
+		return conflictPolicies[p]
+	}
+	return fmt.Sprintf("ConflictPolicy(%d)", int(p))
+}
+
+// ParseConflictPolicy returns the policy named s: skip, overwrite or fail.
+func ParseConflictPolicy(s string) (ConflictPolicy, error) {
+	for i, name := range conflictPolicies {
+		if s == name {
+			return ConflictPolicy(i), nil
+		}
+	}
+
+	return ConflictSkip, fmt.Errorf("Unknown conflict policy %s, expected one of: %s.", s, strings.Join(conflictPolicies, ", "))
 }
 
 const (
 	VERSION = "0.1"
+
+	// AppName names the per-user configuration and cache directories.
+	AppName = "go-bootstrap"
 )
 
-var Cfg *Config = &Config{}
+// Clone returns a copy of c that can be modified without affecting c.
+func (c *Config) Clone() *Config {
+	clone := *c
+
+	clone.Variables = make(map[string]interface{}, len(c.Variables))
+	for name, value := range c.Variables {
+		clone.Variables[name] = value
+	}
+
+	return &clone
+}
+
+// Logf reports a message through the logger of c, if any.
+func (c *Config) Logf(format string, args ...interface{}) {
+	if c.Logger != nil {
+		c.Logger.Printf(format, args...)
+	}
+}
+
+// ConfigDir returns the per-user configuration directory of go-bootstrap,
+// $XDG_CONFIG_HOME/go-bootstrap when XDG_CONFIG_HOME is set.
+func ConfigDir() (string, error) {
+	base := os.Getenv("XDG_CONFIG_HOME")
+	if base == "" {
+		var err error
+		base, err = os.UserConfigDir()
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return filepath.Join(base, AppName), nil
+}
+
+// CacheDir returns the per-user cache directory of go-bootstrap,
+// $XDG_CACHE_HOME/go-bootstrap when XDG_CACHE_HOME is set.
+func CacheDir() (string, error) {
+	base := os.Getenv("XDG_CACHE_HOME")
+	if base == "" {
+		var err error
+		base, err = os.UserCacheDir()
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return filepath.Join(base, AppName), nil
+}
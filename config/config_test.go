@@ -1,21 +1,24 @@
 package config
 
 import (
+	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
 )
 
-// TestCfgInitialization tests the initialization of the exported variable Cfg.
-// This test checks that Cfg is not nil and that its default fields are set as expected.
-func TestCfgInitialization(t *testing.T) {
-	// Happy path: Expect Cfg to be already initialized
-	if Cfg == nil {
-		t.Error("Expected Cfg to be initialized, got nil")
-	}
+// TestClone checks that a clone can be modified without affecting the
+// original, so that each run can own its Config.
+func TestClone(t *testing.T) {
+	original := &Config{ProjectName: "a", Variables: map[string]interface{}{"x": "1"}}
+
+	clone := original.Clone()
+	clone.ProjectName = "b"
+	clone.Variables["x"] = "2"
+	clone.Variables["y"] = "3"
 
-	// Edge case: Check the default value of ProjectName, which should be an empty string
-	expectedProjectName := ""
-	if Cfg.ProjectName != expectedProjectName {
-		t.Errorf("Expected Cfg.ProjectName to be '%s', got '%s'", expectedProjectName, Cfg.ProjectName)
+	if original.ProjectName != "a" || len(original.Variables) != 1 || original.Variables["x"] != "1" {
+		t.Errorf("Expected the original to be unchanged, got %+v", original)
 	}
 }
 
@@ -47,3 +50,128 @@ func TestConfigStruct(t *testing.T) {
 
 // Additional tests can be added here as needed to further validate behavior or error conditions
 // in functions/methods when they are added to the package.
+
+// TestConfigDir checks that XDG_CONFIG_HOME is honored.
+func TestConfigDir(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", "/tmp/xdg")
+
+	dir, err := ConfigDir()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if dir != "/tmp/xdg/go-bootstrap" {
+		t.Errorf("Expected ConfigDir to be '/tmp/xdg/go-bootstrap', got '%s'", dir)
+	}
+}
+
+// TestCacheDir checks that XDG_CACHE_HOME is honored.
+func TestCacheDir(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", "/tmp/xdg-cache")
+
+	dir, err := CacheDir()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if dir != "/tmp/xdg-cache/go-bootstrap" {
+		t.Errorf("Expected CacheDir to be '/tmp/xdg-cache/go-bootstrap', got '%s'", dir)
+	}
+}
+
+// writeUserConfig writes a user configuration file named name in a temporary
+// XDG_CONFIG_HOME.
+func writeUserConfig(t *testing.T, name string, content string) string {
+	t.Helper()
+
+	home := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", home)
+
+	dir := filepath.Join(home, AppName)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("Failed to create config dir: %v", err)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	return path
+}
+
+// TestLoad checks that the user configuration file is read and that
+// environment variables override it.
+func TestLoad(t *testing.T) {
+	writeUserConfig(t, "config.json", `{"author": "Jane Doe", "module_prefix": "github.com/acme"}`)
+	t.Setenv("GO_BOOTSTRAP_MODULE_PREFIX", "gitlab.com/acme")
+
+	c, err := Load()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if c.Author != "Jane Doe" {
+		t.Errorf("Expected author 'Jane Doe', got '%s'", c.Author)
+	}
+	if c.ModulePrefix != "gitlab.com/acme" {
+		t.Errorf("Expected the environment to override module_prefix, got '%s'", c.ModulePrefix)
+	}
+}
+
+// TestLoadYAML covers the supported YAML subset and rejects the rest.
+func TestLoadYAML(t *testing.T) {
+	writeUserConfig(t, "config.yaml", "---\n# team defaults\nauthor: \"Jane \\\"JD\\\" Doe\" # comment\nlicense: 'Apache-2.0'\ntemplate: server # default\nemail:\n")
+
+	c, err := Load()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	expected := Config{Author: `Jane "JD" Doe`, License: "Apache-2.0", Template: "server"}
+	if !reflect.DeepEqual(*c, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, *c)
+	}
+
+	for _, content := range []string{"author:\n  name: Jane\n", "license: [MIT]\n", "color: blue\n", "author \"Jane\"\n"} {
+		writeUserConfig(t, "config.yaml", content)
+		if _, err := Load(); err == nil {
+			t.Errorf("Expected an error for %q, got nil", content)
+		}
+	}
+}
+
+// TestSave checks that a saved configuration reads back identically in both
+// formats, and that an unknown setting is rejected.
+func TestSave(t *testing.T) {
+	for _, name := range []string{"config.json", "config.yaml"} {
+		path := writeUserConfig(t, name, "")
+		os.Remove(path)
+
+		c := &Config{Author: "Jane: Doe # not a comment", Template: "cli"}
+		if err := c.Save(path); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+
+		loaded, loadedPath, err := LoadFile()
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if loadedPath != path || !reflect.DeepEqual(*loaded, *c) {
+			t.Errorf("Expected %+v from %s, got %+v from %s", *c, path, *loaded, loadedPath)
+		}
+	}
+
+	if err := (&Config{}).Set("colour", "blue"); err == nil {
+		t.Errorf("Expected an error for an unknown setting, got nil")
+	}
+}
+
+// TestParseConflictPolicy checks that policies round trip through their name.
+func TestParseConflictPolicy(t *testing.T) {
+	for _, policy := range []ConflictPolicy{ConflictSkip, ConflictOverwrite, ConflictFail} {
+		parsed, err := ParseConflictPolicy(policy.String())
+		if err != nil || parsed != policy {
+			t.Errorf("Expected %v, got %v (%v)", policy, parsed, err)
+		}
+	}
+
+	if _, err := ParseConflictPolicy("merge"); err == nil {
+		t.Errorf("Expected an error for an unknown policy, got nil")
+	}
+}
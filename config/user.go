@@ -0,0 +1,157 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// EnvPrefix prefixes the environment variables overriding the settings of
+// the user configuration file, e.g. GO_BOOTSTRAP_MODULE_PREFIX.
+const EnvPrefix = "GO_BOOTSTRAP_"
+
+// fileNames are the names the user configuration file is looked up under in
+// ConfigDir, in order.
+var fileNames = []string{"config.json", "config.yaml", "config.yml"}
+
+// Keys returns the names of the settings of the user configuration file.
+func Keys() []string {
+	keys := []string{"author", "email", "license", "module_prefix", "template"}
+	sort.Strings(keys)
+	return keys
+}
+
+func (c *Config) setting(key string) (*string, error) {
+	switch key {
+	case "author":
+		return &c.Author, nil
+	case "email":
+		return &c.Email, nil
+	case "license":
+		return &c.License, nil
+	case "module_prefix":
+		return &c.ModulePrefix, nil
+	case "template":
+		return &c.Template, nil
+	}
+
+	return nil, fmt.Errorf("Unknown setting %s, expected one of: %s.", key, strings.Join(Keys(), ", "))
+}
+
+// Get returns the value of the setting key.
+func (c *Config) Get(key string) (string, error) {
+	value, err := c.setting(key)
+	if err != nil {
+		return "", err
+	}
+
+	return *value, nil
+}
+
+// Set changes the setting key. An empty value unsets it.
+func (c *Config) Set(key string, value string) error {
+	setting, err := c.setting(key)
+	if err != nil {
+		return err
+	}
+
+	*setting = value
+	return nil
+}
+
+// Load returns the user configuration: the settings of the user
+// configuration file, overridden by GO_BOOTSTRAP_* environment variables. A
+// missing file is not an error.
+func Load() (*Config, error) {
+	c, _, err := LoadFile()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range Keys() {
+		value, exists := os.LookupEnv(EnvPrefix + strings.ToUpper(key))
+		if exists {
+			c.Set(key, value)
+		}
+	}
+
+	return c, nil
+}
+
+// LoadFile reads the user configuration file alone and returns it with its
+// path. When there is no file, the path is the one Save should create.
+func LoadFile() (*Config, string, error) {
+	dir, err := ConfigDir()
+	if err != nil {
+		return nil, "", err
+	}
+
+	var found []string
+	for _, name := range fileNames {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			found = append(found, filepath.Join(dir, name))
+		}
+	}
+
+	switch len(found) {
+	case 0:
+		return &Config{}, filepath.Join(dir, fileNames[0]), nil
+	case 1:
+	default:
+		return nil, "", fmt.Errorf("Found several user configuration files, keep only one of: %s.", strings.Join(found, ", "))
+	}
+
+	path := found[0]
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("Failed to read file: %v", err)
+	}
+
+	c := &Config{}
+	if filepath.Ext(path) == ".json" {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(c)
+	} else {
+		err = c.unmarshalYAML(data)
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("Invalid user configuration %s: %v", path, err)
+	}
+
+	return c, path, nil
+}
+
+// Save writes the settings of c to path, as JSON or YAML depending on its
+// extension.
+func (c *Config) Save(path string) error {
+	var data []byte
+	if filepath.Ext(path) == ".json" {
+		var err error
+		data, err = json.MarshalIndent(c, "", "    ")
+		if err != nil {
+			return err
+		}
+		data = append(data, '\n')
+	} else {
+		data = c.marshalYAML()
+	}
+
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+
+	// write then rename so a failed write never truncates the file
+	tmp := path + ".tmp"
+	err = os.WriteFile(tmp, data, 0644)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The standard library has no YAML support, and the user configuration is a
+// flat set of strings, so only that subset of YAML is read and written:
+// `key: value` lines with optional quotes, comments and a leading "---".
+// Nested mappings, lists and multi-line values are rejected.
+
+func (c *Config) unmarshalYAML(data []byte) error {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimRight(scanner.Text(), " \t\r")
+		trimmed := strings.TrimSpace(text)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || (line == 1 && trimmed == "---") {
+			continue
+		}
+
+		key, value, ok := strings.Cut(text, ":")
+		if !ok || key != strings.TrimSpace(key) || key == "" {
+			return fmt.Errorf("line %d: only top-level \"key: value\" pairs are supported", line)
+		}
+
+		value, err := yamlScalar(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("line %d: %v", line, err)
+		}
+
+		err = c.Set(key, value)
+		if err != nil {
+			return fmt.Errorf("line %d: %v", line, err)
+		}
+	}
+
+	return scanner.Err()
+}
+
+// yamlScalar returns the string value of a plain or quoted scalar. An empty
+// value is null, which unsets the setting.
+func yamlScalar(s string) (string, error) {
+	switch {
+	case s == "" || strings.HasPrefix(s, "#"):
+		return "", nil
+	case strings.HasPrefix(s, `"`):
+		quoted, err := strconv.QuotedPrefix(s)
+		if err != nil {
+			return "", fmt.Errorf("invalid string %s", s)
+		}
+		if rest := strings.TrimSpace(s[len(quoted):]); rest != "" && !strings.HasPrefix(rest, "#") {
+			return "", fmt.Errorf("unexpected %q after string", rest)
+		}
+		return strconv.Unquote(quoted)
+	case strings.HasPrefix(s, "'"):
+		end := strings.LastIndex(s, "'")
+		if end == 0 {
+			return "", fmt.Errorf("unterminated string %s", s)
+		}
+		return strings.ReplaceAll(s[1:end], "''", "'"), nil
+	case strings.ContainsAny(s[:1], "[{|>&*!"):
+		return "", fmt.Errorf("only string values are supported")
+	}
+
+	// drop a trailing comment
+	if i := strings.Index(s, " #"); i >= 0 {
+		s = strings.TrimSpace(s[:i])
+	}
+
+	return s, nil
+}
+
+func (c *Config) marshalYAML() []byte {
+	var b bytes.Buffer
+
+	for _, key := range Keys() {
+		value, _ := c.Get(key)
+		if value != "" {
+			fmt.Fprintf(&b, "%s: %s\n", key, strconv.Quote(value))
+		}
+	}
+
+	return b.Bytes()
+}
@@ -0,0 +1,70 @@
+// Package diff compares texts line by line. It is used to merge template
+// updates into projects and to show what a generation would change.
+package diff
+
+import "strings"
+
+// Lines splits s into lines, each keeping its line ending. The last line has
+// none when s does not end with a newline.
+func Lines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return lines
+}
+
+// match returns, for every line of a, the index of the line of b it is
+// matched with in a longest common subsequence of a and b, or -1.
+func match(a, b []string) []int {
+	// lengths[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:]
+	lengths := make([][]int, len(a)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			case lengths[i+1][j] >= lengths[i][j+1]:
+				lengths[i][j] = lengths[i+1][j]
+			default:
+				lengths[i][j] = lengths[i][j+1]
+			}
+		}
+	}
+
+	matches := make([]int, len(a))
+	i, j := 0, 0
+	for i < len(a) {
+		switch {
+		case j < len(b) && a[i] == b[j]:
+			matches[i] = j
+			i++
+			j++
+		case j < len(b) && lengths[i+1][j] < lengths[i][j+1]:
+			j++
+		default:
+			matches[i] = -1
+			i++
+		}
+	}
+
+	return matches
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
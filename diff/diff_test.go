@@ -0,0 +1,108 @@
+package diff
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestLines checks that lines keep their endings and that a missing final
+// newline is preserved.
+func TestLines(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"", nil},
+		{"a\n", []string{"a\n"}},
+		{"a\nb", []string{"a\n", "b"}},
+		{"a\n\nb\n", []string{"a\n", "\n", "b\n"}},
+	}
+
+	for _, tt := range tests {
+		lines := Lines(tt.input)
+		if len(lines) == 0 && len(tt.expected) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(lines, tt.expected) {
+			t.Errorf("Lines(%q): expected %q, got %q", tt.input, tt.expected, lines)
+		}
+	}
+}
+
+// TestMerge3 covers clean merges from either side, identical changes and
+// conflicting ones.
+func TestMerge3(t *testing.T) {
+	base := "a\nb\nc\nd\n"
+
+	tests := []struct {
+		name      string
+		ours      string
+		theirs    string
+		expected  string
+		conflicts int
+	}{
+		{"unchanged", base, base, base, 0},
+		{"ours only", "a\nB\nc\nd\n", base, "a\nB\nc\nd\n", 0},
+		{"theirs only", base, "a\nb\nc\nd\ne\n", "a\nb\nc\nd\ne\n", 0},
+		{"both sides apart", "A\nb\nc\nd\n", "a\nb\nc\nD\n", "A\nb\nc\nD\n", 0},
+		{"same change", "a\nX\nc\nd\n", "a\nX\nc\nd\n", "a\nX\nc\nd\n", 0},
+		{"deletion", "a\nc\nd\n", "a\nb\nc\nd\nz\n", "a\nc\nd\nz\n", 0},
+		{"conflict", "a\nours\nc\nd\n", "a\ntheirs\nc\nd\n", "a\n<<<<<<< current\nours\n=======\ntheirs\n>>>>>>> template\nc\nd\n", 1},
+		{"no final newline", "a\nb\nc\nd\nx", "a\nb\nc\nd\ny", "a\nb\nc\nd\n<<<<<<< current\nx\n=======\ny\n>>>>>>> template\n", 1},
+	}
+
+	for _, tt := range tests {
+		m := Merge3(base, tt.ours, tt.theirs)
+		if text := m.Text("current", "template"); text != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.expected, text)
+		}
+		if len(m.Conflicts) != tt.conflicts {
+			t.Errorf("%s: expected %d conflicts, got %d", tt.name, tt.conflicts, len(m.Conflicts))
+		}
+	}
+
+	// conflicts keep the current lines when resolved
+	m := Merge3(base, "a\nours\nc\nd\n", "a\ntheirs\nc\nD\n")
+	if resolved := m.Resolved(); resolved != "a\nours\nc\nD\n" {
+		t.Errorf("Expected the current side of the conflict, got %q", resolved)
+	}
+	if m.Conflicts[0].Line != 2 {
+		t.Errorf("Expected the conflict on line 2, got %d", m.Conflicts[0].Line)
+	}
+}
+
+// TestUnified checks hunk headers, context lines and the marker of a missing
+// final newline against the output of GNU diff -u.
+func TestUnified(t *testing.T) {
+	tests := []struct {
+		name     string
+		a, b     string
+		expected string
+	}{
+		{"equal", "a\n", "a\n", ""},
+		{"added", "", "x\n", "--- a\n+++ b\n@@ -0,0 +1 @@\n+x\n"},
+		{"removed", "x\n", "", "--- a\n+++ b\n@@ -1 +0,0 @@\n-x\n"},
+		{
+			"context",
+			"1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n",
+			"1\n2\n3\n4\nX\n6\n7\n8\n9\n10\n",
+			"--- a\n+++ b\n@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+X\n 6\n 7\n 8\n",
+		},
+		{
+			"two hunks",
+			"1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			"X\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\nY",
+			"--- a\n+++ b\n@@ -1,4 +1,4 @@\n-1\n+X\n 2\n 3\n 4\n@@ -9,4 +9,4 @@\n 9\n 10\n 11\n-12\n+Y\n\\ No newline at end of file\n",
+		},
+	}
+
+	for _, tt := range tests {
+		if unified := Unified("a", "b", tt.a, tt.b); unified != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.expected, unified)
+		}
+	}
+
+	if added, deleted := Stat("a\nb\n", "a\nc\nd\n"); added != 2 || deleted != 1 {
+		t.Errorf("Expected 2 added and 1 deleted lines, got %d and %d", added, deleted)
+	}
+}
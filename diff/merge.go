@@ -0,0 +1,130 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Merge is the result of a three-way merge.
+type Merge struct {
+	chunks    []chunk
+	Conflicts []Conflict
+}
+
+// Conflict is a region changed differently on both sides of a merge. Line is
+// the first line of the region in ours, counting from 1.
+type Conflict struct {
+	Line   int
+	Base   []string
+	Ours   []string
+	Theirs []string
+}
+
+type chunk struct {
+	lines    []string
+	conflict *Conflict
+}
+
+// Merge3 merges the changes from base to ours and from base to theirs. Lines
+// changed on one side only take that side, lines changed the same way on
+// both sides are kept once, and lines changed differently are conflicts.
+func Merge3(base, ours, theirs string) *Merge {
+	o, a, b := Lines(base), Lines(ours), Lines(theirs)
+	matchA, matchB := match(o, a), match(o, b)
+
+	m := &Merge{}
+	i, j, k := 0, 0, 0
+	for {
+		// lines unchanged on both sides
+		n := 0
+		for i+n < len(o) && matchA[i+n] == j+n && matchB[i+n] == k+n {
+			n++
+		}
+		if n > 0 {
+			m.chunks = append(m.chunks, chunk{lines: o[i : i+n]})
+			i, j, k = i+n, j+n, k+n
+			continue
+		}
+
+		// the changed region ends at the next line unchanged on both sides
+		next := i
+		for next < len(o) && (matchA[next] < 0 || matchB[next] < 0) {
+			next++
+		}
+		endA, endB := len(a), len(b)
+		if next < len(o) {
+			endA, endB = matchA[next], matchB[next]
+		}
+
+		m.resolve(j, o[i:next], a[j:endA], b[k:endB])
+		if next == len(o) {
+			return m
+		}
+		i, j, k = next, endA, endB
+	}
+}
+
+func (m *Merge) resolve(line int, base, ours, theirs []string) {
+	switch {
+	case equal(ours, base):
+		m.chunks = append(m.chunks, chunk{lines: theirs})
+	case equal(theirs, base), equal(ours, theirs):
+		m.chunks = append(m.chunks, chunk{lines: ours})
+	default:
+		conflict := &Conflict{Line: line + 1, Base: base, Ours: ours, Theirs: theirs}
+		m.Conflicts = append(m.Conflicts, *conflict)
+		m.chunks = append(m.chunks, chunk{lines: ours, conflict: conflict})
+	}
+}
+
+// Text returns the merged text. Conflicts are written between markers
+// labelled with ours and theirs.
+func (m *Merge) Text(ours, theirs string) string {
+	var sb strings.Builder
+	for _, c := range m.chunks {
+		if c.conflict == nil {
+			writeLines(&sb, c.lines)
+			continue
+		}
+		sb.WriteString(c.conflict.Markers(ours, theirs))
+	}
+
+	return sb.String()
+}
+
+// Resolved returns the merged text keeping ours for every conflict.
+func (m *Merge) Resolved() string {
+	var sb strings.Builder
+	for _, c := range m.chunks {
+		writeLines(&sb, c.lines)
+	}
+
+	return sb.String()
+}
+
+// Markers returns the conflict between git style conflict markers.
+func (c *Conflict) Markers(ours, theirs string) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "<<<<<<< %s\n", ours)
+	writeLines(&sb, c.Ours)
+	terminate(&sb)
+	sb.WriteString("=======\n")
+	writeLines(&sb, c.Theirs)
+	terminate(&sb)
+	fmt.Fprintf(&sb, ">>>>>>> %s\n", theirs)
+
+	return sb.String()
+}
+
+func writeLines(sb *strings.Builder, lines []string) {
+	for _, line := range lines {
+		sb.WriteString(line)
+	}
+}
+
+// terminate ends the last line of sb so that a marker can follow it.
+func terminate(sb *strings.Builder) {
+	if s := sb.String(); s != "" && !strings.HasSuffix(s, "\n") {
+		sb.WriteString("\n")
+	}
+}
@@ -0,0 +1,147 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// context is the number of unchanged lines shown around changes.
+const context = 3
+
+type opKind byte
+
+const (
+	opEqual  opKind = ' '
+	opDelete opKind = '-'
+	opInsert opKind = '+'
+)
+
+type op struct {
+	kind opKind
+	line string
+}
+
+// ops returns the edit script turning a into b.
+func ops(a, b []string) []op {
+	matches := match(a, b)
+
+	var script []op
+	j := 0
+	for i, line := range a {
+		if matches[i] < 0 {
+			script = append(script, op{opDelete, line})
+			continue
+		}
+		for ; j < matches[i]; j++ {
+			script = append(script, op{opInsert, b[j]})
+		}
+		script = append(script, op{opEqual, line})
+		j++
+	}
+	for ; j < len(b); j++ {
+		script = append(script, op{opInsert, b[j]})
+	}
+
+	return script
+}
+
+// Stat returns the number of lines added and deleted from a to b.
+func Stat(a, b string) (added, deleted int) {
+	for _, o := range ops(Lines(a), Lines(b)) {
+		switch o.kind {
+		case opInsert:
+			added++
+		case opDelete:
+			deleted++
+		}
+	}
+
+	return added, deleted
+}
+
+// Unified returns the changes from a to b in the unified format, with the
+// names of a and b in the header, or an empty string when they are equal.
+func Unified(fromName, toName, a, b string) string {
+	script := ops(Lines(a), Lines(b))
+
+	var sb strings.Builder
+	// line numbers of the start of script[i] in a and b
+	lineA, lineB := 1, 1
+	for i := 0; i < len(script); {
+		if script[i].kind == opEqual {
+			lineA, lineB = lineA+1, lineB+1
+			i++
+			continue
+		}
+
+		if sb.Len() == 0 {
+			fmt.Fprintf(&sb, "--- %s\n+++ %s\n", fromName, toName)
+		}
+
+		// the hunk starts with the context before the change and extends
+		// until more than twice the context separates two changes
+		start := i - min(i, context)
+		for start < i && script[start].kind != opEqual {
+			start++
+		}
+		end := i
+		for equal := 0; end < len(script) && equal <= 2*context; end++ {
+			if script[end].kind == opEqual {
+				equal++
+			} else {
+				equal = 0
+			}
+		}
+		// keep only the context after the last change
+		last := end - 1
+		for last > i && script[last].kind == opEqual {
+			last--
+		}
+		end = min(last+1+context, len(script))
+
+		startA, startB := lineA-(i-start), lineB-(i-start)
+		countA, countB := 0, 0
+		var body strings.Builder
+		for _, o := range script[start:end] {
+			if o.kind != opInsert {
+				countA++
+			}
+			if o.kind != opDelete {
+				countB++
+			}
+			body.WriteByte(byte(o.kind))
+			body.WriteString(o.line)
+			if !strings.HasSuffix(o.line, "\n") {
+				body.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n", hunkRange(startA, countA), hunkRange(startB, countB))
+		sb.WriteString(body.String())
+
+		for _, o := range script[i:end] {
+			if o.kind != opInsert {
+				lineA++
+			}
+			if o.kind != opDelete {
+				lineB++
+			}
+		}
+		i = end
+	}
+
+	return sb.String()
+}
+
+// hunkRange formats the range of a hunk header. Empty ranges start at the
+// line before them.
+func hunkRange(start, count int) string {
+	if count == 0 {
+		start--
+	}
+	if count == 1 {
+		return fmt.Sprint(start)
+	}
+
+	return fmt.Sprintf("%d,%d", start, count)
+}
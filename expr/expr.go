@@ -0,0 +1,552 @@
+// Package expr implements the small expression language used by template
+// conditions, e.g. `with_websocket && deploy in ["k8s", "nomad"]`.
+//
+// Operands are variables, "strings", numbers, true, false, [lists] and
+// function calls such as lower(name). Operators are +, ==, !=, in, !, && and
+// ||, from the tightest to the loosest binding, and parentheses group
+// sub-expressions. + adds numbers and concatenates anything else.
+package expr
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Expr is a parsed expression.
+type Expr interface {
+	Eval(env map[string]interface{}) (interface{}, error)
+}
+
+// Parse parses s into an expression.
+func Parse(s string) (Expr, error) {
+	tokens, err := tokenize(s)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid expression %q: %v", s, err)
+	}
+
+	p := &parser{tokens: tokens}
+	e, err := p.parseOr()
+	if err == nil && p.peek().kind != tokEOF {
+		err = fmt.Errorf("unexpected %q at %d", p.peek().text, p.peek().pos)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Invalid expression %q: %v", s, err)
+	}
+
+	return e, nil
+}
+
+// ParseLoop parses a loop header, "<variable> in <list>", as used by repeated
+// template nodes.
+func ParseLoop(s string) (string, Expr, error) {
+	tokens, err := tokenize(s)
+	if err != nil {
+		return "", nil, fmt.Errorf("Invalid loop %q: %v", s, err)
+	}
+
+	if len(tokens) < 3 || tokens[0].kind != tokIdent || tokens[1].kind != tokIdent || tokens[1].text != "in" {
+		return "", nil, fmt.Errorf("Invalid loop %q: expected \"<variable> in <list>\"", s)
+	}
+
+	list, err := Parse(s[tokens[2].pos:])
+	if err != nil {
+		return "", nil, fmt.Errorf("Invalid loop %q: %v", s, err)
+	}
+
+	return tokens[0].text, list, nil
+}
+
+// Eval parses and evaluates s.
+func Eval(s string, env map[string]interface{}) (interface{}, error) {
+	e, err := Parse(s)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := e.Eval(env)
+	if err != nil {
+		return nil, fmt.Errorf("Evaluating %q: %v", s, err)
+	}
+
+	return value, nil
+}
+
+// Variables returns the names of the variables e refers to, in order of
+// appearance and without duplicates.
+func Variables(e Expr) []string {
+	var names []string
+	walk(e, func(e Expr) {
+		if v, ok := e.(*variable); ok && !containsString(names, v.name) {
+			names = append(names, v.name)
+		}
+	})
+
+	return names
+}
+
+// Functions returns the names of the functions e calls, in order of
+// appearance and without duplicates.
+func Functions(e Expr) []string {
+	var names []string
+	walk(e, func(e Expr) {
+		if c, ok := e.(*call); ok && !containsString(names, c.name) {
+			names = append(names, c.name)
+		}
+	})
+
+	return names
+}
+
+// walk calls visit for e and every sub-expression of e.
+func walk(e Expr, visit func(Expr)) {
+	visit(e)
+
+	switch e := e.(type) {
+	case *list:
+		for _, item := range e.items {
+			walk(item, visit)
+		}
+	case *call:
+		for _, arg := range e.args {
+			walk(arg, visit)
+		}
+	case *not:
+		walk(e.operand, visit)
+	case *logical:
+		walk(e.left, visit)
+		walk(e.right, visit)
+	case *comparison:
+		walk(e.left, visit)
+		walk(e.right, visit)
+	case *sum:
+		walk(e.left, visit)
+		walk(e.right, visit)
+	}
+}
+
+func containsString(items []string, s string) bool {
+	for _, item := range items {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// EvalBool parses and evaluates s, converting the result with Truthy.
+func EvalBool(s string, env map[string]interface{}) (bool, error) {
+	value, err := Eval(s, env)
+	if err != nil {
+		return false, err
+	}
+
+	return Truthy(value), nil
+}
+
+// Truthy converts a value to a boolean: false, "", "false", 0, empty lists
+// and nil are false, anything else is true.
+func Truthy(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != "" && v != "false"
+	case float64:
+		return v != 0
+	case int:
+		return v != 0
+	case []interface{}:
+		return len(v) > 0
+	case []string:
+		return len(v) > 0
+	default:
+		return true
+	}
+}
+
+// Equal compares two values. Values of different types are compared through
+// their string form, so that a "3" supplied on the command line equals 3.
+func Equal(a interface{}, b interface{}) bool {
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) accept(op string) bool {
+	t := p.peek()
+	if (t.kind == tokOp || t.kind == tokIdent) && t.text == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(op string) error {
+	if !p.accept(op) {
+		return fmt.Errorf("expected %q at %d", op, p.peek().pos)
+	}
+	return nil
+}
+
+func (p *parser) parseOr() (Expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.accept("||") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logical{op: "||", left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.accept("&&") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &logical{op: "&&", left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseNot() (Expr, error) {
+	if p.accept("!") {
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &not{operand: operand}, nil
+	}
+
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (Expr, error) {
+	left, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, op := range []string{"==", "!=", "in"} {
+		if p.accept(op) {
+			right, err := p.parseSum()
+			if err != nil {
+				return nil, err
+			}
+			return &comparison{op: op, left: left, right: right}, nil
+		}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseSum() (Expr, error) {
+	left, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.accept("+") {
+		right, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		left = &sum{left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parsePrimary() (Expr, error) {
+	t := p.next()
+
+	switch t.kind {
+	case tokString, tokNumber:
+		return &literal{value: t.value}, nil
+
+	case tokIdent:
+		switch t.text {
+		case "true":
+			return &literal{value: true}, nil
+		case "false":
+			return &literal{value: false}, nil
+		case "in":
+			return nil, fmt.Errorf("unexpected \"in\" at %d", t.pos)
+		}
+		if p.accept("(") {
+			return p.parseCall(t)
+		}
+		return &variable{name: t.text}, nil
+
+	case tokOp:
+		switch t.text {
+		case "(":
+			e, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			return e, p.expect(")")
+
+		case "[":
+			l := &list{}
+			if p.accept("]") {
+				return l, nil
+			}
+			for {
+				item, err := p.parseOr()
+				if err != nil {
+					return nil, err
+				}
+				l.items = append(l.items, item)
+
+				if p.accept("]") {
+					return l, nil
+				}
+				if err := p.expect(","); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	if t.kind == tokEOF {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	return nil, fmt.Errorf("unexpected %q at %d", t.text, t.pos)
+}
+
+// parseCall parses the arguments of a call to the function named by t, whose
+// opening parenthesis has been consumed.
+func (p *parser) parseCall(t token) (Expr, error) {
+	f, exists := functions[t.text]
+	if !exists {
+		return nil, fmt.Errorf("unknown function %s at %d", t.text, t.pos)
+	}
+
+	c := &call{name: t.text, f: f}
+	if !p.accept(")") {
+		for {
+			arg, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			c.args = append(c.args, arg)
+
+			if p.accept(")") {
+				break
+			}
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if len(c.args) != f.arity {
+		return nil, fmt.Errorf("%s expects %d arguments, got %d", t.text, f.arity, len(c.args))
+	}
+
+	return c, nil
+}
+
+type literal struct {
+	value interface{}
+}
+
+func (e *literal) Eval(env map[string]interface{}) (interface{}, error) {
+	return e.value, nil
+}
+
+type variable struct {
+	name string
+}
+
+func (e *variable) Eval(env map[string]interface{}) (interface{}, error) {
+	value, exists := env[e.name]
+	if !exists {
+		return nil, fmt.Errorf("unknown variable %s", e.name)
+	}
+	return value, nil
+}
+
+type list struct {
+	items []Expr
+}
+
+func (e *list) Eval(env map[string]interface{}) (interface{}, error) {
+	values := make([]interface{}, 0, len(e.items))
+	for _, item := range e.items {
+		value, err := item.Eval(env)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+type not struct {
+	operand Expr
+}
+
+func (e *not) Eval(env map[string]interface{}) (interface{}, error) {
+	value, err := e.operand.Eval(env)
+	if err != nil {
+		return nil, err
+	}
+	return !Truthy(value), nil
+}
+
+type logical struct {
+	op          string
+	left, right Expr
+}
+
+func (e *logical) Eval(env map[string]interface{}) (interface{}, error) {
+	left, err := e.left.Eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	// short-circuit like Go does
+	if e.op == "&&" && !Truthy(left) {
+		return false, nil
+	}
+	if e.op == "||" && Truthy(left) {
+		return true, nil
+	}
+
+	right, err := e.right.Eval(env)
+	if err != nil {
+		return nil, err
+	}
+	return Truthy(right), nil
+}
+
+type comparison struct {
+	op          string
+	left, right Expr
+}
+
+func (e *comparison) Eval(env map[string]interface{}) (interface{}, error) {
+	left, err := e.left.Eval(env)
+	if err != nil {
+		return nil, err
+	}
+	right, err := e.right.Eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	switch e.op {
+	case "==":
+		return Equal(left, right), nil
+	case "!=":
+		return !Equal(left, right), nil
+	default:
+		return contains(right, left)
+	}
+}
+
+type sum struct {
+	left, right Expr
+}
+
+func (e *sum) Eval(env map[string]interface{}) (interface{}, error) {
+	left, err := e.left.Eval(env)
+	if err != nil {
+		return nil, err
+	}
+	right, err := e.right.Eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	l, lok := left.(float64)
+	r, rok := right.(float64)
+	if lok && rok {
+		return l + r, nil
+	}
+
+	return fmt.Sprint(left) + fmt.Sprint(right), nil
+}
+
+type call struct {
+	name string
+	f    function
+	args []Expr
+}
+
+func (e *call) Eval(env map[string]interface{}) (interface{}, error) {
+	args := make([]interface{}, 0, len(e.args))
+	for _, arg := range e.args {
+		value, err := arg.Eval(env)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, value)
+	}
+
+	value, err := e.f.call(args)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", e.name, err)
+	}
+	return value, nil
+}
+
+// contains implements `in`: membership in a list, or substring of a string.
+func contains(container interface{}, item interface{}) (bool, error) {
+	switch c := container.(type) {
+	case []interface{}:
+		for _, v := range c {
+			if Equal(v, item) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case []string:
+		for _, v := range c {
+			if Equal(v, item) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case string:
+		s, ok := item.(string)
+		if !ok {
+			return false, fmt.Errorf("in: cannot look for %v in a string", item)
+		}
+		return strings.Contains(c, s), nil
+	default:
+		return false, fmt.Errorf("in: %v is not a list", container)
+	}
+}
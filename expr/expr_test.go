@@ -0,0 +1,165 @@
+package expr
+
+import (
+	"fmt"
+	"testing"
+)
+
+// TestEvalBool covers every operator against a typical variable set.
+func TestEvalBool(t *testing.T) {
+	env := map[string]interface{}{
+		"with_websocket": true,
+		"deploy":         "k8s",
+		"replicas":       3.0,
+		"services":       []interface{}{"api", "worker"},
+		"disabled":       "false",
+	}
+
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{`with_websocket`, true},
+		{`!with_websocket`, false},
+		{`deploy == "k8s"`, true},
+		{`deploy != 'k8s'`, false},
+		{`replicas == 3`, true},
+		{`replicas == "3"`, true},
+		{`"api" in services`, true},
+		{`"migrator" in services`, false},
+		{`deploy in ["k8s", "nomad"]`, true},
+		{`!(deploy in ["nomad"])`, true},
+		{`with_websocket && deploy == "vm"`, false},
+		{`with_websocket || missing`, true},
+		{`disabled`, false},
+		{`!disabled && with_websocket`, true},
+		{`"8" in "k8s"`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			result, err := EvalBool(tt.input, env)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if result != tt.expected {
+				t.Errorf("EvalBool(%q) = %v; want %v", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
+
+// TestEval covers string-producing expressions used by derived variables.
+func TestEval(t *testing.T) {
+	env := map[string]interface{}{
+		"module":   "github.com/acme/Order-Service",
+		"services": []interface{}{"api", "worker"},
+		"port":     8080.0,
+	}
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`base(module)`, "Order-Service"},
+		{`lower(replace(base(module), "-", "_"))`, "order_service"},
+		{`upper("ok")`, "OK"},
+		{`pascal("order_items-v2")`, "OrderItemsV2"},
+		{`"cmd/" + base(module) + "/main.go"`, "cmd/Order-Service/main.go"},
+		{`port + 1`, 8081.0},
+		{`":" + port`, ":8080"},
+		{`join(services, " ")`, "api worker"},
+		{`"api" + "" == "api"`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			result, err := Eval(tt.input, env)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if result != tt.expected {
+				t.Errorf("Eval(%q) = %v; want %v", tt.input, result, tt.expected)
+			}
+		})
+	}
+
+	secret, err := Eval(`random_hex(16)`, env)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(secret.(string)) != 32 {
+		t.Errorf("Expected 32 hex characters, got %q", secret)
+	}
+}
+
+// TestVariables checks that referenced variables and functions are listed
+// once, in order.
+func TestVariables(t *testing.T) {
+	e, err := Parse(`lower(name) + "-" + suffix + name in [a, !b]`)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	got := fmt.Sprint(Variables(e))
+	if got != "[name suffix a b]" {
+		t.Errorf("Expected [name suffix a b], got %s", got)
+	}
+
+	e, _ = Parse(`upper(lower(a)) + lower(random_hex(8))`)
+	if got := fmt.Sprint(Functions(e)); got != "[upper lower random_hex]" {
+		t.Errorf("Expected [upper lower random_hex], got %s", got)
+	}
+}
+
+// TestParseErrors checks that malformed expressions are rejected.
+func TestParseErrors(t *testing.T) {
+	for _, input := range []string{
+		``,
+		`deploy ==`,
+		`(a && b`,
+		`a b`,
+		`"unterminated`,
+		`a = b`,
+		`[a, b`,
+		`in services`,
+		`unknown(a)`,
+		`lower(a, b)`,
+		`a +`,
+	} {
+		if _, err := Parse(input); err == nil {
+			t.Errorf("Expected a parse error for %q, got nil", input)
+		}
+	}
+}
+
+// TestEvalErrors checks runtime errors such as unknown variables.
+func TestEvalErrors(t *testing.T) {
+	for _, input := range []string{`missing`, `"a" in 3`, `random_hex(0)`, `join("a", ",")`} {
+		if _, err := EvalBool(input, map[string]interface{}{}); err == nil {
+			t.Errorf("Expected an evaluation error for %q, got nil", input)
+		}
+	}
+}
+
+// TestParseLoop covers loop headers used by repeated nodes.
+func TestParseLoop(t *testing.T) {
+	variable, list, err := ParseLoop(`service in services`)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if variable != "service" {
+		t.Errorf("Expected loop variable 'service', got '%s'", variable)
+	}
+
+	value, err := list.Eval(map[string]interface{}{"services": []interface{}{"api"}})
+	if err != nil || len(value.([]interface{})) != 1 {
+		t.Errorf("Expected the list to evaluate to [api], got %v (%v)", value, err)
+	}
+
+	for _, input := range []string{`services`, `service services`, `service in`, `"a" in services`} {
+		if _, _, err := ParseLoop(input); err == nil {
+			t.Errorf("Expected an error for %q, got nil", input)
+		}
+	}
+}
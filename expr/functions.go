@@ -0,0 +1,93 @@
+package expr
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type function struct {
+	arity int
+	call  func(args []interface{}) (interface{}, error)
+}
+
+// functions are the functions callable from expressions. Strings are
+// expected where noted, other values are converted through their string
+// form.
+var functions = map[string]function{
+	// lower("Foo") is "foo"
+	"lower": {1, func(args []interface{}) (interface{}, error) {
+		return strings.ToLower(fmt.Sprint(args[0])), nil
+	}},
+	// upper("foo") is "FOO"
+	"upper": {1, func(args []interface{}) (interface{}, error) {
+		return strings.ToUpper(fmt.Sprint(args[0])), nil
+	}},
+	// pascal("order-items") is "OrderItems", words being separated by -, _,
+	// dots or spaces
+	"pascal": {1, func(args []interface{}) (interface{}, error) {
+		words := strings.FieldsFunc(fmt.Sprint(args[0]), func(r rune) bool {
+			return r == '-' || r == '_' || r == '.' || r == ' '
+		})
+		for i, word := range words {
+			r, size := utf8.DecodeRuneInString(word)
+			words[i] = string(unicode.ToUpper(r)) + word[size:]
+		}
+		return strings.Join(words, ""), nil
+	}},
+	// replace("a-b", "-", "_") is "a_b"
+	"replace": {3, func(args []interface{}) (interface{}, error) {
+		return strings.ReplaceAll(fmt.Sprint(args[0]), fmt.Sprint(args[1]), fmt.Sprint(args[2])), nil
+	}},
+	// base("github.com/acme/api") is "api"
+	"base": {1, func(args []interface{}) (interface{}, error) {
+		return path.Base(fmt.Sprint(args[0])), nil
+	}},
+	// join(["a", "b"], ",") is "a,b"
+	"join": {2, func(args []interface{}) (interface{}, error) {
+		items, ok := args[0].([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%v is not a list", args[0])
+		}
+		s := make([]string, 0, len(items))
+		for _, item := range items {
+			s = append(s, fmt.Sprint(item))
+		}
+		return strings.Join(s, fmt.Sprint(args[1])), nil
+	}},
+	// random_hex(16) is 16 random bytes, hex encoded
+	"random_hex": {1, func(args []interface{}) (interface{}, error) {
+		b, err := randomBytes(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return hex.EncodeToString(b), nil
+	}},
+	// random_base64(32) is 32 random bytes, base64 encoded
+	"random_base64": {1, func(args []interface{}) (interface{}, error) {
+		b, err := randomBytes(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return base64.StdEncoding.EncodeToString(b), nil
+	}},
+}
+
+func randomBytes(n interface{}) ([]byte, error) {
+	size, ok := n.(float64)
+	if !ok || size < 1 || size > 1024 || size != float64(int(size)) {
+		return nil, fmt.Errorf("invalid size %v: expected a number of bytes between 1 and 1024", n)
+	}
+
+	b := make([]byte, int(size))
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
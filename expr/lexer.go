@@ -0,0 +1,125 @@
+package expr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokOp
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	value interface{} // parsed literal for strings and numbers
+	pos   int
+}
+
+// operators, longest first so that "==" is not read as "=".
+var operators = []string{"==", "!=", "&&", "||", "!", "+", "(", ")", "[", "]", ","}
+
+func tokenize(s string) ([]token, error) {
+	var tokens []token
+
+	for i := 0; i < len(s); {
+		c := s[i]
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+
+		case c == '"' || c == '\'':
+			value, end, err := readString(s, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokString, text: s[i:end], value: value, pos: i})
+			i = end
+
+		case isDigit(c) || (c == '-' && i+1 < len(s) && isDigit(s[i+1])):
+			end := i + 1
+			for end < len(s) && (isDigit(s[end]) || s[end] == '.') {
+				end++
+			}
+			value, err := strconv.ParseFloat(s[i:end], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q at %d", s[i:end], i)
+			}
+			tokens = append(tokens, token{kind: tokNumber, text: s[i:end], value: value, pos: i})
+			i = end
+
+		case isIdentStart(c):
+			end := i + 1
+			for end < len(s) && isIdentPart(s[end]) {
+				end++
+			}
+			tokens = append(tokens, token{kind: tokIdent, text: s[i:end], pos: i})
+			i = end
+
+		default:
+			op := ""
+			for _, candidate := range operators {
+				if strings.HasPrefix(s[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected character %q at %d", c, i)
+			}
+			tokens = append(tokens, token{kind: tokOp, text: op, pos: i})
+			i += len(op)
+		}
+	}
+
+	return append(tokens, token{kind: tokEOF, pos: len(s)}), nil
+}
+
+// readString reads the quoted string starting at s[start] and returns its
+// value and the index right after the closing quote.
+func readString(s string, start int) (string, int, error) {
+	quote := s[start]
+	var b strings.Builder
+
+	for i := start + 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == quote:
+			return b.String(), i + 1, nil
+		case c == '\\' && i+1 < len(s):
+			i++
+			switch s[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			default:
+				b.WriteByte(s[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	return "", 0, fmt.Errorf("unterminated string at %d", start)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || isDigit(c)
+}
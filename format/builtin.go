@@ -0,0 +1,142 @@
+package format
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"os"
+	"os/exec"
+	"os/user"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/paoloanzn/go-bootstrap/config"
+)
+
+var (
+	environmentOnce sync.Once
+	environment     map[string]interface{}
+)
+
+// Builtins returns the variables available to every template run with cfg:
+//
+//	year, date      the current year and date (2006-01-02)
+//	git_author      user.name from git config, or the OS user
+//	git_email       user.email from git config
+//	author, email   the author setting of the user configuration, or git_author
+//	                and git_email
+//	module_prefix   the module_prefix setting, or github.com/example
+//	license         the license setting, or MIT
+//	go_version      the version of the local Go toolchain, e.g. 1.24.1
+//	user            the OS user name
+//	secret_hex      32 random bytes, hex encoded
+//	secret_base64   32 random bytes, base64 encoded
+//
+// Values read from the environment are looked up once per process. The date
+// and the secrets come from cfg.RunBuiltins, which BuildPlan sets once per
+// run, so a secret used by several files of a project has the same value in
+// all of them while two projects get different ones. When it is nil, they
+// are drawn for this call only: cfg is only read, so one Config can be
+// shared by concurrent calls. Values that cannot be found are empty.
+func Builtins(cfg *config.Config) map[string]interface{} {
+	environmentOnce.Do(lookupEnvironment)
+	run := cfg.RunBuiltins
+	if run == nil {
+		run = RunBuiltins()
+	}
+
+	b := make(map[string]interface{}, len(environment)+len(run))
+	for name, value := range environment {
+		b[name] = value
+	}
+	for name, value := range run {
+		b[name] = value
+	}
+
+	settings := map[string]string{
+		"author":        cfg.Author,
+		"email":         cfg.Email,
+		"module_prefix": cfg.ModulePrefix,
+		"license":       cfg.License,
+	}
+	for name, value := range settings {
+		if value != "" {
+			b[name] = value
+		}
+	}
+
+	return b
+}
+
+// RunBuiltins draws the built-in values specific to a run: the date and the
+// secrets.
+func RunBuiltins() map[string]interface{} {
+	now := time.Now()
+
+	return map[string]interface{}{
+		"year":          now.Format("2006"),
+		"date":          now.Format("2006-01-02"),
+		"secret_hex":    hex.EncodeToString(secret()),
+		"secret_base64": base64.StdEncoding.EncodeToString(secret()),
+	}
+}
+
+func lookupEnvironment() {
+	username := osUser()
+
+	author := gitConfig("user.name")
+	if author == "" {
+		author = username
+	}
+	email := gitConfig("user.email")
+
+	environment = map[string]interface{}{
+		"git_author":    author,
+		"git_email":     email,
+		"author":        author,
+		"email":         email,
+		"module_prefix": "github.com/example",
+		"license":       "MIT",
+		"go_version":    goVersion(),
+		"user":          username,
+	}
+}
+
+func gitConfig(key string) string {
+	out, err := exec.Command("git", "config", "--get", key).Output()
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(out))
+}
+
+// goVersion asks the local toolchain, falling back to the version
+// go-bootstrap was built with.
+func goVersion() string {
+	version := runtime.Version()
+	if out, err := exec.Command("go", "env", "GOVERSION").Output(); err == nil {
+		version = strings.TrimSpace(string(out))
+	}
+
+	return strings.TrimPrefix(version, "go")
+}
+
+func osUser() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+
+	return os.Getenv("USER")
+}
+
+func secret() []byte {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err) // crypto/rand never fails on supported platforms
+	}
+
+	return b
+}
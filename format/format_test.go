@@ -2,6 +2,7 @@ package format
 
 import (
 	"testing"
+	"time"
 
 	"github.com/paoloanzn/go-bootstrap/config"
 )
@@ -29,7 +30,7 @@ func TestFormatPath(t *testing.T) {
 
 func TestMatchWildCards(t *testing.T) {
 	// Set up the configuration for testing
-	config.Cfg.ProjectName = "TestProject"
+	cfg := &config.Config{ProjectName: "TestProject"}
 
 	// Test cases
 	tests := []struct {
@@ -44,10 +45,66 @@ func TestMatchWildCards(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.input, func(t *testing.T) {
-			result := MatchWildCards(tt.input)
+			result := MatchWildCards(cfg, tt.input)
 			if result != tt.expected {
 				t.Errorf("MatchWildCards(%q) = %q; want %q", tt.input, result, tt.expected)
 			}
 		})
 	}
 }
+
+func TestMatchWildCardsUnknown(t *testing.T) {
+	cfg := &config.Config{ProjectName: "TestProject"}
+
+	// Unknown patterns must survive replacement, e.g. Go channel syntax or HTML.
+	input := "<main_package> <div> <-ch"
+	expected := "TestProject <div> <-ch"
+
+	result := MatchWildCards(cfg, input)
+	if result != expected {
+		t.Errorf("MatchWildCards(%q) = %q; want %q", input, result, expected)
+	}
+}
+
+// TestBuiltins checks the built-in variables and that template variables
+// shadow them.
+func TestBuiltins(t *testing.T) {
+	cfg := &config.Config{}
+	builtins := Builtins(cfg)
+
+	if builtins["year"] != time.Now().Format("2006") {
+		t.Errorf("Expected the current year, got %v", builtins["year"])
+	}
+	if len(builtins["secret_hex"].(string)) != 64 {
+		t.Errorf("Expected a 64 characters hex secret, got %q", builtins["secret_hex"])
+	}
+	if builtins["go_version"] == "" {
+		t.Errorf("Expected a Go version")
+	}
+
+	// secrets are generated once per run, so every file gets the same value
+	run := &config.Config{RunBuiltins: RunBuiltins()}
+	if MatchWildCards(run, "<secret_hex>") != MatchWildCards(run, "<secret_hex>") {
+		t.Errorf("Expected <secret_hex> to be stable across matches")
+	}
+	if MatchWildCards(run, "<secret_hex>") == MatchWildCards(&config.Config{RunBuiltins: RunBuiltins()}, "<secret_hex>") {
+		t.Errorf("Expected another run to get another <secret_hex>")
+	}
+	if MatchWildCards(cfg, "<secret_hex>"); cfg.RunBuiltins != nil {
+		t.Errorf("Expected the Config to be left unchanged")
+	}
+
+	if MatchWildCards(cfg, "<module_prefix>") != "github.com/example" {
+		t.Errorf("Expected the default module prefix, got %q", MatchWildCards(cfg, "<module_prefix>"))
+	}
+
+	cfg = &config.Config{ModulePrefix: "github.com/acme", Variables: map[string]interface{}{"year": "1999"}}
+
+	if result := MatchWildCards(cfg, "<module_prefix>/x"); result != "github.com/acme/x" {
+		t.Errorf("Expected the user configuration to override module_prefix, got %q", result)
+	}
+
+	if result := MatchWildCards(cfg, "<year>"); result != "1999" {
+		t.Errorf("Expected the template variable to shadow the built-in one, got %q", result)
+	}
+}
@@ -1,35 +1,59 @@
 package format
 
 import (
+	"fmt"
 	"regexp"
 
 	"github.com/paoloanzn/go-bootstrap/config"
 )
 
-func DefaultWildCards() map[string]string {
+// MainPackage is the wildcard replaced with the project name.
+const MainPackage = "<main_package>"
+
+var wildCardPattern = regexp.MustCompile(`<([^>]+)>`) // match <string> pattern
+
+// DefaultWildCards returns the value of every wildcard of the run cfg: the
+// built-in variables, <main_package> and the template variables, which may
+// shadow built-in ones.
+func DefaultWildCards(cfg *config.Config) map[string]string {
 	w := make(map[string]string)
 
-	w["<main_package>"] = config.Cfg.ProjectName
+	for name, value := range Builtins(cfg) {
+		w[fmt.Sprintf("<%s>", name)] = fmt.Sprint(value)
+	}
+
+	w[MainPackage] = cfg.ProjectName
+
+	for name, value := range cfg.Variables {
+		w[fmt.Sprintf("<%s>", name)] = fmt.Sprint(value)
+	}
 
 	return w
 }
 
-func MatchWildCards(s string) string {
-	defaults := DefaultWildCards()
-	re := regexp.MustCompile(`<[^>]+>`) // match <string> pattern
-
-	replaced := s // copy
+// MatchWildCards replaces the wildcards of s with their value in cfg.
+func MatchWildCards(cfg *config.Config, s string) string {
+	defaults := DefaultWildCards(cfg)
 
-	allMatches := re.FindAllStringSubmatch(s, -1)
-	for _, m := range allMatches {
-		if len(m) > 0 {
-			key := m[0]
-			value, exists := defaults[key]
-			if exists {
-				replaced = re.ReplaceAllString(replaced, value)
-			}
+	replaced := wildCardPattern.ReplaceAllStringFunc(s, func(key string) string {
+		value, exists := defaults[key]
+		if exists {
+			return value
 		}
-	}
+
+		return key // leave unknown patterns untouched
+	})
 
 	return replaced
 }
+
+// WildCardNames returns the names of the wildcards used in s, without the
+// angle brackets.
+func WildCardNames(s string) []string {
+	var names []string
+	for _, match := range wildCardPattern.FindAllStringSubmatch(s, -1) {
+		names = append(names, match[1])
+	}
+
+	return names
+}
@@ -0,0 +1,243 @@
+// Package gobootstrap generates Go projects from go-bootstrap templates, for
+// tools that embed go-bootstrap instead of running its command.
+//
+//	tmpl, err := gobootstrap.Load("server")
+//	if err != nil {
+//		return err
+//	}
+//	result, err := gobootstrap.Generate(ctx, tmpl,
+//		gobootstrap.WithOutputDir("/srv/projects"),
+//		gobootstrap.WithVariables(map[string]interface{}{"deploy": "k8s"}),
+//	)
+//
+// The functions, types and options of this package follow semantic
+// versioning: they are not removed or changed incompatibly before a new major
+// version. Its types are its own rather than the ones of the internal
+// packages, so that those can change freely. They are safe for concurrent
+// use, and a template can be shared by concurrent calls to Generate.
+package gobootstrap
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log"
+
+	"github.com/paoloanzn/go-bootstrap/bootstrap"
+	"github.com/paoloanzn/go-bootstrap/config"
+	"github.com/paoloanzn/go-bootstrap/loader"
+	"github.com/paoloanzn/go-bootstrap/parsing"
+	"github.com/paoloanzn/go-bootstrap/source"
+)
+
+// Template is a parsed and validated template, see Load and Parse.
+type Template struct {
+	t *parsing.JSONTemplate
+}
+
+// ConflictPolicy decides what happens when a generated file already exists.
+type ConflictPolicy int
+
+const (
+	// ConflictSkip keeps existing files. It is the default.
+	ConflictSkip ConflictPolicy = iota
+	// ConflictOverwrite replaces existing files.
+	ConflictOverwrite
+	// ConflictFail makes Generate fail before writing anything when a file
+	// already exists.
+	ConflictFail
+)
+
+var conflictPolicies = map[ConflictPolicy]config.ConflictPolicy{
+	ConflictSkip:      config.ConflictSkip,
+	ConflictOverwrite: config.ConflictOverwrite,
+	ConflictFail:      config.ConflictFail,
+}
+
+// Result lists the paths handled by Generate, relative to the output
+// directory and in creation order. Directory paths end with a slash.
+type Result struct {
+	// Created are the paths that did not exist.
+	Created []string
+	// Skipped are the nodes left out by conditions of the template and the
+	// paths that existed and were kept.
+	Skipped []string
+	// Overwritten are the files replaced under ConflictOverwrite.
+	Overwritten []string
+	// Injected are the existing files changed by injections and Go edits.
+	// The ones already holding their changes are listed in Skipped.
+	Injected []string
+}
+
+// FS is a writable filesystem projects are generated into, see WithFS.
+// Names are slash separated paths, relative to the root of the filesystem,
+// and the parent directory of an entry exists before it is created.
+type FS interface {
+	// Stat returns a fs.ErrNotExist error when name does not exist.
+	Stat(name string) (fs.FileInfo, error)
+	Mkdir(name string, perm fs.FileMode) error
+	// WriteFile creates or truncates name.
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+}
+
+// MemFS is an in-memory FS, to preview or test a generation.
+type MemFS struct {
+	fs *bootstrap.MemFS
+}
+
+// NewMemFS returns an empty in-memory FS.
+func NewMemFS() *MemFS {
+	return &MemFS{fs: bootstrap.NewMemFS()}
+}
+
+func (m *MemFS) Stat(name string) (fs.FileInfo, error) {
+	return m.fs.Stat(name)
+}
+
+func (m *MemFS) Mkdir(name string, perm fs.FileMode) error {
+	return m.fs.Mkdir(name, perm)
+}
+
+func (m *MemFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	return m.fs.WriteFile(name, data, perm)
+}
+
+// ReadFile returns the content of the file name.
+func (m *MemFS) ReadFile(name string) ([]byte, error) {
+	return m.fs.ReadFile(name)
+}
+
+// Paths returns every path of m in lexical order, directories with a
+// trailing slash.
+func (m *MemFS) Paths() []string {
+	return m.fs.Paths()
+}
+
+// NewOSFS returns the filesystem of the operating system rooted at root.
+func NewOSFS(root string) FS {
+	return bootstrap.NewOSFS(root)
+}
+
+// Option configures a call to Generate.
+type Option func(*options)
+
+type options struct {
+	cfg *config.Config
+	fs  FS
+}
+
+// WithOutputDir generates the project in dir instead of the working
+// directory.
+func WithOutputDir(dir string) Option {
+	return func(o *options) {
+		o.cfg.OutputDir = dir
+	}
+}
+
+// WithName names the project folder and <main_package>, instead of the
+// config.name of the template.
+func WithName(name string) Option {
+	return func(o *options) {
+		o.cfg.ProjectName = name
+	}
+}
+
+// WithInPlace generates the content of the project directly in the output
+// directory, for instance an existing repository, instead of in a new folder.
+func WithInPlace() Option {
+	return func(o *options) {
+		o.cfg.InPlace = true
+	}
+}
+
+// WithVariables sets template variables. Strings are converted to the type of
+// the variable default, as for values given on the command line. It can be
+// repeated, later values win.
+func WithVariables(variables map[string]interface{}) Option {
+	return func(o *options) {
+		for name, value := range variables {
+			o.cfg.Variables[name] = value
+		}
+	}
+}
+
+// WithConflictPolicy sets what happens to files that already exist.
+func WithConflictPolicy(policy ConflictPolicy) Option {
+	return func(o *options) {
+		o.cfg.OnConflict = conflictPolicies[policy]
+	}
+}
+
+// WithLogger reports every created or overwritten path to logger. Nothing is
+// reported by default.
+func WithLogger(logger *log.Logger) Option {
+	return func(o *options) {
+		o.cfg.Logger = logger
+	}
+}
+
+// WithFS generates the project in fsys, such as a MemFS, instead of the
+// output directory.
+func WithFS(fsys FS) Option {
+	return func(o *options) {
+		o.fs = fsys
+	}
+}
+
+// Load resolves ref like the init command does: as a git or HTTP(S) source, a
+// path to a JSON file, a template of the user registry or a built-in template.
+// Templates it extends or includes are loaded and merged.
+func Load(ref string) (*Template, error) {
+	tmpl, err := loader.Load(ref, source.Options{})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Template{t: tmpl}, nil
+}
+
+// Parse parses and validates a template held in memory. It cannot extend or
+// include other templates, use Load for those.
+func Parse(data []byte) (*Template, error) {
+	tmpl, err := parsing.ParseTemplateData(data, "template")
+	if err != nil {
+		return nil, err
+	}
+	if tmpl.Extends != "" || len(tmpl.Include) > 0 {
+		return nil, fmt.Errorf("Parse cannot resolve extends and include, use Load instead.")
+	}
+
+	err = parsing.ValidateTemplate(tmpl)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Template{t: tmpl}, nil
+}
+
+// Generate creates the project described by tmpl. It stops before the next
+// path once ctx is done. On errors, the result lists what was done until
+// then.
+func Generate(ctx context.Context, tmpl *Template, opts ...Option) (*Result, error) {
+	o := &options{cfg: &config.Config{Variables: make(map[string]interface{})}}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.fs == nil {
+		o.fs = NewOSFS(o.cfg.OutputDir)
+	}
+
+	plan, err := bootstrap.BuildPlan(o.cfg, tmpl.t)
+	if err != nil {
+		return &Result{}, err
+	}
+
+	applied, err := bootstrap.ApplyFS(ctx, o.fs, o.cfg, plan)
+
+	return &Result{
+		Created:     applied.Created,
+		Skipped:     applied.Skipped,
+		Overwritten: applied.Overwritten,
+		Injected:    applied.Injected,
+	}, err
+}
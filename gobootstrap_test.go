@@ -0,0 +1,177 @@
+package gobootstrap
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const testTemplate = `{
+	"variables": {
+		"service": {"default": "api"},
+		"with_docker": {"default": false}
+	},
+	"project": {
+		"cmd": {
+			"<service>": {"main.go": {"$type": "file", "$content": "// <service>\n"}}
+		},
+		"Dockerfile": {"$type": "file", "$if": "with_docker"}
+	},
+	"config": {"name": "svc"}
+}`
+
+func parseTestTemplate(t *testing.T) *Template {
+	t.Helper()
+
+	tmpl, err := Parse([]byte(testTemplate))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	return tmpl
+}
+
+// TestGenerate checks the result of a generation and of a second one over it
+// under each conflict policy.
+func TestGenerate(t *testing.T) {
+	tmpl := parseTestTemplate(t)
+	dir := t.TempDir()
+
+	result, err := Generate(context.Background(), tmpl, WithOutputDir(dir), WithVariables(map[string]interface{}{"service": "orders"}))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	expected := &Result{
+		Created: []string{"svc/", "svc/cmd/", "svc/cmd/orders/", "svc/cmd/orders/main.go"},
+		Skipped: []string{"svc/Dockerfile"},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, result)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "svc", "cmd", "orders", "main.go"))
+	if err != nil || string(data) != "// orders\n" {
+		t.Errorf("Expected main.go to hold '// orders', got %q (%v)", data, err)
+	}
+
+	vars := WithVariables(map[string]interface{}{"service": "orders"})
+
+	result, err = Generate(context.Background(), tmpl, WithOutputDir(dir), vars, WithConflictPolicy(ConflictOverwrite))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !reflect.DeepEqual(result.Overwritten, []string{"svc/cmd/orders/main.go"}) || len(result.Created) != 0 {
+		t.Errorf("Expected main.go to be overwritten and nothing created, got %+v", result)
+	}
+
+	_, err = Generate(context.Background(), tmpl, WithOutputDir(dir), vars, WithConflictPolicy(ConflictFail))
+	if err == nil || !strings.Contains(err.Error(), "svc/cmd/orders/main.go") {
+		t.Errorf("Expected a conflict error naming main.go, got: %v", err)
+	}
+}
+
+// TestGenerateInjected checks that the files changed by injections and Go
+// edits are listed once, and skipped when generated again.
+func TestGenerateInjected(t *testing.T) {
+	tmpl, err := Parse([]byte(`{
+		"project": {
+			"routes.go": {"$type": "inject", "$content": "// <main_package> routes", "$after": "package"},
+			"main.go": {"$type": "go", "$edits": [{"func": "main", "statement": "println(\"<main_package>\")"}]}
+		},
+		"config": {"name": "svc"}
+	}`))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	fsys := NewMemFS()
+	fsys.WriteFile("routes.go", []byte("package main\n"), 0644)
+	fsys.WriteFile("main.go", []byte("package main\n\nfunc main() {\n}\n"), 0644)
+
+	result, err := Generate(context.Background(), tmpl, WithFS(fsys), WithInPlace())
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !reflect.DeepEqual(result.Injected, []string{"main.go", "routes.go"}) {
+		t.Errorf("Expected main.go and routes.go to be injected, got %+v", result)
+	}
+	if data, _ := fsys.ReadFile("main.go"); !strings.Contains(string(data), `println("svc")`) {
+		t.Errorf("Expected main.go to be edited, got %q", data)
+	}
+
+	result, err = Generate(context.Background(), tmpl, WithFS(fsys), WithInPlace())
+	if err != nil || len(result.Injected) != 0 || len(result.Skipped) != 2 {
+		t.Errorf("Expected both changes to be skipped, got %+v (%v)", result, err)
+	}
+}
+
+// TestGenerateCanceled checks that nothing is written once ctx is done.
+func TestGenerateCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	dir := t.TempDir()
+	result, err := Generate(ctx, parseTestTemplate(t), WithOutputDir(dir))
+	if err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got: %v", err)
+	}
+	if len(result.Created) != 0 {
+		t.Errorf("Expected nothing to be created, got %v", result.Created)
+	}
+}
+
+// TestGenerateConcurrent shares a template between concurrent generations.
+// Run with -race.
+func TestGenerateConcurrent(t *testing.T) {
+	tmpl := parseTestTemplate(t)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			dir := t.TempDir()
+			service := fmt.Sprintf("svc%d", i)
+			_, err := Generate(context.Background(), tmpl, WithOutputDir(dir), WithVariables(map[string]interface{}{"service": service}))
+			if err != nil {
+				t.Errorf("Expected no error, got: %v", err)
+				return
+			}
+
+			if _, err := os.Stat(filepath.Join(dir, "svc", "cmd", service, "main.go")); err != nil {
+				t.Errorf("Expected the main.go of %s: %v", service, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+// TestParse checks that Parse validates templates and rejects composition.
+func TestParse(t *testing.T) {
+	for _, data := range []string{`{"project": {}}`, `{"extends": "base", "project": {}, "config": {"name": "x"}}`, `{`} {
+		if _, err := Parse([]byte(data)); err == nil {
+			t.Errorf("Expected an error parsing %s, got nil", data)
+		}
+	}
+}
+
+// TestGenerateMemFS previews a generation in memory.
+func TestGenerateMemFS(t *testing.T) {
+	fsys := NewMemFS()
+
+	_, err := Generate(context.Background(), parseTestTemplate(t), WithFS(fsys))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	expected := []string{"svc/", "svc/.go-bootstrap/", "svc/.go-bootstrap/manifest.json", "svc/cmd/", "svc/cmd/api/", "svc/cmd/api/main.go"}
+	if !reflect.DeepEqual(fsys.Paths(), expected) {
+		t.Errorf("Expected %v, got %v", expected, fsys.Paths())
+	}
+}
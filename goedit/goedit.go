@@ -0,0 +1,569 @@
+// Package goedit edits Go source files through their syntax tree rather than
+// as text: it adds imports, statements to function bodies, struct fields and
+// methods. Edits are idempotent, leave the rest of the file as it is and
+// return gofmt-ed source.
+package goedit
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"strings"
+)
+
+// Edit is a single change of a Go file. Exactly one of Import, Statement,
+// Field and Method is set, along with what it applies to:
+//
+//	{"import": "net/http"}
+//	{"func": "Routes", "statement": "registerOrderRoutes(mux)"}
+//	{"struct": "Server", "field": "orders *OrderStore"}
+//	{"type": "Server", "method": "func (s *Server) Orders() *OrderStore { return s.orders }"}
+type Edit struct {
+	Import string `json:"import,omitempty"`
+	Name   string `json:"name,omitempty"` // of the import, optional
+
+	Func      string `json:"func,omitempty"` // Name or Type.Name for methods
+	Statement string `json:"statement,omitempty"`
+
+	Struct string `json:"struct,omitempty"`
+	Field  string `json:"field,omitempty"`
+
+	Type   string `json:"type,omitempty"`
+	Method string `json:"method,omitempty"`
+}
+
+// Validate checks that e describes a single edit.
+func (e Edit) Validate() error {
+	switch {
+	case e.Import != "" && e.Func == "" && e.Statement == "" && e.Struct == "" && e.Field == "" && e.Type == "" && e.Method == "":
+	case e.Func != "" && e.Statement != "" && e.Import == "" && e.Name == "" && e.Struct == "" && e.Field == "" && e.Type == "" && e.Method == "":
+	case e.Struct != "" && e.Field != "" && e.Import == "" && e.Name == "" && e.Func == "" && e.Statement == "" && e.Type == "" && e.Method == "":
+	case e.Type != "" && e.Method != "" && e.Import == "" && e.Name == "" && e.Func == "" && e.Statement == "" && e.Struct == "" && e.Field == "":
+	default:
+		return fmt.Errorf("Invalid Go edit: expected one of import, func and statement, struct and field, or type and method.")
+	}
+
+	return nil
+}
+
+// String describes e, as shown by dry runs.
+func (e Edit) String() string {
+	switch {
+	case e.Import != "" && e.Name != "":
+		return fmt.Sprintf("import %s %q", e.Name, e.Import)
+	case e.Import != "":
+		return fmt.Sprintf("import %q", e.Import)
+	case e.Statement != "":
+		return fmt.Sprintf("statement in %s", e.Func)
+	case e.Field != "":
+		return fmt.Sprintf("field of %s", e.Struct)
+	default:
+		return fmt.Sprintf("method of %s", e.Type)
+	}
+}
+
+// Apply applies e to src. It reports false, with src unchanged, when src
+// already holds what e adds.
+func (e Edit) Apply(src []byte) ([]byte, bool, error) {
+	err := e.Validate()
+	if err != nil {
+		return nil, false, err
+	}
+
+	switch {
+	case e.Import != "":
+		return AddImport(src, e.Import, e.Name)
+	case e.Statement != "":
+		return AddStatement(src, e.Func, e.Statement)
+	case e.Field != "":
+		return AddField(src, e.Struct, e.Field)
+	default:
+		return AddMethod(src, e.Type, e.Method)
+	}
+}
+
+// file is a parsed source file being edited.
+type file struct {
+	src  []byte
+	fset *token.FileSet
+	ast  *ast.File
+}
+
+func parse(src []byte) (*file, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid Go source: %v", err)
+	}
+
+	return &file{src: src, fset: fset, ast: f}, nil
+}
+
+func (f *file) offset(pos token.Pos) int {
+	return f.fset.Position(pos).Offset
+}
+
+func (f *file) line(pos token.Pos) int {
+	return f.fset.Position(pos).Line
+}
+
+// lineStart returns the offset of the start of the line of pos, and whether
+// only blanks precede pos on it.
+func (f *file) lineStart(pos token.Pos) (int, bool) {
+	offset := f.offset(pos)
+	start := bytes.LastIndexByte(f.src[:offset], '\n') + 1
+	return start, len(bytes.TrimSpace(f.src[start:offset])) == 0
+}
+
+// before returns the offset to insert a line before pos at, with the prefix
+// the line needs when pos does not start its own line.
+func (f *file) before(pos token.Pos) (int, string) {
+	start, ownLine := f.lineStart(pos)
+	if !ownLine {
+		return f.offset(pos), "\n"
+	}
+
+	return start, ""
+}
+
+// after returns the offset of the start of the line following pos.
+func (f *file) after(pos token.Pos) int {
+	offset := f.offset(pos)
+	end := bytes.IndexByte(f.src[offset:], '\n')
+	if end < 0 {
+		return len(f.src)
+	}
+
+	return offset + end + 1
+}
+
+// insert returns the source of f with s inserted at offset, gofmt-ed.
+func (f *file) insert(offset int, s string) ([]byte, bool, error) {
+	var buf bytes.Buffer
+	buf.Write(f.src[:offset])
+	buf.WriteString(s)
+	buf.Write(f.src[offset:])
+
+	return gofmt(buf.Bytes())
+}
+
+func gofmt(src []byte) ([]byte, bool, error) {
+	formatted, err := format.Source(src)
+	if err != nil {
+		return nil, false, fmt.Errorf("Invalid Go source after the edit: %v", err)
+	}
+
+	return formatted, true, nil
+}
+
+// text returns the source of node, gofmt-ed.
+func text(fset *token.FileSet, node interface{}) string {
+	var buf bytes.Buffer
+	format.Node(&buf, fset, node)
+	return buf.String()
+}
+
+// AddImport imports path into src, named name if not empty. The import goes
+// to the group of standard library imports, or to the group of other imports
+// sharing the longest prefix with path, in sorted order.
+func AddImport(src []byte, path string, name string) ([]byte, bool, error) {
+	f, err := parse(src)
+	if err != nil {
+		return nil, false, err
+	}
+
+	for _, spec := range f.ast.Imports {
+		existing, _ := strconv.Unquote(spec.Path.Value)
+		if existing != path {
+			continue
+		}
+		if importName(spec) == name {
+			return src, false, nil
+		}
+		return nil, false, fmt.Errorf("%s is already imported as %s.", path, importName(spec))
+	}
+
+	line := strconv.Quote(path)
+	if name != "" {
+		line = name + " " + line
+	}
+
+	var block, single *ast.GenDecl
+	for _, decl := range f.ast.Decls {
+		d, ok := decl.(*ast.GenDecl)
+		if !ok || d.Tok != token.IMPORT || isCgo(d) {
+			continue
+		}
+		if d.Lparen.IsValid() {
+			block = d
+			break
+		}
+		single = d
+	}
+
+	switch {
+	case block != nil:
+		return f.insertImport(block, path, line)
+
+	case single != nil:
+		// turn the single import into a block
+		spec := single.Specs[0].(*ast.ImportSpec)
+		existing, _ := strconv.Unquote(spec.Path.Value)
+		specs := []string{string(f.src[f.offset(spec.Pos()):f.offset(spec.End())]), line}
+		if isStd(path) == isStd(existing) && path < existing || isStd(path) && !isStd(existing) {
+			specs[0], specs[1] = specs[1], specs[0]
+		}
+		separator := "\n\t"
+		if isStd(path) != isStd(existing) {
+			separator = "\n\n\t"
+		}
+
+		var buf bytes.Buffer
+		buf.Write(f.src[:f.offset(single.Pos())])
+		fmt.Fprintf(&buf, "import (\n\t%s%s%s\n)", specs[0], separator, specs[1])
+		buf.Write(f.src[f.offset(single.End()):])
+		return gofmt(buf.Bytes())
+
+	default:
+		return f.insert(f.offset(f.ast.Name.End()), fmt.Sprintf("\n\nimport %s", line))
+	}
+}
+
+// insertImport adds the import line of path to the import block decl.
+func (f *file) insertImport(decl *ast.GenDecl, path string, line string) ([]byte, bool, error) {
+	// groups of imports are separated by blank lines
+	var groups [][]*ast.ImportSpec
+	for i, s := range decl.Specs {
+		spec := s.(*ast.ImportSpec)
+		if i == 0 || f.line(specStart(spec)) > f.line(decl.Specs[i-1].End())+1 {
+			groups = append(groups, nil)
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], spec)
+	}
+
+	var group []*ast.ImportSpec
+	best := -1
+	for _, g := range groups {
+		first, _ := strconv.Unquote(g[0].Path.Value)
+		if isStd(path) != isStd(first) {
+			continue
+		}
+		if isStd(path) {
+			group = g
+			break
+		}
+		for _, spec := range g {
+			p, _ := strconv.Unquote(spec.Path.Value)
+			if n := commonPrefix(p, path); n >= best {
+				group, best = g, n
+			}
+		}
+	}
+
+	if group == nil {
+		// a new group, standard library imports first
+		if isStd(path) {
+			offset, prefix := f.before(specStart(groups[0][0]))
+			return f.insert(offset, fmt.Sprintf("%s\t%s\n\n", prefix, line))
+		}
+		offset, prefix := f.before(decl.Rparen)
+		return f.insert(offset, fmt.Sprintf("%s\n\t%s\n", prefix, line))
+	}
+
+	for _, spec := range group {
+		p, _ := strconv.Unquote(spec.Path.Value)
+		if path < p {
+			offset, prefix := f.before(specStart(spec))
+			return f.insert(offset, fmt.Sprintf("%s\t%s\n", prefix, line))
+		}
+	}
+
+	last := group[len(group)-1]
+	end := last.End()
+	if last.Comment != nil {
+		end = last.Comment.End()
+	}
+	return f.insert(f.offset(end), fmt.Sprintf("\n\t%s", line))
+}
+
+func specStart(spec *ast.ImportSpec) token.Pos {
+	if spec.Doc != nil {
+		return spec.Doc.Pos()
+	}
+
+	return spec.Pos()
+}
+
+func importName(spec *ast.ImportSpec) string {
+	if spec.Name == nil {
+		return ""
+	}
+
+	return spec.Name.Name
+}
+
+// isCgo reports whether decl is the import of the "C" pseudo package, which
+// must stay on its own.
+func isCgo(decl *ast.GenDecl) bool {
+	for _, spec := range decl.Specs {
+		if spec.(*ast.ImportSpec).Path.Value == `"C"` {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isStd reports whether path is in the standard library, whose first path
+// element has no dot.
+func isStd(path string) bool {
+	first, _, _ := strings.Cut(path, "/")
+	return !strings.Contains(first, ".")
+}
+
+func commonPrefix(a, b string) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+
+	return n
+}
+
+// AddStatement adds stmt, one or more statements, at the end of the body of
+// the function fn, or of the method fn written Type.Method. A final return
+// statement stays last, along with the comments right before it. Statements
+// the body already holds are not added again.
+func AddStatement(src []byte, fn string, stmt string) ([]byte, bool, error) {
+	f, err := parse(src)
+	if err != nil {
+		return nil, false, err
+	}
+
+	decl := f.findFunc(fn)
+	if decl == nil || decl.Body == nil {
+		return nil, false, fmt.Errorf("Function %s not found.", fn)
+	}
+
+	snippetSrc := "package p\nfunc _() {\n" + stmt + "\n}"
+	snippetSet := token.NewFileSet()
+	snippet, err := parser.ParseFile(snippetSet, "", snippetSrc, 0)
+	if err != nil {
+		return nil, false, fmt.Errorf("Invalid statement %q: %v", stmt, err)
+	}
+
+	existing := make(map[string]bool)
+	for _, s := range decl.Body.List {
+		existing[text(f.fset, s)] = true
+	}
+	statements := snippet.Decls[0].(*ast.FuncDecl).Body.List
+	var missing []string
+	for _, s := range statements {
+		if !existing[text(snippetSet, s)] {
+			start, end := snippetSet.Position(s.Pos()).Offset, snippetSet.Position(s.End()).Offset
+			missing = append(missing, snippetSrc[start:end])
+		}
+	}
+	if len(missing) == 0 {
+		return src, false, nil
+	}
+	// the snippet is kept whole, with its comments, unless part of it is
+	// already there
+	if len(missing) < len(statements) {
+		stmt = strings.Join(missing, "\n")
+	}
+
+	body := decl.Body
+	if n := len(body.List); n > 0 {
+		if ret, ok := body.List[n-1].(*ast.ReturnStmt); ok {
+			// insert right after the previous statement, so that the
+			// comments and blank lines leading to the return stay with it
+			previous := body.Lbrace
+			if n > 1 {
+				previous = body.List[n-2].End()
+			}
+			next := ret.Pos()
+			for _, c := range f.ast.Comments {
+				if c.Pos() > previous && c.Pos() < next && f.line(c.Pos()) > f.line(previous) {
+					next = c.Pos()
+				}
+			}
+
+			if f.line(previous) < f.line(next) {
+				return f.insert(f.after(previous), stmt+"\n")
+			}
+			return f.insert(f.offset(next), stmt+"\n")
+		}
+	}
+
+	offset, prefix := f.before(body.Rbrace)
+	return f.insert(offset, prefix+stmt+"\n")
+}
+
+// findFunc returns the declaration of the function name, or of the method
+// Type.Method.
+func (f *file) findFunc(name string) *ast.FuncDecl {
+	typeName, method, isMethod := strings.Cut(name, ".")
+	for _, decl := range f.ast.Decls {
+		d, ok := decl.(*ast.FuncDecl)
+		if !ok {
+			continue
+		}
+		if !isMethod && d.Recv == nil && d.Name.Name == name {
+			return d
+		}
+		if isMethod && d.Recv != nil && d.Name.Name == method && receiverType(d) == typeName {
+			return d
+		}
+	}
+
+	return nil
+}
+
+// receiverType returns the name of the receiver type of the method decl.
+func receiverType(decl *ast.FuncDecl) string {
+	t := decl.Recv.List[0].Type
+	if star, ok := t.(*ast.StarExpr); ok {
+		t = star.X
+	}
+	switch x := t.(type) {
+	case *ast.IndexExpr:
+		t = x.X
+	case *ast.IndexListExpr:
+		t = x.X
+	}
+
+	if ident, ok := t.(*ast.Ident); ok {
+		return ident.Name
+	}
+	return ""
+}
+
+// AddField adds field, e.g. "orders *OrderStore `json:\"orders\"`", at the
+// end of the struct type structName. A field of the same name must have the
+// same type.
+func AddField(src []byte, structName string, field string) ([]byte, bool, error) {
+	f, err := parse(src)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var st *ast.StructType
+	ast.Inspect(f.ast, func(n ast.Node) bool {
+		spec, ok := n.(*ast.TypeSpec)
+		if ok && spec.Name.Name == structName {
+			st, _ = spec.Type.(*ast.StructType)
+		}
+		return st == nil
+	})
+	if st == nil {
+		return nil, false, fmt.Errorf("Struct %s not found.", structName)
+	}
+
+	snippetSet := token.NewFileSet()
+	snippet, err := parser.ParseFile(snippetSet, "", "package p\ntype _ struct {\n"+field+"\n}", 0)
+	if err != nil {
+		return nil, false, fmt.Errorf("Invalid field %q: %v", field, err)
+	}
+	fields := snippet.Decls[0].(*ast.GenDecl).Specs[0].(*ast.TypeSpec).Type.(*ast.StructType).Fields.List
+
+	existing := make(map[string]string)
+	for _, fd := range st.Fields.List {
+		for _, name := range fieldNames(fd) {
+			existing[name] = text(f.fset, fd.Type)
+		}
+	}
+
+	added := false
+	for _, fd := range fields {
+		for _, name := range fieldNames(fd) {
+			fieldType, exists := existing[name]
+			if !exists {
+				added = true
+				continue
+			}
+			if fieldType != text(snippetSet, fd.Type) {
+				return nil, false, fmt.Errorf("Field %s of %s already exists with type %s.", name, structName, fieldType)
+			}
+		}
+	}
+	if !added {
+		return src, false, nil
+	}
+
+	offset, prefix := f.before(st.Fields.Closing)
+	return f.insert(offset, prefix+field+"\n")
+}
+
+// fieldNames returns the names of fd, or the name of its type for embedded
+// fields.
+func fieldNames(fd *ast.Field) []string {
+	if len(fd.Names) == 0 {
+		t := fd.Type
+		if star, ok := t.(*ast.StarExpr); ok {
+			t = star.X
+		}
+		if sel, ok := t.(*ast.SelectorExpr); ok {
+			t = sel.Sel
+		}
+		if ident, ok := t.(*ast.Ident); ok {
+			return []string{ident.Name}
+		}
+		return nil
+	}
+
+	names := make([]string, 0, len(fd.Names))
+	for _, name := range fd.Names {
+		names = append(names, name.Name)
+	}
+	return names
+}
+
+// AddMethod adds method, the source of a method of typeName, after the last
+// method of typeName in src, or after its declaration, or at the end. A
+// method of the same name is left as it is.
+func AddMethod(src []byte, typeName string, method string) ([]byte, bool, error) {
+	f, err := parse(src)
+	if err != nil {
+		return nil, false, err
+	}
+
+	snippet, err := parser.ParseFile(token.NewFileSet(), "", "package p\n"+method, parser.ParseComments)
+	if err != nil || len(snippet.Decls) != 1 {
+		return nil, false, fmt.Errorf("Invalid method %q: expected a single method declaration.", method)
+	}
+	decl, ok := snippet.Decls[0].(*ast.FuncDecl)
+	if !ok || decl.Recv == nil || receiverType(decl) != typeName {
+		return nil, false, fmt.Errorf("Invalid method %q: expected a method of %s.", method, typeName)
+	}
+
+	if f.findFunc(typeName+"."+decl.Name.Name) != nil {
+		return src, false, nil
+	}
+
+	end := token.NoPos
+	for _, d := range f.ast.Decls {
+		switch d := d.(type) {
+		case *ast.FuncDecl:
+			if d.Recv != nil && receiverType(d) == typeName {
+				end = d.End()
+			}
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				if ts, ok := spec.(*ast.TypeSpec); ok && ts.Name.Name == typeName && end == token.NoPos {
+					end = d.End()
+				}
+			}
+		}
+	}
+
+	offset := len(f.src)
+	if end.IsValid() {
+		offset = f.offset(end)
+	}
+	return f.insert(offset, "\n\n"+strings.TrimSpace(method)+"\n")
+}
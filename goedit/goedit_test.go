@@ -0,0 +1,201 @@
+package goedit
+
+import (
+	"strings"
+	"testing"
+)
+
+const server = `package http
+
+import (
+	"fmt"
+	"os"
+
+	"example.com/svc/store"
+	"github.com/rs/zerolog"
+)
+
+// Server serves the API.
+type Server struct {
+	store *store.Store
+}
+
+func (s *Server) Close() error { return nil }
+
+// Routes returns the handler serving every route of the service.
+func Routes() http.Handler {
+	mux := http.NewServeMux()
+	registerUserRoutes(mux)
+
+	// more routes
+	return mux
+}
+
+func run() {
+	fmt.Println(os.Args)
+}
+`
+
+// TestAddImport checks that imports land in the group they belong to, sorted,
+// and that files without imports or with a single one get them.
+func TestAddImport(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		path     string
+		alias    string
+		expected string
+	}{
+		{"standard library", server, "net/http", "", "\t\"fmt\"\n\t\"net/http\"\n\t\"os\"\n\n"},
+		{"closest group", server, "example.com/svc/orders", "", "\t\"example.com/svc/orders\"\n\t\"example.com/svc/store\"\n"},
+		{"named", server, "github.com/rs/zerolog/log", "zlog", "\t\"github.com/rs/zerolog\"\n\tzlog \"github.com/rs/zerolog/log\"\n)"},
+		{"already imported", server, "os", "", server},
+		{"no imports", "package a\n\nvar x = 1\n", "fmt", "", "package a\n\nimport \"fmt\"\n\nvar x = 1\n"},
+		{"single import", "package a\n\nimport \"os\"\n", "fmt", "", "package a\n\nimport (\n\t\"fmt\"\n\t\"os\"\n)\n"},
+		{"new group", "package a\n\nimport \"os\"\n", "example.com/x", "", "import (\n\t\"os\"\n\n\t\"example.com/x\"\n)\n"},
+		{"new standard group", "package a\n\nimport (\n\t\"example.com/x\"\n)\n", "io", "", "import (\n\t\"io\"\n\n\t\"example.com/x\"\n)\n"},
+	}
+
+	for _, tt := range tests {
+		out, _, err := AddImport([]byte(tt.src), tt.path, tt.alias)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+		if !strings.Contains(string(out), tt.expected) {
+			t.Errorf("%s: expected %q in\n%s", tt.name, tt.expected, out)
+		}
+
+		again, changed, err := AddImport(out, tt.path, tt.alias)
+		if err != nil || changed || string(again) != string(out) {
+			t.Errorf("%s: expected adding the import again to change nothing, got %v and\n%s", tt.name, err, again)
+		}
+	}
+
+	if _, _, err := AddImport([]byte(server), "os", "system"); err == nil {
+		t.Errorf("Expected an error importing os again under another name")
+	}
+}
+
+// TestAddStatement checks that statements go before a final return and the
+// comments leading to it, or at the end of the body, and only once.
+func TestAddStatement(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		fn       string
+		stmt     string
+		expected string
+	}{
+		{"before return", server, "Routes", "registerOrderRoutes(mux)", "\tregisterUserRoutes(mux)\n\tregisterOrderRoutes(mux)\n\n\t// more routes\n\treturn mux\n"},
+		{"end of body", server, "run", "fmt.Println(\"done\")", "\tfmt.Println(os.Args)\n\tfmt.Println(\"done\")\n}"},
+		{"method", server, "Server.Close", "s.store = nil", "func (s *Server) Close() error {\n\ts.store = nil\n\treturn nil\n}"},
+		{"line comment", "package a\n\nfunc f() int {\n\tx() // x\n\treturn 1\n}\n", "f", "y()", "\tx() // x\n\ty()\n\treturn 1\n"},
+		{"empty body", "package a\n\nfunc f() {}\n", "f", "x := 1\n_ = x", "func f() {\n\tx := 1\n\t_ = x\n}"},
+		{"already there", server, "Routes", "registerUserRoutes( mux )", server},
+		{"partly there", server, "Routes", "registerUserRoutes(mux)\nregisterOrderRoutes(mux)", "\tregisterUserRoutes(mux)\n\tregisterOrderRoutes(mux)\n\n\t// more routes\n"},
+	}
+
+	for _, tt := range tests {
+		out, _, err := AddStatement([]byte(tt.src), tt.fn, tt.stmt)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+		if !strings.Contains(string(out), tt.expected) {
+			t.Errorf("%s: expected %q in\n%s", tt.name, tt.expected, out)
+		}
+
+		if _, changed, _ := AddStatement(out, tt.fn, tt.stmt); changed {
+			t.Errorf("%s: expected adding the statement again to change nothing", tt.name)
+		}
+		for _, s := range strings.Split(tt.stmt, "\n") {
+			if n := strings.Count(string(out), "\t"+s+"\n"); n > 1 {
+				t.Errorf("%s: expected %q once, got it %d times in\n%s", tt.name, s, n, out)
+			}
+		}
+	}
+
+	for _, tt := range []struct{ fn, stmt string }{{"missing", "x()"}, {"Routes", "x("}, {"Other.Close", "x()"}} {
+		if _, _, err := AddStatement([]byte(server), tt.fn, tt.stmt); err == nil {
+			t.Errorf("Expected an error adding %q to %s", tt.stmt, tt.fn)
+		}
+	}
+}
+
+// TestAddField checks that fields are added at the end of the struct, once,
+// and that a field of the same name with another type is an error.
+func TestAddField(t *testing.T) {
+	out, changed, err := AddField([]byte(server), "Server", "orders *store.Orders `json:\"orders\"`")
+	if err != nil || !changed {
+		t.Fatalf("Expected the field to be added, got %v", err)
+	}
+	expected := "\tstore  *store.Store\n\torders *store.Orders `json:\"orders\"`\n}"
+	if !strings.Contains(string(out), expected) {
+		t.Errorf("Expected %q in\n%s", expected, out)
+	}
+
+	if _, changed, _ := AddField(out, "Server", "orders *store.Orders"); changed {
+		t.Errorf("Expected adding the field again to change nothing")
+	}
+	if _, _, err := AddField(out, "Server", "orders []string"); err == nil {
+		t.Errorf("Expected an error adding a field of another type")
+	}
+	if _, _, err := AddField(out, "Client", "x int"); err == nil {
+		t.Errorf("Expected an error adding a field to a missing struct")
+	}
+
+	out, _, err = AddField([]byte("package a\n\ntype T struct{}\n"), "T", "sync.Mutex")
+	if err != nil || !strings.Contains(string(out), "type T struct {\n\tsync.Mutex\n}") {
+		t.Errorf("Expected an embedded field, got %v and\n%s", err, out)
+	}
+}
+
+// TestAddMethod checks that methods follow the last method of their type, and
+// are only added once.
+func TestAddMethod(t *testing.T) {
+	method := "// Orders returns the order store.\nfunc (s *Server) Orders() *store.Orders { return nil }"
+	out, changed, err := AddMethod([]byte(server), "Server", method)
+	if err != nil || !changed {
+		t.Fatalf("Expected the method to be added, got %v", err)
+	}
+	expected := "func (s *Server) Close() error { return nil }\n\n// Orders returns the order store.\nfunc (s *Server) Orders() *store.Orders { return nil }\n\n// Routes"
+	if !strings.Contains(string(out), expected) {
+		t.Errorf("Expected %q in\n%s", expected, out)
+	}
+
+	if _, changed, _ := AddMethod(out, "Server", "func (s Server) Orders() {}"); changed {
+		t.Errorf("Expected adding the method again to change nothing")
+	}
+	if _, _, err := AddMethod(out, "Server", "func Orders() {}"); err == nil {
+		t.Errorf("Expected an error adding a function as a method")
+	}
+
+	out, _, err = AddMethod([]byte("package a\n\ntype T int\n\nvar x = 1\n"), "T", "func (T) String() string { return \"\" }")
+	if err != nil || !strings.Contains(string(out), "type T int\n\nfunc (T) String() string { return \"\" }\n\nvar x") {
+		t.Errorf("Expected the method after the type, got %v and\n%s", err, out)
+	}
+}
+
+// TestEdit checks that edits describe a single change.
+func TestEdit(t *testing.T) {
+	valid := []Edit{
+		{Import: "fmt"},
+		{Import: "fmt", Name: "f"},
+		{Func: "main", Statement: "x()"},
+		{Struct: "T", Field: "x int"},
+		{Type: "T", Method: "func (T) M() {}"},
+	}
+	for _, edit := range valid {
+		if err := edit.Validate(); err != nil {
+			t.Errorf("%+v: unexpected error: %v", edit, err)
+		}
+	}
+
+	invalid := []Edit{{}, {Name: "f"}, {Func: "main"}, {Import: "fmt", Field: "x int"}, {Struct: "T", Method: "func (T) M() {}"}}
+	for _, edit := range invalid {
+		if err := edit.Validate(); err == nil {
+			t.Errorf("%+v: expected an error", edit)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package loader
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/paoloanzn/go-bootstrap/parsing"
+)
+
+const projectPath = "/project"
+
+// compose merges the template extended by jsonTemplate, then its includes in
+// order, then jsonTemplate itself, each layer overriding the previous ones.
+func (l *loader) compose(jsonTemplate *parsing.JSONTemplate, loc *located, stack []string) (*parsing.JSONTemplate, error) {
+	result := &parsing.JSONTemplate{
+		Project:    make(map[string]interface{}),
+		Config:     make(map[string]interface{}),
+		Variables:  make(map[string]parsing.Variable),
+		Profiles:   make(map[string]parsing.Layer),
+		Features:   make(map[string]parsing.Layer),
+		Generators: make(map[string]parsing.Generator),
+		Origins:    map[string]string{projectPath: loc.origin},
+	}
+
+	var parents []string
+	if jsonTemplate.Extends != "" {
+		parents = append(parents, jsonTemplate.Extends)
+	}
+	parents = append(parents, jsonTemplate.Include...)
+
+	for _, ref := range parents {
+		parent, err := l.load(ref, loc, stack)
+		if err != nil {
+			return nil, fmt.Errorf("%v (loading %s from %s)", err, ref, loc.origin)
+		}
+
+		err = merge(result, parent)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	project, ok := jsonTemplate.Project.(map[string]interface{})
+	if !ok && jsonTemplate.Project != nil {
+		return nil, fmt.Errorf("Invalid project json configuration in %s: expected an object.", loc.origin)
+	}
+
+	jsonTemplate.Origins = make(map[string]string)
+	annotate(project, projectPath, loc.origin, jsonTemplate.Origins)
+	jsonTemplate.Project = project
+
+	err := merge(result, jsonTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// merge applies layer on top of result.
+func merge(result *parsing.JSONTemplate, layer *parsing.JSONTemplate) error {
+	if layer.Description != "" {
+		result.Description = layer.Description
+	}
+	if layer.Version != "" {
+		result.Version = layer.Version
+	}
+
+	for name, variable := range layer.Variables {
+		result.Variables[name] = variable
+	}
+	for name, profile := range layer.Profiles {
+		result.Profiles[name] = profile
+	}
+	for name, feature := range layer.Features {
+		result.Features[name] = feature
+	}
+	for name, generator := range layer.Generators {
+		result.Generators[name] = generator
+	}
+	for key, value := range layer.Config {
+		result.Config[key] = value
+	}
+
+	project, _ := layer.Project.(map[string]interface{})
+	return mergeDir(result, result.Project.(map[string]interface{}), project, projectPath, layer)
+}
+
+func mergeDir(result *parsing.JSONTemplate, dst map[string]interface{}, src map[string]interface{}, path string, layer *parsing.JSONTemplate) error {
+	for key, value := range src {
+		nodePath := path + "/" + key
+
+		if parsing.IsAttribute(key) {
+			dst[key] = value
+			continue
+		}
+
+		if value == parsing.RemoveNode {
+			delete(dst, key)
+			forget(result.Origins, nodePath)
+			continue
+		}
+
+		existing, exists := dst[key]
+		if exists && parsing.IsDir(existing) != parsing.IsDir(value) {
+			return fmt.Errorf("%s is a %s %s, it cannot be redefined as a %s %s; remove it first with \"%s\".",
+				key, kind(existing), result.Provenance(nodePath), kind(value), layer.Provenance(nodePath), parsing.RemoveNode)
+		}
+
+		if parsing.IsDir(value) {
+			if !exists {
+				existing = make(map[string]interface{})
+				dst[key] = existing
+				setOrigin(result, layer, nodePath)
+			}
+
+			err := mergeDir(result, existing.(map[string]interface{}), value.(map[string]interface{}), nodePath, layer)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		dst[key] = value
+		setOrigin(result, layer, nodePath)
+	}
+
+	return nil
+}
+
+// annotate records origin as the origin of node and of all its descendants.
+// Tombstones are not annotated since they never reach the composed tree.
+func annotate(node map[string]interface{}, path string, origin string, origins map[string]string) {
+	for key, value := range node {
+		if parsing.IsAttribute(key) || value == parsing.RemoveNode {
+			continue
+		}
+
+		nodePath := path + "/" + key
+		origins[nodePath] = origin
+
+		if parsing.IsDir(value) {
+			annotate(value.(map[string]interface{}), nodePath, origin, origins)
+		}
+	}
+}
+
+func setOrigin(result *parsing.JSONTemplate, layer *parsing.JSONTemplate, path string) {
+	if origin, exists := layer.Origins[path]; exists {
+		result.Origins[path] = origin
+	}
+}
+
+// forget drops the origins of path and of its descendants.
+func forget(origins map[string]string, path string) {
+	for p := range origins {
+		if p == path || strings.HasPrefix(p, path+"/") {
+			delete(origins, p)
+		}
+	}
+}
+
+func kind(value interface{}) string {
+	if parsing.IsDir(value) {
+		return "directory"
+	}
+
+	return "file"
+}
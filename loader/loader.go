@@ -0,0 +1,183 @@
+package loader
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/paoloanzn/go-bootstrap/parsing"
+	"github.com/paoloanzn/go-bootstrap/registry"
+	"github.com/paoloanzn/go-bootstrap/source"
+	"github.com/paoloanzn/go-bootstrap/templates"
+)
+
+// Load resolves ref, composes it with the templates it extends or includes
+// and validates the result. ref is tried as a remote source, a path to a JSON
+// file, a template of the user registry and finally a built-in template.
+func Load(ref string, opts source.Options) (*parsing.JSONTemplate, error) {
+	l := &loader{opts: opts}
+
+	jsonTemplate, err := l.load(ref, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// local files are recorded by absolute path, to be found again later
+	jsonTemplate.Source = ref
+	if l.root != nil && filepath.IsAbs(l.root.key) && !source.IsRemote(ref) {
+		jsonTemplate.Source = l.root.key
+	}
+
+	err = parsing.ValidateTemplate(jsonTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	return jsonTemplate, nil
+}
+
+type loader struct {
+	opts     source.Options
+	registry *registry.Registry
+	root     *located // the template Load was called for
+}
+
+// located is a template found by locate, before parsing.
+type located struct {
+	data   []byte
+	origin string // shown in error messages
+	key    string // identifies the template for cycle detection
+	dir    string // directory relative references are resolved against
+	// remote is set for templates of a remote source, whose relative
+	// references only resolve inside their checkout or bundle
+	remote bool
+}
+
+func (l *loader) load(ref string, from *located, stack []string) (*parsing.JSONTemplate, error) {
+	loc, err := l.locate(ref, from)
+	if err != nil {
+		return nil, err
+	}
+	if l.root == nil {
+		l.root = loc
+	}
+
+	for i, key := range stack {
+		if key == loc.key {
+			cycle := append(append([]string{}, stack[i:]...), loc.key)
+			return nil, fmt.Errorf("Template inheritance cycle: %s.", strings.Join(cycle, " -> "))
+		}
+	}
+	stack = append(stack, loc.key)
+
+	jsonTemplate, err := parsing.ParseTemplateData(loc.data, loc.origin)
+	if err != nil {
+		return nil, err
+	}
+
+	return l.compose(jsonTemplate, loc, stack)
+}
+
+// locate finds the template ref refers to, from the template from or from
+// the command line when nil. Relative paths are resolved against the
+// directory of from first, so that a template can extend its siblings, then
+// against the working directory unless from is remote.
+func (l *loader) locate(ref string, from *located) (*located, error) {
+	if source.IsRemote(ref) {
+		fetched, err := source.Fetch(ref, l.opts)
+		if err != nil {
+			return nil, err
+		}
+
+		loc, err := readFile(fetched.Path, ref, ref)
+		if err != nil {
+			return nil, err
+		}
+		loc.remote = true
+
+		return loc, nil
+	}
+
+	remote := from != nil && from.remote
+
+	var candidates []string
+	switch {
+	case remote && filepath.IsLocal(ref):
+		candidates = append(candidates, filepath.Join(from.dir, ref), filepath.Join(from.dir, ref+".json"))
+	case remote:
+		// neither absolute nor leaving the checkout: only names remain
+	case from != nil && from.dir != "" && !filepath.IsAbs(ref):
+		candidates = append(candidates, filepath.Join(from.dir, ref), filepath.Join(from.dir, ref+".json"), ref)
+	default:
+		candidates = append(candidates, ref)
+	}
+
+	for _, candidate := range candidates {
+		info, err := os.Stat(candidate)
+		if err != nil || info.IsDir() {
+			continue
+		}
+
+		abs, err := filepath.Abs(candidate)
+		if err != nil {
+			return nil, err
+		}
+
+		loc, err := readFile(candidate, candidate, abs)
+		if err != nil {
+			return nil, err
+		}
+		loc.remote = remote
+
+		return loc, nil
+	}
+
+	r, err := l.openRegistry()
+	if err != nil {
+		return nil, err
+	}
+	if _, exists := r.Get(ref); exists {
+		data, err := r.Read(ref)
+		if err != nil {
+			return nil, err
+		}
+
+		return &located{data: data, origin: "registry:" + ref, key: "registry:" + ref, dir: r.Dir(ref)}, nil
+	}
+
+	name := strings.TrimSuffix(ref, ".json")
+	if templates.Exists(name) {
+		data, err := templates.Read(name)
+		if err != nil {
+			return nil, err
+		}
+
+		return &located{data: data, origin: name + ".json", key: "builtin:" + name}, nil
+	}
+
+	return nil, fmt.Errorf("Template not found: %s is neither a file, a registered template nor a built-in template.", ref)
+}
+
+func readFile(path string, origin string, key string) (*located, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read file: %v", err)
+	}
+
+	return &located{data: data, origin: origin, key: key, dir: filepath.Dir(path)}, nil
+}
+
+func (l *loader) openRegistry() (*registry.Registry, error) {
+	if l.registry != nil {
+		return l.registry, nil
+	}
+
+	r, err := registry.Open()
+	if err != nil {
+		return nil, err
+	}
+	l.registry = r
+
+	return r, nil
+}
@@ -0,0 +1,304 @@
+package loader
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/paoloanzn/go-bootstrap/parsing"
+	"github.com/paoloanzn/go-bootstrap/registry"
+	"github.com/paoloanzn/go-bootstrap/source"
+)
+
+// writeTemplates writes the given templates (name -> JSON) into a new temp dir.
+func writeTemplates(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create parent dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	// keep the registry of the developer running the tests out of the way
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	return dir
+}
+
+// TestLoadComposition checks deep merging of extends and includes, config
+// overrides and tombstones.
+func TestLoadComposition(t *testing.T) {
+	dir := writeTemplates(t, map[string]string{
+		"base.json": `{
+			"description": "base",
+			"project": {
+				"cmd": {"<main_package>": {"main.go": "file"}},
+				"config": {"config.go": "file"},
+				"Makefile": "file",
+				"README.md": "file"
+			},
+			"config": {"name": "base-project", "license": "MIT"}
+		}`,
+		"fragments/docker.json": `{
+			"project": {"Dockerfile": "file", ".dockerignore": "file"},
+			"config": {"name": "docker"}
+		}`,
+		"server.json": `{
+			"extends": "base",
+			"include": ["fragments/docker.json"],
+			"project": {
+				"cmd": {"server": {"main.go": "file"}, "<main_package>": "remove"},
+				"config": "remove",
+				".dockerignore": "remove",
+				"http": {"routes.go": "file"}
+			},
+			"config": {"name": "go-backend"}
+		}`,
+	})
+
+	jsonTemplate, err := Load(filepath.Join(dir, "server.json"), source.Options{})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if jsonTemplate.Config["name"] != "go-backend" || jsonTemplate.Config["license"] != "MIT" {
+		t.Errorf("Expected child config to override parent config, got %v", jsonTemplate.Config)
+	}
+	if jsonTemplate.Description != "base" {
+		t.Errorf("Expected description to be inherited, got '%s'", jsonTemplate.Description)
+	}
+
+	project := jsonTemplate.Project.(map[string]interface{})
+	for _, name := range []string{"Makefile", "README.md", "Dockerfile", "http"} {
+		if _, exists := project[name]; !exists {
+			t.Errorf("Expected %s in the composed tree", name)
+		}
+	}
+	for _, name := range []string{"config", ".dockerignore"} {
+		if _, exists := project[name]; exists {
+			t.Errorf("Expected %s to be removed", name)
+		}
+	}
+
+	cmd := project["cmd"].(map[string]interface{})
+	if _, exists := cmd["server"]; !exists || len(cmd) != 1 {
+		t.Errorf("Expected cmd to only hold server, got %v", cmd)
+	}
+
+	if origin := jsonTemplate.Origin("/project/Makefile"); !strings.HasSuffix(origin, "base.json") {
+		t.Errorf("Expected Makefile to come from base.json, got '%s'", origin)
+	}
+	if origin := jsonTemplate.Origin("/project/Dockerfile"); !strings.HasSuffix(origin, "docker.json") {
+		t.Errorf("Expected Dockerfile to come from docker.json, got '%s'", origin)
+	}
+	if origin := jsonTemplate.Origin("/project/cmd/server/main.go"); !strings.HasSuffix(origin, "server.json") {
+		t.Errorf("Expected cmd/server to come from server.json, got '%s'", origin)
+	}
+}
+
+// TestLoadCycle checks that inheritance cycles are reported.
+func TestLoadCycle(t *testing.T) {
+	dir := writeTemplates(t, map[string]string{
+		"a.json": `{"extends": "b.json", "project": {}, "config": {"name": "a"}}`,
+		"b.json": `{"include": ["a.json"], "project": {}, "config": {"name": "b"}}`,
+	})
+
+	_, err := Load(filepath.Join(dir, "a.json"), source.Options{})
+	if err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Fatalf("Expected a cycle error, got: %v", err)
+	}
+}
+
+// TestLoadProvenance checks that conflicts and invalid nodes name the
+// template that defined them.
+func TestLoadProvenance(t *testing.T) {
+	dir := writeTemplates(t, map[string]string{
+		"base.json":     `{"project": {"cmd": {"main.go": "file"}}, "config": {"name": "base"}}`,
+		"conflict.json": `{"extends": "base", "project": {"cmd": "file"}, "config": {"name": "c"}}`,
+		"invalid.json":  `{"extends": "base", "project": {"cmd": {"x.go": 42}}, "config": {"name": "c"}}`,
+	})
+
+	_, err := Load(filepath.Join(dir, "conflict.json"), source.Options{})
+	if err == nil || !strings.Contains(err.Error(), "base.json at /project/cmd") {
+		t.Errorf("Expected the conflict to mention base.json at /project/cmd, got: %v", err)
+	}
+
+	_, err = Load(filepath.Join(dir, "invalid.json"), source.Options{})
+	if err == nil || !strings.Contains(err.Error(), "invalid.json at /project/cmd/x.go") {
+		t.Errorf("Expected the invalid node to mention invalid.json at /project/cmd/x.go, got: %v", err)
+	}
+}
+
+// TestLoadBuiltin checks that built-in templates can be loaded and extended
+// by name.
+func TestLoadBuiltin(t *testing.T) {
+	dir := writeTemplates(t, map[string]string{
+		"custom.json": `{"extends": "base", "project": {"LICENSE": "remove"}, "config": {"name": "custom"}}`,
+	})
+
+	jsonTemplate, err := Load(filepath.Join(dir, "custom.json"), source.Options{})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	project := jsonTemplate.Project.(map[string]interface{})
+	if _, exists := project["LICENSE"]; exists {
+		t.Errorf("Expected LICENSE to be removed")
+	}
+	if project["Makefile"] != parsing.FileNode {
+		t.Errorf("Expected Makefile from the built-in base template, got %v", project["Makefile"])
+	}
+
+	// the built-in server template extends base itself
+	jsonTemplate, err = Load("server", source.Options{})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	project = jsonTemplate.Project.(map[string]interface{})
+	cmd := project["cmd"].(map[string]interface{})
+	if project["LICENSE"] != parsing.FileNode || cmd["<main_package>"] != nil || project["config"] != nil {
+		t.Errorf("Expected server to keep LICENSE from base and remove its main package and config, got %v", project)
+	}
+
+	if _, err := Load("missing-template", source.Options{}); err == nil {
+		t.Errorf("Expected an error for a missing template, got nil")
+	}
+}
+
+// TestLoadRemote checks that the relative references of a remote template are
+// not looked up in the working directory, while built-in names still resolve.
+func TestLoadRemote(t *testing.T) {
+	dir := writeTemplates(t, map[string]string{
+		"sibling.json": `{"project": {"local": "file"}, "config": {"name": "local"}}`,
+	})
+	t.Chdir(dir)
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/relative.json":
+			w.Write([]byte(`{"extends": "sibling.json", "project": {}, "config": {"name": "remote"}}`))
+		case "/builtin.json":
+			w.Write([]byte(`{"extends": "base", "project": {}, "config": {"name": "remote"}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	if _, err := Load(server.URL+"/relative.json", source.Options{}); err == nil {
+		t.Errorf("Expected sibling.json not to be found in the working directory, got nil")
+	}
+
+	jsonTemplate, err := Load(server.URL+"/builtin.json", source.Options{})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if project := jsonTemplate.Project.(map[string]interface{}); project["Makefile"] != parsing.FileNode {
+		t.Errorf("Expected Makefile from the built-in base template, got %v", project["Makefile"])
+	}
+}
+
+// TestLoadRegistered checks that a registered template extends its siblings
+// whatever the working directory, when registered by a relative path.
+func TestLoadRegistered(t *testing.T) {
+	dir := writeTemplates(t, map[string]string{
+		"templates/parent.json": `{"project": {"Makefile": "file"}, "config": {"name": "parent"}}`,
+		"templates/svc.json":    `{"extends": "parent.json", "project": {"main.go": "file"}, "config": {"name": "svc"}}`,
+	})
+
+	r, err := registry.Open()
+	if err != nil {
+		t.Fatalf("Expected no error opening the registry, got: %v", err)
+	}
+	t.Chdir(dir)
+	if _, err := r.Add("svc", filepath.Join("templates", "svc.json")); err != nil {
+		t.Fatalf("Expected no error registering the template, got: %v", err)
+	}
+
+	t.Chdir(t.TempDir())
+	jsonTemplate, err := Load("svc", source.Options{})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if jsonTemplate.Project.(map[string]interface{})["Makefile"] != parsing.FileNode {
+		t.Errorf("Expected Makefile from the parent template, got %v", jsonTemplate.Project)
+	}
+	if jsonTemplate.Source != "svc" {
+		t.Errorf("Expected the source to stay svc, got %s", jsonTemplate.Source)
+	}
+}
+
+// TestSelect checks that profiles, the features they enable and explicit
+// features are merged in order, and exposed as variables.
+func TestSelect(t *testing.T) {
+	dir := writeTemplates(t, map[string]string{
+		"svc.json": `{
+			"profiles": {
+				"minimal": {"project": {"websocket": "remove"}, "config": {"tier": "minimal"}},
+				"full": {"features": ["metrics", "docker"], "config": {"tier": "full"}}
+			},
+			"features": {
+				"metrics": {"project": {"internal": {"metrics": {"metrics.go": "file"}}}},
+				"docker": {"project": {"Dockerfile": "file"}, "config": {"tier": "docker"}},
+				"tracing": {"project": {"internal": {"tracing": {"tracing.go": "file"}}}}
+			},
+			"project": {"websocket": {"server.go": "file"}, "internal": {}},
+			"config": {"name": "svc", "tier": "base"}
+		}`,
+	})
+
+	load := func() *parsing.JSONTemplate {
+		jsonTemplate, err := Load(filepath.Join(dir, "svc.json"), source.Options{})
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		return jsonTemplate
+	}
+
+	jsonTemplate := load()
+	if err := Select(jsonTemplate, "full", []string{"tracing", "metrics"}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	internal := jsonTemplate.Project.(map[string]interface{})["internal"].(map[string]interface{})
+	if len(internal) != 2 {
+		t.Errorf("Expected metrics and tracing under internal, got %v", internal)
+	}
+	if jsonTemplate.Config["tier"] != "docker" {
+		t.Errorf("Expected features to override the profile config, got %v", jsonTemplate.Config["tier"])
+	}
+	features := jsonTemplate.Variables[FeaturesVariable].Default.([]interface{})
+	if len(features) != 3 {
+		t.Errorf("Expected 3 distinct selected features, got %v", features)
+	}
+	if origin := jsonTemplate.Origin("/project/internal/tracing"); origin != "feature tracing" {
+		t.Errorf("Expected tracing to come from 'feature tracing', got '%s'", origin)
+	}
+
+	jsonTemplate = load()
+	if err := Select(jsonTemplate, "minimal", nil); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if _, exists := jsonTemplate.Project.(map[string]interface{})["websocket"]; exists {
+		t.Errorf("Expected the minimal profile to remove websocket")
+	}
+
+	for _, tt := range []struct {
+		profile  string
+		features []string
+	}{{"huge", nil}, {"", []string{"unknown"}}} {
+		if err := Select(load(), tt.profile, tt.features); err == nil {
+			t.Errorf("Expected an error selecting %s %v, got nil", tt.profile, tt.features)
+		}
+	}
+}
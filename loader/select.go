@@ -0,0 +1,127 @@
+package loader
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/paoloanzn/go-bootstrap/parsing"
+)
+
+const (
+	// ProfileVariable and FeaturesVariable expose the selection to $if
+	// conditions, e.g. "docker" in features.
+	ProfileVariable  = "profile"
+	FeaturesVariable = "features"
+)
+
+// Select applies the profile and the features chosen for jsonTemplate: the
+// profile is merged first, then the features it enables, then the features
+// requested explicitly, each layer overriding the previous ones. profile may
+// be empty.
+func Select(jsonTemplate *parsing.JSONTemplate, profile string, features []string) error {
+	var layers []*parsing.JSONTemplate
+	var selected []string
+
+	if profile != "" {
+		layer, exists := jsonTemplate.Profiles[profile]
+		if !exists {
+			return fmt.Errorf("Unknown profile %s, available profiles: %s.", profile, names(jsonTemplate.Profiles))
+		}
+
+		layers = append(layers, fromLayer(layer, "profile "+profile))
+		features = append(append([]string{}, layer.Features...), features...)
+	}
+
+	for _, feature := range features {
+		if contains(selected, feature) {
+			continue
+		}
+
+		layer, exists := jsonTemplate.Features[feature]
+		if !exists {
+			return fmt.Errorf("Unknown feature %s, available features: %s.", feature, names(jsonTemplate.Features))
+		}
+
+		layers = append(layers, fromLayer(layer, "feature "+feature))
+		selected = append(selected, feature)
+	}
+
+	if jsonTemplate.Project == nil {
+		jsonTemplate.Project = make(map[string]interface{})
+	}
+	if jsonTemplate.Config == nil {
+		jsonTemplate.Config = make(map[string]interface{})
+	}
+	if jsonTemplate.Variables == nil {
+		jsonTemplate.Variables = make(map[string]parsing.Variable)
+	}
+	if jsonTemplate.Origins == nil {
+		jsonTemplate.Origins = make(map[string]string)
+	}
+
+	for _, layer := range layers {
+		err := merge(jsonTemplate, layer)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(jsonTemplate.Profiles) > 0 || len(jsonTemplate.Features) > 0 {
+		jsonTemplate.Variables[ProfileVariable] = parsing.Variable{
+			Description: "Selected profile",
+			Default:     profile,
+		}
+
+		list := make([]interface{}, 0, len(selected))
+		for _, feature := range selected {
+			list = append(list, feature)
+		}
+		jsonTemplate.Variables[FeaturesVariable] = parsing.Variable{
+			Description: "Selected features",
+			Default:     list,
+		}
+	}
+
+	return nil
+}
+
+// fromLayer turns a profile or feature into a template that can be merged,
+// recording origin as the origin of its nodes.
+func fromLayer(layer parsing.Layer, origin string) *parsing.JSONTemplate {
+	project, _ := layer.Project.(map[string]interface{})
+
+	t := &parsing.JSONTemplate{
+		Variables: layer.Variables,
+		Project:   project,
+		Config:    layer.Config,
+		Origins:   make(map[string]string),
+	}
+	annotate(project, projectPath, origin, t.Origins)
+
+	return t
+}
+
+func names(layers map[string]parsing.Layer) string {
+	if len(layers) == 0 {
+		return "none"
+	}
+
+	list := make([]string, 0, len(layers))
+	for name := range layers {
+		list = append(list, name)
+	}
+	sort.Strings(list)
+
+	return strings.Join(list, ", ")
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+
+	return false
+}
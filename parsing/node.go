@@ -0,0 +1,149 @@
+package parsing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/paoloanzn/go-bootstrap/goedit"
+)
+
+const (
+	// FileNode is the value marking an empty file in the project tree.
+	FileNode = "file"
+
+	// RemoveNode is the tombstone deleting a node inherited through
+	// "extends" or "include".
+	RemoveNode = "remove"
+
+	// InjectNode is the $type of a node inserting its content into an
+	// existing file, before or after a marker line or at the end.
+	InjectNode = "inject"
+
+	// GoNode is the $type of a node editing an existing Go file through its
+	// syntax tree, with the edits listed by $edits.
+	GoNode = "go"
+
+	// AttrPrefix marks keys of a node object that are attributes of the
+	// node itself instead of entries of a directory.
+	AttrPrefix = "$"
+
+	AttrType    = "$type"
+	AttrContent = "$content"
+	AttrIf      = "$if"
+	AttrEach    = "$each"
+	AttrBefore  = "$before"
+	AttrAfter   = "$after"
+	AttrEdits   = "$edits"
+)
+
+// IsAttribute reports whether key is a node attribute rather than a file or
+// directory name.
+func IsAttribute(key string) bool {
+	return strings.HasPrefix(key, AttrPrefix)
+}
+
+// IsValidName reports whether name can name a file or directory of the
+// project: a single path element, neither "." nor "..".
+func IsValidName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, "/\\") && filepath.IsLocal(name)
+}
+
+// IsFile reports whether value describes a file, either as the plain "file"
+// marker or as an object with "$type": "file".
+func IsFile(value interface{}) bool {
+	if value == FileNode {
+		return true
+	}
+
+	asserted, ok := value.(map[string]interface{})
+	if !ok {
+		return false
+	}
+
+	return asserted[AttrType] == FileNode
+}
+
+// IsInject reports whether value describes an injection into an existing
+// file, an object with "$type": "inject".
+func IsInject(value interface{}) bool {
+	asserted, ok := value.(map[string]interface{})
+	return ok && asserted[AttrType] == InjectNode
+}
+
+// IsGoEdit reports whether value describes edits of an existing Go file, an
+// object with "$type": "go".
+func IsGoEdit(value interface{}) bool {
+	asserted, ok := value.(map[string]interface{})
+	return ok && asserted[AttrType] == GoNode
+}
+
+// GoEdits returns the edits listed by the $edits attribute of a Go edit node.
+func GoEdits(value interface{}) ([]goedit.Edit, error) {
+	asserted, _ := value.(map[string]interface{})
+	list, ok := asserted[AttrEdits].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("Invalid %s attribute: expected a list.", AttrEdits)
+	}
+
+	edits := make([]goedit.Edit, 0, len(list))
+	for _, item := range list {
+		data, err := json.Marshal(item)
+		if err != nil {
+			return nil, err
+		}
+
+		var edit goedit.Edit
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&edit)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid Go edit %s: %v", data, err)
+		}
+		err = edit.Validate()
+		if err != nil {
+			return nil, err
+		}
+
+		edits = append(edits, edit)
+	}
+
+	return edits, nil
+}
+
+// FileContent returns the content of a file or injection node. Plain "file"
+// nodes have no content.
+func FileContent(value interface{}) (string, error) {
+	asserted, ok := value.(map[string]interface{})
+	if !ok {
+		return "", nil
+	}
+
+	content, exists := asserted[AttrContent]
+	if !exists {
+		return "", nil
+	}
+
+	s, ok := content.(string)
+	if !ok {
+		return "", fmt.Errorf("Invalid %s attribute: expected a string.", AttrContent)
+	}
+
+	return s, nil
+}
+
+// NewFile builds a file node holding content.
+func NewFile(content string) map[string]interface{} {
+	return map[string]interface{}{
+		AttrType:    FileNode,
+		AttrContent: content,
+	}
+}
+
+// IsDir reports whether value describes a directory.
+func IsDir(value interface{}) bool {
+	_, ok := value.(map[string]interface{})
+	return ok && !IsFile(value) && !IsInject(value) && !IsGoEdit(value)
+}
@@ -5,25 +5,110 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
 )
 
 type JSONTemplate struct {
-	Project interface{}            `json:"project"`
-	Config  map[string]interface{} `json:"config"`
+	Description string                 `json:"description,omitempty"`
+	Version     string                 `json:"version,omitempty"`
+	Extends     string                 `json:"extends,omitempty"`
+	Include     []string               `json:"include,omitempty"`
+	Variables   map[string]Variable    `json:"variables,omitempty"`
+	Profiles    map[string]Layer       `json:"profiles,omitempty"`
+	Features    map[string]Layer       `json:"features,omitempty"`
+	Generators  map[string]Generator   `json:"generators,omitempty"`
+	Project     interface{}            `json:"project"`
+	Config      map[string]interface{} `json:"config"`
+
+	// Origins maps node paths such as /project/cmd to the template that
+	// defined them, when the template was composed from several files.
+	Origins map[string]string `json:"-"`
+
+	// Source is the reference the template was loaded from, recorded in the
+	// generation manifest: an absolute path, a registry or built-in name, or
+	// a remote reference.
+	Source string `json:"-"`
 }
 
-func ParseTemplate(filePath string) (*JSONTemplate, error) {
-	var pJsonTemplate *JSONTemplate = &JSONTemplate{}
+// Variable declares a value that can be supplied at init time and used as a
+// <name> wildcard in the project tree. Variables without a default are
+// required.
+type Variable struct {
+	Description string      `json:"description,omitempty"`
+	Default     interface{} `json:"default,omitempty"`
+	// Expr derives the value from other variables, e.g. "lower(base(module))".
+	Expr string `json:"expr,omitempty"`
+	// Secret keeps the value out of the generation manifest.
+	Secret bool `json:"secret,omitempty"`
+}
+
+// Layer is a named profile or feature of a template. Selecting it merges its
+// variables, config and project tree on top of the template. A profile can
+// enable a set of features.
+type Layer struct {
+	Description string                 `json:"description,omitempty"`
+	Features    []string               `json:"features,omitempty"`
+	Variables   map[string]Variable    `json:"variables,omitempty"`
+	Project     interface{}            `json:"project,omitempty"`
+	Config      map[string]interface{} `json:"config,omitempty"`
+}
+
+// Generator is a named sub-template adding a component to an existing
+// project, e.g. `go-bootstrap add handler orders`. Its project tree is
+// rendered relative to the project root.
+type Generator struct {
+	Description string              `json:"description,omitempty"`
+	Variables   map[string]Variable `json:"variables,omitempty"`
+	Project     interface{}         `json:"project"`
+}
 
+func ParseTemplate(filePath string) (*JSONTemplate, error) {
 	data, err := os.ReadFile(filePath)
 	if err != nil {
 		log.Fatalf("Failed to read file: %v\n", err)
 	}
 
-	err = json.Unmarshal(data, pJsonTemplate)
+	return ParseTemplateData(data, filePath)
+}
+
+// ParseTemplateData parses a template already loaded in memory. origin names
+// the template in error messages.
+func ParseTemplateData(data []byte, origin string) (*JSONTemplate, error) {
+	var pJsonTemplate *JSONTemplate = &JSONTemplate{}
+
+	err := json.Unmarshal(data, pJsonTemplate)
 	if err != nil {
-		return pJsonTemplate, fmt.Errorf("Unable to parse json file at %s: %v\n", filePath, err)
+		return pJsonTemplate, fmt.Errorf("Unable to parse json file at %s: %v\n", origin, err)
 	}
 
 	return pJsonTemplate, nil
 }
+
+// Origin returns the template that defined the node at path, or of its
+// closest ancestor. It is empty when the origin is unknown.
+func (t *JSONTemplate) Origin(path string) string {
+	for path != "" {
+		if origin, exists := t.Origins[path]; exists {
+			return origin
+		}
+
+		i := strings.LastIndex(path, "/")
+		if i < 0 {
+			break
+		}
+		path = path[:i]
+	}
+
+	return ""
+}
+
+// Provenance describes where the node at path was defined, for error
+// messages.
+func (t *JSONTemplate) Provenance(path string) string {
+	origin := t.Origin(path)
+	if origin == "" {
+		return fmt.Sprintf("at %s", path)
+	}
+
+	return fmt.Sprintf("defined in %s at %s", origin, path)
+}
@@ -120,3 +120,155 @@ func TestParseTemplateFileNotFound(t *testing.T) {
 
 // Note: The tests for ParseTemplate cover the valid scenario, invalid JSON content scenario, and file-not-found (error) scenario.
 // The file-not-found test uses a subprocess to safely capture the log.Fatalf behavior.
+
+// TestValidateTemplate checks that structural errors are reported with the
+// path of the offending node.
+func TestValidateTemplate(t *testing.T) {
+	tests := []struct {
+		name    string
+		project interface{}
+		config  map[string]interface{}
+		errPart string
+	}{
+		{"Valid", map[string]interface{}{"a": "file", "b": map[string]interface{}{"c": NewFile("x")}}, map[string]interface{}{"name": "p"}, ""},
+		{"MissingName", map[string]interface{}{}, map[string]interface{}{}, "config.name"},
+		{"InvalidNode", map[string]interface{}{"b": map[string]interface{}{"c": 1.0}}, map[string]interface{}{"name": "p"}, "/project/b/c"},
+		{"InvalidCondition", map[string]interface{}{"a": map[string]interface{}{AttrIf: "deploy ==", "b": "file"}}, map[string]interface{}{"name": "p"}, "/project/a"},
+		{"UnknownFileAttribute", map[string]interface{}{"a": map[string]interface{}{AttrType: FileNode, "$mode": "0755"}}, map[string]interface{}{"name": "p"}, "$mode"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateTemplate(&JSONTemplate{Project: tt.project, Config: tt.config})
+			if tt.errPart == "" {
+				if err != nil {
+					t.Errorf("Expected no error, got: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errPart) {
+				t.Errorf("Expected an error mentioning %q, got: %v", tt.errPart, err)
+			}
+		})
+	}
+}
+
+// TestValidateTemplateLayers checks that profiles only enable declared
+// features and that layer projects are validated.
+func TestValidateTemplateLayers(t *testing.T) {
+	jsonTemplate := &JSONTemplate{
+		Profiles: map[string]Layer{"full": {Features: []string{"metrics", "tracing"}}},
+		Features: map[string]Layer{"metrics": {Project: map[string]interface{}{"m": RemoveNode}}},
+		Project:  map[string]interface{}{},
+		Config:   map[string]interface{}{"name": "p"},
+	}
+
+	err := ValidateTemplate(jsonTemplate)
+	if err == nil || !strings.Contains(err.Error(), "unknown feature tracing") {
+		t.Errorf("Expected an unknown feature error, got: %v", err)
+	}
+
+	jsonTemplate.Features["tracing"] = Layer{Project: map[string]interface{}{"t": 1.0}}
+	err = ValidateTemplate(jsonTemplate)
+	if err == nil || !strings.Contains(err.Error(), "/features/tracing/project/t") {
+		t.Errorf("Expected an error at /features/tracing/project/t, got: %v", err)
+	}
+
+	// generators cannot remove nodes, they add to an existing project
+	jsonTemplate.Features["tracing"] = Layer{}
+	jsonTemplate.Generators = map[string]Generator{"handler": {Project: map[string]interface{}{"h": RemoveNode}}}
+	err = ValidateTemplate(jsonTemplate)
+	if err == nil || !strings.Contains(err.Error(), "/generators/handler/project/h") {
+		t.Errorf("Expected an error at /generators/handler/project/h, got: %v", err)
+	}
+}
+
+// TestValidateInject checks the attributes of injection nodes.
+func TestValidateInject(t *testing.T) {
+	tests := []struct {
+		node     map[string]interface{}
+		expected string
+	}{
+		{map[string]interface{}{"$type": "inject", "$content": "x", "$before": "// a"}, ""},
+		{map[string]interface{}{"$type": "inject", "$content": "x", "$before": "// a", "$after": "// b"}, "cannot have both"},
+		{map[string]interface{}{"$type": "inject", "$before": "// a"}, "Missing $content"},
+		{map[string]interface{}{"$type": "inject", "$content": "x", "$mode": "0644"}, "Unknown injection attribute $mode"},
+	}
+
+	for _, tt := range tests {
+		jsonTemplate := &JSONTemplate{
+			Project: map[string]interface{}{"routes.go": tt.node},
+			Config:  map[string]interface{}{"name": "p"},
+		}
+		err := ValidateTemplate(jsonTemplate)
+		if tt.expected == "" && err != nil {
+			t.Errorf("Expected %v to be valid, got: %v", tt.node, err)
+		}
+		if tt.expected != "" && (err == nil || !strings.Contains(err.Error(), tt.expected)) {
+			t.Errorf("Expected an error containing %q for %v, got: %v", tt.expected, tt.node, err)
+		}
+	}
+}
+
+// TestValidateNames checks that node names are single path elements.
+func TestValidateNames(t *testing.T) {
+	for _, name := range []string{"..", ".", "../pwned.txt", "a/b", `a\b`, "/etc"} {
+		jsonTemplate := &JSONTemplate{
+			Project: map[string]interface{}{name: "file"},
+			Config:  map[string]interface{}{"name": "p"},
+		}
+		err := ValidateTemplate(jsonTemplate)
+		if err == nil || !strings.Contains(err.Error(), "Invalid name") {
+			t.Errorf("Expected an invalid name error for %q, got: %v", name, err)
+		}
+	}
+}
+
+// TestValidateGoEdit checks the attributes of Go edit nodes and their edits.
+func TestValidateGoEdit(t *testing.T) {
+	edit := func(fields map[string]interface{}) []interface{} {
+		return []interface{}{fields}
+	}
+
+	tests := []struct {
+		node     map[string]interface{}
+		expected string
+	}{
+		{map[string]interface{}{"$type": "go", "$edits": edit(map[string]interface{}{"func": "Routes", "statement": "r(mux)"})}, ""},
+		{map[string]interface{}{"$type": "go", "$edits": edit(map[string]interface{}{"import": "fmt", "name": "f"})}, ""},
+		{map[string]interface{}{"$type": "go"}, "Missing $edits"},
+		{map[string]interface{}{"$type": "go", "$edits": "import fmt"}, "expected a list"},
+		{map[string]interface{}{"$type": "go", "$edits": edit(map[string]interface{}{"func": "Routes"})}, "Invalid Go edit"},
+		{map[string]interface{}{"$type": "go", "$edits": edit(map[string]interface{}{"import": "fmt", "alias": "f"})}, "unknown field"},
+		{map[string]interface{}{"$type": "go", "$edits": edit(map[string]interface{}{"import": "fmt"}), "$content": "x"}, "Unknown Go edit attribute $content"},
+	}
+
+	for _, tt := range tests {
+		jsonTemplate := &JSONTemplate{
+			Project: map[string]interface{}{"routes.go": tt.node},
+			Config:  map[string]interface{}{"name": "p"},
+		}
+		err := ValidateTemplate(jsonTemplate)
+		if tt.expected == "" && err != nil {
+			t.Errorf("Expected %v to be valid, got: %v", tt.node, err)
+		}
+		if tt.expected != "" && (err == nil || !strings.Contains(err.Error(), tt.expected)) {
+			t.Errorf("Expected an error containing %q for %v, got: %v", tt.expected, tt.node, err)
+		}
+	}
+}
+
+// TestValidateVariables checks the syntax of derived variables.
+func TestValidateVariables(t *testing.T) {
+	for _, variable := range []Variable{{Expr: "lower("}, {Expr: "base(module)", Default: "x"}} {
+		jsonTemplate := &JSONTemplate{
+			Variables: map[string]Variable{"name": variable},
+			Project:   map[string]interface{}{},
+			Config:    map[string]interface{}{"name": "p"},
+		}
+		err := ValidateTemplate(jsonTemplate)
+		if err == nil || !strings.Contains(err.Error(), "/variables/name") {
+			t.Errorf("Expected an error for variable %+v, got: %v", variable, err)
+		}
+	}
+}
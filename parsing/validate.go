@@ -0,0 +1,249 @@
+package parsing
+
+import (
+	"fmt"
+
+	"github.com/paoloanzn/go-bootstrap/expr"
+)
+
+// ValidateTemplate checks the structure of a template before anything is
+// generated from it. Errors name the offending node and, for composed
+// templates, the file that defined it.
+func ValidateTemplate(t *JSONTemplate) error {
+	name, exists := t.Config["name"]
+	if !exists {
+		return fmt.Errorf("Error parsing config.name from template config file.")
+	}
+	if _, ok := name.(string); !ok {
+		return fmt.Errorf("Invalid config.name: expected a string.")
+	}
+
+	project, ok := t.Project.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("Invalid project json configuration: expected an object.")
+	}
+
+	err := validateDir(t, project, "/project", false)
+	if err != nil {
+		return err
+	}
+
+	err = validateVariables(t.Variables, "/variables")
+	if err != nil {
+		return err
+	}
+
+	for name, profile := range t.Profiles {
+		for _, feature := range profile.Features {
+			if _, exists := t.Features[feature]; !exists {
+				return fmt.Errorf("Profile %s enables unknown feature %s.", name, feature)
+			}
+		}
+	}
+
+	for name, generator := range t.Generators {
+		path := fmt.Sprintf("/generators/%s", name)
+		err := validateVariables(generator.Variables, path+"/variables")
+		if err != nil {
+			return err
+		}
+
+		generatorProject, ok := generator.Project.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("Invalid project of %s: expected an object.", path)
+		}
+
+		err = validateDir(t, generatorProject, path+"/project", false)
+		if err != nil {
+			return err
+		}
+	}
+
+	for kind, layers := range map[string]map[string]Layer{"profiles": t.Profiles, "features": t.Features} {
+		for name, layer := range layers {
+			err := validateVariables(layer.Variables, fmt.Sprintf("/%s/%s/variables", kind, name))
+			if err != nil {
+				return err
+			}
+
+			if layer.Project == nil {
+				continue
+			}
+
+			path := fmt.Sprintf("/%s/%s/project", kind, name)
+			layerProject, ok := layer.Project.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("Invalid project of %s: expected an object.", path)
+			}
+
+			// layers may remove nodes of the template
+			err = validateDir(t, layerProject, path, true)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateVariables checks the syntax of derived variables.
+func validateVariables(variables map[string]Variable, path string) error {
+	for name, variable := range variables {
+		if variable.Expr == "" {
+			continue
+		}
+		if variable.Default != nil {
+			return fmt.Errorf("Variable %s/%s has both a default and an expression.", path, name)
+		}
+		if _, err := expr.Parse(variable.Expr); err != nil {
+			return fmt.Errorf("%v (variable %s/%s)", err, path, name)
+		}
+	}
+
+	return nil
+}
+
+func validateDir(t *JSONTemplate, node map[string]interface{}, path string, allowRemove bool) error {
+	for key, value := range node {
+		nodePath := path + "/" + key
+
+		if key == AttrIf || key == AttrEach {
+			err := validateExpression(t, key, value, path)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if IsAttribute(key) {
+			return fmt.Errorf("Unknown directory attribute %s (%s).", key, t.Provenance(path))
+		}
+		if !IsValidName(key) {
+			return fmt.Errorf("Invalid name %q (%s): expected a single path element.", key, t.Provenance(path))
+		}
+
+		switch {
+		case value == FileNode:
+		case value == RemoveNode && allowRemove:
+		case IsFile(value):
+			err := validateFile(t, value.(map[string]interface{}), nodePath)
+			if err != nil {
+				return err
+			}
+		case IsInject(value):
+			err := validateInject(t, value.(map[string]interface{}), nodePath)
+			if err != nil {
+				return err
+			}
+		case IsGoEdit(value):
+			err := validateGoEdit(t, value.(map[string]interface{}), nodePath)
+			if err != nil {
+				return err
+			}
+		case IsDir(value):
+			err := validateDir(t, value.(map[string]interface{}), nodePath, allowRemove)
+			if err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("Invalid node %v (%s): expected \"file\" or an object.", value, t.Provenance(nodePath))
+		}
+	}
+
+	return nil
+}
+
+func validateFile(t *JSONTemplate, node map[string]interface{}, path string) error {
+	for key, value := range node {
+		switch key {
+		case AttrType:
+		case AttrIf, AttrEach:
+			err := validateExpression(t, key, value, path)
+			if err != nil {
+				return err
+			}
+		case AttrContent:
+			if _, err := FileContent(node); err != nil {
+				return fmt.Errorf("%v (%s)", err, t.Provenance(path))
+			}
+		default:
+			return fmt.Errorf("Unknown file attribute %s (%s).", key, t.Provenance(path))
+		}
+	}
+
+	return nil
+}
+
+func validateInject(t *JSONTemplate, node map[string]interface{}, path string) error {
+	for key, value := range node {
+		switch key {
+		case AttrType:
+		case AttrIf, AttrEach:
+			err := validateExpression(t, key, value, path)
+			if err != nil {
+				return err
+			}
+		case AttrContent, AttrBefore, AttrAfter:
+			if _, ok := value.(string); !ok {
+				return fmt.Errorf("Invalid %s attribute (%s): expected a string.", key, t.Provenance(path))
+			}
+		default:
+			return fmt.Errorf("Unknown injection attribute %s (%s).", key, t.Provenance(path))
+		}
+	}
+
+	if _, exists := node[AttrContent]; !exists {
+		return fmt.Errorf("Missing %s attribute (%s).", AttrContent, t.Provenance(path))
+	}
+	_, before := node[AttrBefore]
+	_, after := node[AttrAfter]
+	if before && after {
+		return fmt.Errorf("Injection %s cannot have both %s and %s.", t.Provenance(path), AttrBefore, AttrAfter)
+	}
+
+	return nil
+}
+
+func validateGoEdit(t *JSONTemplate, node map[string]interface{}, path string) error {
+	for key, value := range node {
+		switch key {
+		case AttrType, AttrEdits:
+		case AttrIf, AttrEach:
+			err := validateExpression(t, key, value, path)
+			if err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("Unknown Go edit attribute %s (%s).", key, t.Provenance(path))
+		}
+	}
+
+	if _, exists := node[AttrEdits]; !exists {
+		return fmt.Errorf("Missing %s attribute (%s).", AttrEdits, t.Provenance(path))
+	}
+	if _, err := GoEdits(node); err != nil {
+		return fmt.Errorf("%v (%s)", err, t.Provenance(path))
+	}
+
+	return nil
+}
+
+// validateExpression checks the syntax of a $if condition or an $each loop.
+func validateExpression(t *JSONTemplate, key string, value interface{}, path string) error {
+	s, ok := value.(string)
+	if !ok {
+		return fmt.Errorf("Invalid %s attribute (%s): expected a string.", key, t.Provenance(path))
+	}
+
+	var err error
+	if key == AttrEach {
+		_, _, err = expr.ParseLoop(s)
+	} else {
+		_, err = expr.Parse(s)
+	}
+	if err != nil {
+		return fmt.Errorf("%v (%s)", err, t.Provenance(path))
+	}
+
+	return nil
+}
@@ -0,0 +1,249 @@
+package registry
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/paoloanzn/go-bootstrap/config"
+	"github.com/paoloanzn/go-bootstrap/parsing"
+	"github.com/paoloanzn/go-bootstrap/source"
+)
+
+const (
+	indexFile    = "registry.json"
+	templatesDir = "templates"
+)
+
+var validName = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+
+// Entry records where a registered template comes from. The template itself
+// is copied into the registry, so later changes to the source have no effect
+// until the template is added again.
+type Entry struct {
+	Name     string    `json:"name"`
+	Source   string    `json:"source"`
+	Version  string    `json:"version,omitempty"`
+	Checksum string    `json:"checksum"`
+	Added    time.Time `json:"added"`
+}
+
+type Registry struct {
+	dir       string
+	Templates map[string]*Entry `json:"templates"`
+}
+
+// Open loads the registry of the current user, stored in the go-bootstrap
+// configuration directory.
+func Open() (*Registry, error) {
+	dir, err := config.ConfigDir()
+	if err != nil {
+		return nil, err
+	}
+
+	return OpenDir(dir)
+}
+
+// OpenDir loads the registry stored in dir. A missing registry is empty.
+func OpenDir(dir string) (*Registry, error) {
+	r := &Registry{dir: dir, Templates: make(map[string]*Entry)}
+
+	data, err := os.ReadFile(filepath.Join(dir, indexFile))
+	if os.IsNotExist(err) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(data, r)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to parse template registry at %s: %v", dir, err)
+	}
+	if r.Templates == nil {
+		r.Templates = make(map[string]*Entry)
+	}
+
+	return r, nil
+}
+
+// Add registers the template found at ref under name, replacing any
+// template already registered with that name. ref is a path to a JSON
+// template or a remote source understood by the source package.
+func (r *Registry) Add(name string, ref string) (*Entry, error) {
+	if !validName.MatchString(name) {
+		return nil, fmt.Errorf("Invalid template name %q: use lowercase letters, digits, '.', '_' and '-'.", name)
+	}
+
+	data, version, err := fetch(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	// local templates are recorded by absolute path, so that their relative
+	// references resolve the same from any directory
+	if !source.IsRemote(ref) {
+		ref, err = filepath.Abs(ref)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	jsonTemplate, err := parsing.ParseTemplateData(data, ref)
+	if err != nil {
+		return nil, err
+	}
+	if jsonTemplate.Version != "" {
+		version = jsonTemplate.Version
+	}
+
+	err = os.MkdirAll(filepath.Join(r.dir, templatesDir), 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.WriteFile(r.templatePath(name), data, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &Entry{
+		Name:     name,
+		Source:   ref,
+		Version:  version,
+		Checksum: checksum(data),
+		Added:    time.Now().UTC(),
+	}
+	r.Templates[name] = entry
+
+	return entry, r.save()
+}
+
+// Remove unregisters name and deletes its stored copy.
+func (r *Registry) Remove(name string) error {
+	if _, exists := r.Templates[name]; !exists {
+		return fmt.Errorf("Template %s is not registered.", name)
+	}
+
+	err := os.Remove(r.templatePath(name))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	delete(r.Templates, name)
+	return r.save()
+}
+
+// Get returns the entry registered under name.
+func (r *Registry) Get(name string) (*Entry, bool) {
+	entry, exists := r.Templates[name]
+	return entry, exists
+}
+
+// List returns the registered entries sorted by name.
+func (r *Registry) List() []*Entry {
+	entries := make([]*Entry, 0, len(r.Templates))
+	for _, entry := range r.Templates {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name < entries[j].Name
+	})
+
+	return entries
+}
+
+// Read returns the stored template registered under name, after verifying
+// that it still matches the recorded checksum.
+func (r *Registry) Read(name string) ([]byte, error) {
+	entry, exists := r.Templates[name]
+	if !exists {
+		return nil, fmt.Errorf("Template %s is not registered.", name)
+	}
+
+	data, err := os.ReadFile(r.templatePath(name))
+	if err != nil {
+		return nil, err
+	}
+
+	if sum := checksum(data); sum != entry.Checksum {
+		return nil, fmt.Errorf("Template %s was modified since it was added: checksum %s, expected %s.", name, sum, entry.Checksum)
+	}
+
+	return data, nil
+}
+
+// Dir returns the directory the relative "extends" and "include" references
+// of the template registered under name resolve against, that of its local
+// source. It is empty for remote sources.
+func (r *Registry) Dir(name string) string {
+	entry, exists := r.Templates[name]
+	if !exists || source.IsRemote(entry.Source) || !filepath.IsAbs(entry.Source) {
+		return ""
+	}
+
+	return filepath.Dir(entry.Source)
+}
+
+// Load parses the template registered under name.
+func (r *Registry) Load(name string) (*parsing.JSONTemplate, error) {
+	data, err := r.Read(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return parsing.ParseTemplateData(data, r.templatePath(name))
+}
+
+func (r *Registry) templatePath(name string) string {
+	return filepath.Join(r.dir, templatesDir, name+".json")
+}
+
+func (r *Registry) save() error {
+	data, err := json.MarshalIndent(r, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(r.dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	// write then rename so a failed write never truncates the registry
+	tmp := filepath.Join(r.dir, indexFile+".tmp")
+	err = os.WriteFile(tmp, append(data, '\n'), 0644)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, filepath.Join(r.dir, indexFile))
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// fetch returns the template at ref and its version when one can be
+// derived from the source itself.
+func fetch(ref string) ([]byte, string, error) {
+	if !source.IsRemote(ref) {
+		data, err := os.ReadFile(ref)
+		return data, "", err
+	}
+
+	fetched, err := source.Fetch(ref, source.Options{})
+	if err != nil {
+		return nil, "", err
+	}
+
+	data, err := os.ReadFile(fetched.Path)
+	return data, fetched.Version, err
+}
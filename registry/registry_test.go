@@ -0,0 +1,141 @@
+package registry
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testTemplate = `{
+	"version": "1.0.0",
+	"project": {"main.go": "file"},
+	"config": {"name": "svc"}
+}`
+
+func writeTemplate(t *testing.T, dir string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, "svc.json")
+	if err := os.WriteFile(path, []byte(testTemplate), 0644); err != nil {
+		t.Fatalf("Failed to write template: %v", err)
+	}
+
+	return path
+}
+
+// TestRegistryLifecycle covers add, reopen, load, list and remove of a template
+// added from a local path.
+func TestRegistryLifecycle(t *testing.T) {
+	dir := t.TempDir()
+	source := writeTemplate(t, t.TempDir())
+
+	r, err := OpenDir(dir)
+	if err != nil {
+		t.Fatalf("Expected no error opening an empty registry, got: %v", err)
+	}
+
+	entry, err := r.Add("svc", source)
+	if err != nil {
+		t.Fatalf("Expected no error adding a template, got: %v", err)
+	}
+	if entry.Version != "1.0.0" {
+		t.Errorf("Expected version '1.0.0', got '%s'", entry.Version)
+	}
+	if !strings.HasPrefix(entry.Checksum, "sha256:") {
+		t.Errorf("Expected a sha256 checksum, got '%s'", entry.Checksum)
+	}
+
+	// The registry must survive a reopen and keep working after the source
+	// is gone, since the template is copied.
+	os.Remove(source)
+	r, err = OpenDir(dir)
+	if err != nil {
+		t.Fatalf("Expected no error reopening the registry, got: %v", err)
+	}
+
+	jsonTemplate, err := r.Load("svc")
+	if err != nil {
+		t.Fatalf("Expected no error loading the template, got: %v", err)
+	}
+	if jsonTemplate.Config["name"] != "svc" {
+		t.Errorf("Expected config.name 'svc', got %v", jsonTemplate.Config["name"])
+	}
+
+	if list := r.List(); len(list) != 1 || list[0].Name != "svc" {
+		t.Errorf("Expected a single svc entry, got %v", list)
+	}
+
+	if err := r.Remove("svc"); err != nil {
+		t.Fatalf("Expected no error removing the template, got: %v", err)
+	}
+	if _, exists := r.Get("svc"); exists {
+		t.Errorf("Expected svc to be removed")
+	}
+	if err := r.Remove("svc"); err == nil {
+		t.Errorf("Expected an error removing an unregistered template, got nil")
+	}
+}
+
+// TestRegistryChecksum checks that a tampered copy is refused.
+func TestRegistryChecksum(t *testing.T) {
+	dir := t.TempDir()
+
+	r, _ := OpenDir(dir)
+	if _, err := r.Add("svc", writeTemplate(t, t.TempDir())); err != nil {
+		t.Fatalf("Expected no error adding a template, got: %v", err)
+	}
+
+	err := os.WriteFile(r.templatePath("svc"), []byte(`{"project": {}, "config": {"name": "x"}}`), 0644)
+	if err != nil {
+		t.Fatalf("Failed to tamper with the template: %v", err)
+	}
+
+	if _, err := r.Load("svc"); err == nil {
+		t.Errorf("Expected a checksum error, got nil")
+	}
+}
+
+// TestRegistryInvalidName checks that names are validated.
+func TestRegistryInvalidName(t *testing.T) {
+	r, _ := OpenDir(t.TempDir())
+
+	for _, name := range []string{"", "Upper", "../escape", "with space"} {
+		if _, err := r.Add(name, "unused.json"); err == nil {
+			t.Errorf("Expected an error for name %q, got nil", name)
+		}
+	}
+}
+
+// TestRegistryGit adds a template from a local git repository.
+func TestRegistryGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	repo := t.TempDir()
+	if err := os.WriteFile(filepath.Join(repo, "template.json"), []byte(`{"project": {}, "config": {"name": "x"}}`), 0644); err != nil {
+		t.Fatalf("Failed to write template: %v", err)
+	}
+	for _, args := range [][]string{
+		{"init", "--quiet"},
+		{"add", "template.json"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "--quiet", "-m", "template"},
+		{"tag", "v2.0.0"},
+	} {
+		if out, err := exec.Command("git", append([]string{"-C", repo}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v: %s", args, err, out)
+		}
+	}
+
+	r, _ := OpenDir(t.TempDir())
+	entry, err := r.Add("svc", "git+file://"+repo)
+	if err != nil {
+		t.Fatalf("Expected no error adding a git template, got: %v", err)
+	}
+	if entry.Version != "v2.0.0" {
+		t.Errorf("Expected version 'v2.0.0', got '%s'", entry.Version)
+	}
+}
@@ -0,0 +1,235 @@
+package source
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// GitSource is a template stored in a git repository, written as
+// [git+]<repo>[//<subdir>][?ref=<ref>], for example
+// git+file:///srv/templates.git//services/http?ref=v1.2.0 or
+// git@github.com:acme/templates.git//cli.
+type GitSource struct {
+	Repo   string // URL passed to git clone
+	Subdir string // template file or directory inside the repository
+	Ref    string // branch, tag or commit, empty for the default branch
+}
+
+// IsGit reports whether ref points to a git repository.
+func IsGit(ref string) bool {
+	for _, prefix := range []string{"git+", "git@", "git://", "ssh://"} {
+		if strings.HasPrefix(ref, prefix) {
+			return true
+		}
+	}
+
+	repo, _, _ := splitGit(ref)
+	return strings.HasSuffix(repo, ".git")
+}
+
+// ParseGit splits ref into its repository, subdirectory and ref parts.
+func ParseGit(ref string) (*GitSource, error) {
+	repo, subdir, query := splitGit(strings.TrimPrefix(ref, "git+"))
+	if repo == "" {
+		return nil, fmt.Errorf("Invalid git source %s: missing repository.", ref)
+	}
+
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid git source %s: %v", ref, err)
+	}
+
+	if subdir != "" {
+		subdir = path.Clean(subdir)
+		if !filepath.IsLocal(subdir) {
+			return nil, fmt.Errorf("Invalid git source %s: subdirectory %s escapes the repository.", ref, subdir)
+		}
+	}
+
+	gitRef := values.Get("ref")
+	err = checkRef(gitRef)
+	if err != nil {
+		return nil, fmt.Errorf("%v (in %s)", err, ref)
+	}
+
+	return &GitSource{
+		Repo:   repo,
+		Subdir: subdir,
+		Ref:    gitRef,
+	}, nil
+}
+
+func splitGit(ref string) (repo string, subdir string, query string) {
+	ref, query, _ = strings.Cut(ref, "?")
+
+	// the subdirectory separator is the first "//" after the scheme
+	start := 0
+	if i := strings.Index(ref, "://"); i >= 0 {
+		start = i + len("://")
+	}
+
+	if i := strings.Index(ref[start:], "//"); i >= 0 {
+		return ref[:start+i], ref[start+i+2:], query
+	}
+
+	return ref, "", query
+}
+
+// FetchGit clones or updates src.Repo in cacheDir and returns the template
+// inside src.Ref. Each commit is exported to a directory of its own, which is
+// never changed afterwards, so concurrent runs at different refs do not step
+// on each other. In offline mode the cached clone is used as is.
+func FetchGit(src *GitSource, cacheDir string, opts Options) (*Fetched, error) {
+	sum := sha256.Sum256([]byte(src.Repo))
+	key := hex.EncodeToString(sum[:8])
+	dir := filepath.Join(cacheDir, "git", key)
+
+	err := os.MkdirAll(filepath.Dir(dir), 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	unlock, err := lock(dir + ".lock")
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	_, err = os.Stat(filepath.Join(dir, ".git"))
+	switch {
+	case opts.Offline:
+		if err != nil {
+			return nil, fmt.Errorf("%s is not in the cache and offline mode is enabled.", src.Repo)
+		}
+	case err != nil:
+		os.RemoveAll(dir) // leftovers of an interrupted clone
+		_, err = git("", "clone", "--quiet", "--no-checkout", "--", src.Repo, dir)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		_, err = git(dir, "fetch", "--quiet", "--force", "--tags", "--prune", "origin")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	commit, err := resolveRef(dir, src.Ref)
+	if err != nil {
+		return nil, err
+	}
+
+	checkout := filepath.Join(cacheDir, "git", key+"-"+commit[:12])
+	err = exportCommit(dir, commit, checkout)
+	if err != nil {
+		return nil, err
+	}
+
+	version := src.Ref
+	if version == "" {
+		version, err = git(dir, "describe", "--tags", "--always", commit)
+		if err != nil {
+			version = commit
+		}
+	}
+
+	templatePath, err := templateFile(filepath.Join(checkout, filepath.FromSlash(src.Subdir)))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Fetched{Path: templatePath, Version: version}, nil
+}
+
+// exportCommit writes the files of commit in the clone dir to target, unless
+// an earlier run already did.
+func exportCommit(dir string, commit string, target string) error {
+	if _, err := os.Stat(target); err == nil {
+		return nil
+	}
+
+	archive, err := gitOutput(dir, "archive", "--format=tar", commit)
+	if err != nil {
+		return err
+	}
+
+	// extract next to target then rename, so that target is never partial
+	tmp, err := os.MkdirTemp(filepath.Dir(target), filepath.Base(target)+".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	err = extractTar(bytes.NewReader(archive), tmp)
+	if err != nil {
+		return fmt.Errorf("Unable to export %s: %v", commit, err)
+	}
+
+	return os.Rename(tmp, target)
+}
+
+// resolveRef returns the commit ref points to. Branches are looked up on the
+// remote first so that a stale local branch is never used.
+func resolveRef(dir string, ref string) (string, error) {
+	err := checkRef(ref)
+	if err != nil {
+		return "", err
+	}
+
+	candidates := []string{"origin/HEAD"}
+	if ref != "" {
+		candidates = []string{"origin/" + ref, ref}
+	}
+
+	for _, candidate := range candidates {
+		commit, err := git(dir, "rev-parse", "--verify", "--quiet", candidate+"^{commit}")
+		if err == nil {
+			return commit, nil
+		}
+	}
+
+	return "", fmt.Errorf("Unknown git ref %s.", ref)
+}
+
+// checkRef rejects refs git would read as an option.
+func checkRef(ref string) error {
+	if strings.HasPrefix(ref, "-") {
+		return fmt.Errorf("Invalid git ref %s: refs cannot start with a dash.", ref)
+	}
+
+	return nil
+}
+
+func git(dir string, args ...string) (string, error) {
+	out, err := gitOutput(dir, args...)
+	return strings.TrimSpace(string(out)), err
+}
+
+// gitOutput runs git in dir and returns its raw standard output.
+func gitOutput(dir string, args ...string) ([]byte, error) {
+	subcommand := args[0]
+	if dir != "" {
+		args = append([]string{"-C", dir}, args...)
+	}
+
+	cmd := exec.Command("git", args...)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+
+	out, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("git %s: %v: %s", subcommand, err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, err
+	}
+
+	return out, nil
+}
@@ -0,0 +1,363 @@
+package source
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	bodyFile    = "body"
+	metaFile    = "meta.json"
+	bundleDir   = "bundle"
+	maxDownload = 64 << 20
+)
+
+var (
+	// maxExtract and maxEntries bound what a bundle may expand to.
+	maxExtract int64 = 256 << 20
+	maxEntries       = 10000
+)
+
+// httpClient gives up on servers that stop responding instead of hanging.
+var httpClient = &http.Client{Timeout: 60 * time.Second}
+
+// HTTPSource is a template or a .tar.gz bundle served over HTTP(S). A
+// #sha256=<hex> fragment pins the expected content.
+type HTTPSource struct {
+	URL    string
+	SHA256 string
+}
+
+// IsHTTP reports whether ref is an HTTP(S) URL that is not a git repository.
+func IsHTTP(ref string) bool {
+	return (strings.HasPrefix(ref, "http://") || strings.HasPrefix(ref, "https://")) && !IsGit(ref)
+}
+
+// ParseHTTP splits the pinned checksum off ref.
+func ParseHTTP(ref string) (*HTTPSource, error) {
+	url, fragment, _ := strings.Cut(ref, "#")
+	src := &HTTPSource{URL: url}
+
+	if fragment == "" {
+		return src, nil
+	}
+
+	sum, ok := strings.CutPrefix(fragment, "sha256=")
+	if !ok {
+		return nil, fmt.Errorf("Invalid source %s: unsupported fragment %s, expected sha256=<hex>.", ref, fragment)
+	}
+
+	sum = strings.ToLower(sum)
+	if _, err := hex.DecodeString(sum); err != nil || len(sum) != sha256.Size*2 {
+		return nil, fmt.Errorf("Invalid source %s: malformed sha256 %s.", ref, sum)
+	}
+	src.SHA256 = sum
+
+	return src, nil
+}
+
+// IsBundle reports whether the source is a .tar.gz archive of a template
+// directory rather than a single JSON template.
+func (src *HTTPSource) IsBundle() bool {
+	path := strings.SplitN(src.URL, "?", 2)[0]
+	return strings.HasSuffix(path, ".tar.gz") || strings.HasSuffix(path, ".tgz")
+}
+
+// httpMeta holds the validators of a cached download.
+type httpMeta struct {
+	URL          string    `json:"url"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	SHA256       string    `json:"sha256"`
+	Fetched      time.Time `json:"fetched"`
+}
+
+// FetchHTTP downloads src into cacheDir, revalidating a cached copy with its
+// ETag and Last-Modified validators. In offline mode only the cache is used.
+// A pinned checksum is verified before the template is returned.
+func FetchHTTP(src *HTTPSource, cacheDir string, opts Options) (*Fetched, error) {
+	key := sha256.Sum256([]byte(src.URL))
+	dir := filepath.Join(cacheDir, "http", hex.EncodeToString(key[:8]))
+
+	meta, cached := readMeta(dir)
+
+	if opts.Offline {
+		if !cached {
+			return nil, fmt.Errorf("%s is not in the cache and offline mode is enabled.", src.URL)
+		}
+	} else {
+		var err error
+		meta, err = download(src.URL, dir, meta)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, bodyFile))
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(data)
+	actual := hex.EncodeToString(sum[:])
+	if src.SHA256 != "" && actual != src.SHA256 {
+		return nil, fmt.Errorf("Checksum mismatch for %s: got sha256 %s, expected %s.", src.URL, actual, src.SHA256)
+	}
+
+	fetched := &Fetched{
+		Path:     filepath.Join(dir, bodyFile),
+		Version:  strings.Trim(strings.TrimPrefix(meta.ETag, "W/"), `"`),
+		Checksum: "sha256:" + actual,
+	}
+
+	if !src.IsBundle() {
+		return fetched, nil
+	}
+
+	// bundles are extracted once per content, never over a directory that
+	// another run may be reading
+	extracted := filepath.Join(dir, bundleDir+"-"+actual[:12])
+	if _, err := os.Stat(extracted); err != nil {
+		tmp, err := os.MkdirTemp(dir, bundleDir+".tmp-")
+		if err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(tmp)
+
+		err = extractTarGz(data, tmp)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to extract %s: %v", src.URL, err)
+		}
+
+		err = os.Rename(tmp, extracted)
+		if err != nil && !dirExists(extracted) {
+			return nil, err
+		}
+	}
+
+	fetched.Path, err = bundleTemplate(extracted)
+	if err != nil {
+		return nil, err
+	}
+
+	return fetched, nil
+}
+
+func readMeta(dir string) (*httpMeta, bool) {
+	meta := &httpMeta{}
+
+	data, err := os.ReadFile(filepath.Join(dir, metaFile))
+	if err != nil {
+		return meta, false
+	}
+	if json.Unmarshal(data, meta) != nil {
+		return &httpMeta{}, false
+	}
+	if _, err := os.Stat(filepath.Join(dir, bodyFile)); err != nil {
+		return &httpMeta{}, false
+	}
+
+	return meta, true
+}
+
+// download fetches url into dir unless the cached copy described by meta is
+// still valid, and returns the validators to keep.
+func download(url string, dir string, meta *httpMeta) (*httpMeta, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if meta.ETag != "" {
+		req.Header.Set("If-None-Match", meta.ETag)
+	}
+	if meta.LastModified != "" {
+		req.Header.Set("If-Modified-Since", meta.LastModified)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		if meta.SHA256 != "" {
+			return meta, nil
+		}
+		// the cache entry cannot be verified, e.g. written by an older
+		// version: fetch the body again, without validators
+		if meta.ETag != "" || meta.LastModified != "" {
+			return download(url, dir, &httpMeta{})
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Unable to download %s: %s", url, resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxDownload+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxDownload {
+		return nil, fmt.Errorf("Unable to download %s: larger than %d bytes.", url, maxDownload)
+	}
+
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(data)
+	meta = &httpMeta{
+		URL:          url,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		SHA256:       hex.EncodeToString(sum[:]),
+		Fetched:      time.Now().UTC(),
+	}
+
+	err = writeFileAtomic(filepath.Join(dir, bodyFile), data)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded, err := json.MarshalIndent(meta, "", "    ")
+	if err != nil {
+		return nil, err
+	}
+
+	return meta, writeFileAtomic(filepath.Join(dir, metaFile), encoded)
+}
+
+// writeFileAtomic writes data to a temporary file next to path then renames
+// it, so that readers never see a partial file and concurrent writers do not
+// share a temporary file.
+func writeFileAtomic(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-")
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+
+	return err
+}
+
+func dirExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// extractTarGz extracts a gzipped tarball into dir, refusing entries that
+// would land outside of it.
+func extractTarGz(data []byte, dir string) error {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	return extractTar(gz, dir)
+}
+
+// extractTar extracts a tarball into dir, refusing entries that would land
+// outside of it and archives expanding to more than maxExtract bytes or
+// maxEntries entries.
+func extractTar(r io.Reader, dir string) error {
+	tr := tar.NewReader(r)
+	remaining := maxExtract
+
+	for entries := 0; ; entries++ {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if entries >= maxEntries {
+			return fmt.Errorf("more than %d entries", maxEntries)
+		}
+
+		name := filepath.FromSlash(strings.TrimPrefix(header.Name, "./"))
+		if name == "" || name == "." {
+			continue
+		}
+		if !filepath.IsLocal(name) {
+			return fmt.Errorf("entry %s escapes the bundle", header.Name)
+		}
+		target := filepath.Join(dir, name)
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(target, 0755)
+		case tar.TypeReg:
+			if header.Size > remaining {
+				return fmt.Errorf("larger than %d bytes once extracted", maxExtract)
+			}
+			remaining -= header.Size
+
+			err = os.MkdirAll(filepath.Dir(target), 0755)
+			if err == nil {
+				err = writeEntry(target, io.LimitReader(tr, header.Size))
+			}
+		default:
+			// links and special files are not part of templates
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func writeEntry(path string, r io.Reader) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(f, r)
+	return err
+}
+
+// bundleTemplate finds the template file of an extracted bundle, either at
+// its root or inside its single top-level directory.
+func bundleTemplate(dir string) (string, error) {
+	if path, err := templateFile(dir); err == nil {
+		return path, nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	if len(entries) == 1 && entries[0].IsDir() {
+		return templateFile(filepath.Join(dir, entries[0].Name()))
+	}
+
+	return "", fmt.Errorf("Template not found: no %s in bundle.", TemplateFile)
+}
@@ -0,0 +1,253 @@
+package source
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const httpTemplate = `{"project": {}, "config": {"name": "portal"}}`
+
+// newTemplateServer serves body with an ETag and counts full downloads.
+func newTemplateServer(t *testing.T, body []byte, downloads *int32) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		etag := `"v1"`
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		atomic.AddInt32(downloads, 1)
+		w.Header().Set("ETag", etag)
+		w.Write(body)
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// TestFetchHTTP covers download, revalidation, offline mode and pinning.
+func TestFetchHTTP(t *testing.T) {
+	var downloads int32
+	server := newTemplateServer(t, []byte(httpTemplate), &downloads)
+	cacheDir := t.TempDir()
+	url := server.URL + "/template.json"
+
+	t.Run("OfflineWithoutCache", func(t *testing.T) {
+		src, _ := ParseHTTP(url)
+		if _, err := FetchHTTP(src, cacheDir, Options{Offline: true}); err == nil {
+			t.Errorf("Expected an error in offline mode without cache, got nil")
+		}
+	})
+
+	t.Run("DownloadAndRevalidate", func(t *testing.T) {
+		src, _ := ParseHTTP(url + "#sha256=" + sha256Hex([]byte(httpTemplate)))
+
+		for i := 0; i < 2; i++ {
+			fetched, err := FetchHTTP(src, cacheDir, Options{})
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			data, _ := os.ReadFile(fetched.Path)
+			if string(data) != httpTemplate {
+				t.Errorf("Expected the served template, got %s", data)
+			}
+			if fetched.Version != "v1" {
+				t.Errorf("Expected version from the ETag, got %s", fetched.Version)
+			}
+		}
+
+		if atomic.LoadInt32(&downloads) != 1 {
+			t.Errorf("Expected a single download thanks to revalidation, got %d", downloads)
+		}
+	})
+
+	t.Run("NotModifiedWithoutChecksum", func(t *testing.T) {
+		// a cache entry with validators but no checksum is fetched again
+		meta, err := download(url, t.TempDir(), &httpMeta{ETag: `"v1"`})
+		if err != nil {
+			t.Fatalf("Expected the template to be downloaded again, got: %v", err)
+		}
+		if meta.SHA256 != sha256Hex([]byte(httpTemplate)) {
+			t.Errorf("Expected the checksum of the template, got %q", meta.SHA256)
+		}
+	})
+
+	t.Run("Offline", func(t *testing.T) {
+		server.Close()
+
+		src, _ := ParseHTTP(url)
+		fetched, err := FetchHTTP(src, cacheDir, Options{Offline: true})
+		if err != nil {
+			t.Fatalf("Expected the cached copy to be used, got: %v", err)
+		}
+		data, _ := os.ReadFile(fetched.Path)
+		if string(data) != httpTemplate {
+			t.Errorf("Expected the cached template, got %s", data)
+		}
+	})
+
+	t.Run("ChecksumMismatch", func(t *testing.T) {
+		src, _ := ParseHTTP(url + "#sha256=" + strings.Repeat("0", 64))
+		if _, err := FetchHTTP(src, cacheDir, Options{Offline: true}); err == nil {
+			t.Errorf("Expected a checksum mismatch error, got nil")
+		}
+	})
+}
+
+// TestFetchHTTPBundle downloads a .tar.gz bundle with the template inside a
+// top-level directory.
+func TestFetchHTTPBundle(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	tw.WriteHeader(&tar.Header{Name: "portal/", Typeflag: tar.TypeDir, Mode: 0755})
+	tw.WriteHeader(&tar.Header{Name: "portal/template.json", Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(httpTemplate))})
+	tw.Write([]byte(httpTemplate))
+	tw.Close()
+	gz.Close()
+
+	var downloads int32
+	server := newTemplateServer(t, buf.Bytes(), &downloads)
+
+	src, err := ParseHTTP(server.URL + "/portal.tar.gz")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !src.IsBundle() {
+		t.Fatalf("Expected a bundle source")
+	}
+
+	fetched, err := FetchHTTP(src, t.TempDir(), Options{})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	data, _ := os.ReadFile(fetched.Path)
+	if string(data) != httpTemplate {
+		t.Errorf("Expected the bundled template, got %s", data)
+	}
+}
+
+// TestFetchHTTPConcurrent fetches the same bundle from concurrent runs, which
+// must not share temporary files or extract over each other.
+func TestFetchHTTPConcurrent(t *testing.T) {
+	var downloads int32
+	server := newTemplateServer(t, tarGz(map[string]string{"template.json": httpTemplate}), &downloads)
+	cacheDir := t.TempDir()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			src, _ := ParseHTTP(server.URL + "/portal.tgz")
+			fetched, err := FetchHTTP(src, cacheDir, Options{})
+			if err != nil {
+				t.Errorf("Expected no error, got: %v", err)
+				return
+			}
+			if data, _ := os.ReadFile(fetched.Path); string(data) != httpTemplate {
+				t.Errorf("Expected the bundled template, got %s", data)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// TestExtractTarLimits checks that bundles expanding beyond the size or
+// entry limits are refused.
+func TestExtractTarLimits(t *testing.T) {
+	defer func(size int64, entries int) { maxExtract, maxEntries = size, entries }(maxExtract, maxEntries)
+	maxExtract, maxEntries = 16, 2
+
+	tests := []struct {
+		files    map[string]string
+		expected string
+	}{
+		{map[string]string{"a": "0123456789", "b": "012345"}, ""},
+		{map[string]string{"a": "0123456789", "b": "0123456789"}, "larger than 16 bytes"},
+		{map[string]string{"a": "", "b": "", "c": ""}, "more than 2 entries"},
+	}
+
+	for _, tt := range tests {
+		err := extractTarGz(tarGz(tt.files), t.TempDir())
+		if tt.expected == "" && err != nil {
+			t.Errorf("%v: expected no error, got: %v", tt.files, err)
+		}
+		if tt.expected != "" && (err == nil || !strings.Contains(err.Error(), tt.expected)) {
+			t.Errorf("%v: expected an error containing %q, got: %v", tt.files, tt.expected, err)
+		}
+	}
+}
+
+// TestFetchHTTPTimeout checks that a server that stops responding fails the
+// download.
+func TestFetchHTTPTimeout(t *testing.T) {
+	defer func(client *http.Client) { httpClient = client }(httpClient)
+	httpClient = &http.Client{Timeout: 50 * time.Millisecond}
+
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer server.Close()
+	defer close(done)
+
+	src, _ := ParseHTTP(server.URL + "/template.json")
+	if _, err := FetchHTTP(src, t.TempDir(), Options{}); err == nil {
+		t.Errorf("Expected a timeout error, got nil")
+	}
+}
+
+// tarGz returns a gzipped tarball of files, in name order.
+func tarGz(files map[string]string) []byte {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, name := range names {
+		tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(files[name]))})
+		tw.Write([]byte(files[name]))
+	}
+	tw.Close()
+	gz.Close()
+
+	return buf.Bytes()
+}
+
+// TestParseHTTP covers source detection and fragment validation.
+func TestParseHTTP(t *testing.T) {
+	if !IsHTTP("https://example.com/t.json") || IsHTTP("https://example.com/t.git") {
+		t.Errorf("Expected plain URLs to be HTTP sources and .git URLs not to")
+	}
+
+	for _, ref := range []string{"https://example.com/t.json#md5=abc", "https://example.com/t.json#sha256=xyz"} {
+		if _, err := ParseHTTP(ref); err == nil {
+			t.Errorf("Expected an error for %s, got nil", ref)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package source
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+const (
+	// lockTimeout bounds the wait for another process holding a lock.
+	lockTimeout = 5 * time.Minute
+	// staleLock is the age after which a lock is considered left behind by
+	// a process that crashed while holding it.
+	staleLock = 10 * time.Minute
+)
+
+// lock takes the lock file at path, waiting while another process holds it,
+// and returns the function releasing it.
+func lock(path string) (func(), error) {
+	deadline := time.Now().Add(lockTimeout)
+
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > staleLock {
+			os.Remove(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("Timed out waiting for the lock %s.", path)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
@@ -0,0 +1,78 @@
+package source
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/paoloanzn/go-bootstrap/config"
+)
+
+// TemplateFile is the template looked up when a source points to a directory.
+const TemplateFile = "template.json"
+
+// Fetched is a remote template made available on the local filesystem.
+type Fetched struct {
+	Path     string
+	Version  string
+	Checksum string // of the downloaded content, when known
+}
+
+type Options struct {
+	// Offline forbids network access: only cached sources can be used.
+	Offline bool
+}
+
+// IsRemote reports whether ref must be fetched before it can be parsed.
+func IsRemote(ref string) bool {
+	return IsGit(ref) || IsHTTP(ref)
+}
+
+// Fetch makes the remote template ref available locally, caching it under the
+// go-bootstrap user cache directory.
+func Fetch(ref string, opts Options) (*Fetched, error) {
+	cacheDir, err := config.CacheDir()
+	if err != nil {
+		return nil, err
+	}
+
+	if IsGit(ref) {
+		src, err := ParseGit(ref)
+		if err != nil {
+			return nil, err
+		}
+
+		return FetchGit(src, cacheDir, opts)
+	}
+
+	if IsHTTP(ref) {
+		src, err := ParseHTTP(ref)
+		if err != nil {
+			return nil, err
+		}
+
+		return FetchHTTP(src, cacheDir, opts)
+	}
+
+	return nil, fmt.Errorf("Unsupported template source %s.", ref)
+}
+
+// templateFile returns path itself when it is a file, or the template file
+// inside it when it is a directory.
+func templateFile(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("Template not found: %v", err)
+	}
+
+	if !info.IsDir() {
+		return path, nil
+	}
+
+	path = filepath.Join(path, TemplateFile)
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("Template not found: %v", err)
+	}
+
+	return path, nil
+}
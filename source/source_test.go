@@ -0,0 +1,200 @@
+package source
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestParseGit covers the accepted forms of git sources.
+func TestParseGit(t *testing.T) {
+	tests := []struct {
+		input  string
+		repo   string
+		subdir string
+		ref    string
+	}{
+		{"git+file:///srv/templates.git//services/http?ref=v1.2.0", "file:///srv/templates.git", "services/http", "v1.2.0"},
+		{"git+https://example.com/acme/templates.git", "https://example.com/acme/templates.git", "", ""},
+		{"https://example.com/acme/templates.git//cli", "https://example.com/acme/templates.git", "cli", ""},
+		{"git@github.com:acme/templates.git//cli/template.json?ref=main", "git@github.com:acme/templates.git", "cli/template.json", "main"},
+		{"ssh://git@example.com/acme/templates.git?ref=abc123", "ssh://git@example.com/acme/templates.git", "", "abc123"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if !IsGit(tt.input) {
+				t.Errorf("Expected %q to be a git source", tt.input)
+			}
+
+			src, err := ParseGit(tt.input)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if src.Repo != tt.repo || src.Subdir != tt.subdir || src.Ref != tt.ref {
+				t.Errorf("ParseGit(%q) = %+v; want {%s %s %s}", tt.input, *src, tt.repo, tt.subdir, tt.ref)
+			}
+		})
+	}
+}
+
+// TestParseGitInvalid checks that a subdirectory cannot escape the repository,
+// that refs cannot be read as git options and that local paths are not
+// mistaken for git sources.
+func TestParseGitInvalid(t *testing.T) {
+	if _, err := ParseGit("git+file:///srv/t.git//../etc"); err == nil {
+		t.Errorf("Expected an error for an escaping subdirectory, got nil")
+	}
+	if _, err := ParseGit("git+file:///srv/t.git?ref=--output=/tmp/x"); err == nil {
+		t.Errorf("Expected an error for a ref starting with a dash, got nil")
+	}
+	if _, err := resolveRef(t.TempDir(), "--all"); err == nil {
+		t.Errorf("Expected resolveRef to refuse a ref starting with a dash, got nil")
+	}
+
+	for _, ref := range []string{"templates/base.json", "server", "/abs/path.json"} {
+		if IsGit(ref) {
+			t.Errorf("Expected %q not to be a git source", ref)
+		}
+	}
+}
+
+// gitRun runs git in dir and fails the test on error.
+func gitRun(t *testing.T, dir string, args ...string) {
+	t.Helper()
+
+	args = append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)
+	if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+		t.Fatalf("git %v failed: %v: %s", args, err, out)
+	}
+}
+
+// commitTemplate writes a template under subdir of the work tree and commits it.
+func commitTemplate(t *testing.T, work string, subdir string, name string) {
+	t.Helper()
+
+	dir := filepath.Join(work, subdir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("Failed to create %s: %v", dir, err)
+	}
+
+	content := `{"project": {}, "config": {"name": "` + name + `"}}`
+	if err := os.WriteFile(filepath.Join(dir, TemplateFile), []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write template: %v", err)
+	}
+
+	gitRun(t, work, "add", "-A")
+	gitRun(t, work, "commit", "--quiet", "-m", name)
+}
+
+// TestFetchGit fetches templates from a local bare repository at different
+// refs and subdirectories, reusing the cache between fetches.
+func TestFetchGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	bare := filepath.Join(t.TempDir(), "templates.git")
+	work := t.TempDir()
+	cacheDir := t.TempDir()
+
+	gitRun(t, t.TempDir(), "init", "--quiet", "--bare", bare)
+	gitRun(t, work, "init", "--quiet")
+	commitTemplate(t, work, "services/http", "v1")
+	gitRun(t, work, "tag", "v1.2.0")
+	gitRun(t, work, "push", "--quiet", "--tags", bare, "HEAD:refs/heads/main")
+	gitRun(t, bare, "symbolic-ref", "HEAD", "refs/heads/main")
+
+	tests := []struct {
+		ref     string
+		name    string
+		version string
+	}{
+		{"git+file://" + bare + "//services/http?ref=v1.2.0", "v1", "v1.2.0"},
+		{"git+file://" + bare + "//services/http/template.json", "v1", "v1.2.0"},
+	}
+
+	for _, tt := range tests {
+		src, err := ParseGit(tt.ref)
+		if err != nil {
+			t.Fatalf("Expected no error parsing %s, got: %v", tt.ref, err)
+		}
+
+		fetched, err := FetchGit(src, cacheDir, Options{})
+		if err != nil {
+			t.Fatalf("Expected no error fetching %s, got: %v", tt.ref, err)
+		}
+		if fetched.Version != tt.version {
+			t.Errorf("Expected version %s, got %s", tt.version, fetched.Version)
+		}
+
+		data, _ := os.ReadFile(fetched.Path)
+		if want := `"name": "` + tt.name + `"`; !strings.Contains(string(data), want) {
+			t.Errorf("Expected template %s, got %s", tt.name, data)
+		}
+	}
+
+	// A new commit on main must be picked up through the cache while the tag
+	// keeps pointing to the old template.
+	commitTemplate(t, work, "services/http", "v2")
+	gitRun(t, work, "push", "--quiet", bare, "HEAD:refs/heads/main")
+
+	for ref, name := range map[string]string{
+		"git+file://" + bare + "//services/http?ref=main":   "v2",
+		"git+file://" + bare + "//services/http":            "v2",
+		"git+file://" + bare + "//services/http?ref=v1.2.0": "v1",
+	} {
+		src, _ := ParseGit(ref)
+		fetched, err := FetchGit(src, cacheDir, Options{})
+		if err != nil {
+			t.Fatalf("Expected no error fetching %s, got: %v", ref, err)
+		}
+
+		data, _ := os.ReadFile(fetched.Path)
+		if want := `"name": "` + name + `"`; !strings.Contains(string(data), want) {
+			t.Errorf("Fetching %s: expected template %s, got %s", ref, name, data)
+		}
+	}
+
+	src, _ := ParseGit("git+file://" + bare + "?ref=missing")
+	if _, err := FetchGit(src, cacheDir, Options{}); err == nil {
+		t.Errorf("Expected an error for an unknown ref, got nil")
+	}
+
+	// concurrent runs at different refs each get their own template
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		ref, name := "v1.2.0", "v1"
+		if i%2 == 1 {
+			ref, name = "main", "v2"
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			src, _ := ParseGit("git+file://" + bare + "//services/http?ref=" + ref)
+			fetched, err := FetchGit(src, cacheDir, Options{})
+			if err != nil {
+				t.Errorf("Expected no error fetching %s, got: %v", ref, err)
+				return
+			}
+
+			data, _ := os.ReadFile(fetched.Path)
+			if want := `"name": "` + name + `"`; !strings.Contains(string(data), want) {
+				t.Errorf("Fetching %s: expected template %s, got %s", ref, name, data)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// repositories are never parsed as options of git clone
+	src, _ = ParseGit("git+--upload-pack=touch pwned.git")
+	_, err := FetchGit(src, t.TempDir(), Options{})
+	if err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Errorf("Expected the repository to be looked up as a path, got: %v", err)
+	}
+}
@@ -0,0 +1,63 @@
+package templates
+
+import (
+	"embed"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/paoloanzn/go-bootstrap/parsing"
+)
+
+// SchemaFile is the JSON schema shipped next to the templates. It is not a
+// template itself.
+const SchemaFile = "template.schema.json"
+
+//go:embed *.json
+var files embed.FS
+
+// Names returns the names of the built-in templates, sorted.
+func Names() []string {
+	entries, _ := files.ReadDir(".")
+
+	var names []string
+	for _, entry := range entries {
+		if entry.Name() == SchemaFile {
+			continue
+		}
+		names = append(names, strings.TrimSuffix(entry.Name(), ".json"))
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Exists reports whether name is a built-in template.
+func Exists(name string) bool {
+	for _, n := range Names() {
+		if n == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Read returns the raw JSON of the built-in template name.
+func Read(name string) ([]byte, error) {
+	if !Exists(name) {
+		return nil, fmt.Errorf("Unknown built-in template %s.", name)
+	}
+
+	return files.ReadFile(name + ".json")
+}
+
+// Load parses the built-in template name.
+func Load(name string) (*parsing.JSONTemplate, error) {
+	data, err := Read(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return parsing.ParseTemplateData(data, "builtin:"+name)
+}
@@ -0,0 +1,43 @@
+package templates
+
+import (
+	"testing"
+)
+
+// TestCatalog checks that every advertised template is embedded and parses.
+func TestCatalog(t *testing.T) {
+	expected := []string{"base", "cli", "grpc-service", "library", "server", "worker"}
+
+	names := Names()
+	if len(names) != len(expected) {
+		t.Fatalf("Expected templates %v, got %v", expected, names)
+	}
+
+	for i, name := range expected {
+		if names[i] != name {
+			t.Errorf("Expected template %s at position %d, got %s", name, i, names[i])
+		}
+
+		jsonTemplate, err := Load(name)
+		if err != nil {
+			t.Errorf("Failed to load %s: %v", name, err)
+			continue
+		}
+		if jsonTemplate.Description == "" {
+			t.Errorf("Expected %s to have a description", name)
+		}
+		if _, exists := jsonTemplate.Config["name"]; !exists {
+			t.Errorf("Expected %s to define config.name", name)
+		}
+	}
+}
+
+// TestLoadUnknown checks the error returned for a missing template, including
+// the schema file which is embedded but is not a template.
+func TestLoadUnknown(t *testing.T) {
+	for _, name := range []string{"missing", "template.schema"} {
+		if _, err := Load(name); err == nil {
+			t.Errorf("Expected an error loading %s, got nil", name)
+		}
+	}
+}